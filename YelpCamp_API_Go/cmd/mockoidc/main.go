@@ -0,0 +1,31 @@
+// Command mockoidc runs a local OpenID Connect provider for trying out
+// social login without a real provider. Every login is approved as the user
+// given by the flags; see .env.example for the matching API settings.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/oauth/mockoidc"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "listen address")
+	issuer := flag.String("issuer", "http://localhost:9000", "issuer URL the provider is reached at")
+	var user mockoidc.User
+	flag.StringVar(&user.Subject, "sub", "mock-user-1", "subject of the signed-in user")
+	flag.StringVar(&user.Email, "email", "camper@example.com", "email of the signed-in user")
+	flag.BoolVar(&user.EmailVerified, "email-verified", true, "whether the email is reported as verified")
+	flag.StringVar(&user.Username, "username", "camper", "preferred username of the signed-in user")
+	flag.StringVar(&user.Name, "name", "Happy Camper", "display name of the signed-in user")
+	flag.Parse()
+
+	server, err := mockoidc.New(*issuer, user)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Mock OIDC provider %s listening on %s as %s", *issuer, *addr, user.Email)
+	log.Fatal(http.ListenAndServe(*addr, server))
+}
@@ -1,73 +1,249 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
-	"github.com/joho/godotenv"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/auth"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/background"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/config"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/export"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/geocode"
 	"github.com/sangnn2012/yelpcamp-api-go/internal/handlers"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/mailer"
 	mw "github.com/sangnn2012/yelpcamp-api-go/internal/middleware"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/oauth"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/problem"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store/memory"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store/postgres"
 	"github.com/sangnn2012/yelpcamp-api-go/pkg/database"
 )
 
 func main() {
-	// Load .env file
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found")
+	command := ""
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+
+	// Load and validate configuration; subcommands only need the database
+	load := config.Load
+	if command == "migrate" || command == "role" {
+		load = config.LoadDatabase
+	}
+	cfg, err := load()
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
 	}
 
 	// Connect to database
-	db, err := database.Connect(os.Getenv("DATABASE_URL"))
+	db, err := database.Connect(cfg.DatabaseURL)
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
 	defer db.Close()
 
-	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(db)
-	campgroundHandler := handlers.NewCampgroundHandler(db)
-	commentHandler := handlers.NewCommentHandler(db)
+	// Schema migrations: server migrate up|down|status|to N
+	if command == "migrate" {
+		if err := runMigrate(db, os.Args[2:]); err != nil {
+			log.Fatal("Migration failed: ", err)
+		}
+		return
+	}
+
+	// Role assignment: server role USERNAME user|moderator|admin
+	if command == "role" {
+		if err := runRole(db, os.Args[2:]); err != nil {
+			log.Fatal("Role change failed: ", err)
+		}
+		return
+	}
+
+	mail, err := newMailer(cfg.Mail)
+	if err != nil {
+		log.Fatal("Invalid mail configuration: ", err)
+	}
+
+	// Initialize stores and handlers
+	stores := postgres.New(db)
+	keys, err := newKeySet(cfg)
+	if err != nil {
+		log.Fatal("Invalid JWT keys: ", err)
+	}
+	tokens := auth.NewTokenService(keys, cfg.JWT.Issuer, cfg.JWT.Audience, cfg.Auth.AccessTokenTTL,
+		cfg.Auth.RefreshTokenTTL, stores.Users, stores.Sessions, stores.RefreshTokens)
+	apiKeys := auth.NewAPIKeys(stores.APIKeys, stores.Users)
+	authenticator := mw.NewAuthenticator(tokens, apiKeys)
+	emails := auth.NewEmailVerification(stores.Users, stores.Verifications, mail, cfg.AppURL,
+		cfg.Auth.EmailVerificationTTL, cfg.Auth.VerificationResendInterval)
+	twoFactor := auth.NewTwoFactor(stores.TwoFactors, stores.Verifications, cfg.Auth.TwoFactorIssuer,
+		cfg.Auth.TwoFactorChallengeTTL)
+	// Emails sent after a request is answered; shutdown waits for them
+	tasks := background.NewRunner(30 * time.Second)
+	guard := auth.NewLoginGuard(stores.Throttles, auth.NewMailLockoutNotifier(mail, cfg.AppURL), tasks, auth.LoginGuardConfig{
+		MaxFailures:     cfg.Auth.LoginMaxFailures,
+		MaxIPFailures:   cfg.Auth.LoginMaxIPFailures,
+		LockoutDuration: cfg.Auth.LoginLockoutDuration,
+		FailureWindow:   cfg.Auth.LoginFailureWindow,
+		BackoffBase:     cfg.Auth.LoginBackoffBase,
+	})
+	authHandler := handlers.NewAuthHandler(stores.Users, tokens, emails, twoFactor, guard, tasks)
+	twoFactorHandler := handlers.NewTwoFactorHandler(stores.Users, twoFactor)
+	sessionHandler := handlers.NewSessionHandler(tokens)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeys)
+	resets := auth.NewPasswordReset(stores.Verifications, mail, cfg.AppURL, cfg.Auth.PasswordResetTTL)
+	passwordHandler := handlers.NewPasswordHandler(stores.Users, tokens, apiKeys, resets, tasks)
+	accountHandler := handlers.NewAccountHandler(stores.Users, tokens, emails, resets)
+	exporter := export.NewExporter(stores, cfg.DataExportTTL)
+	exportHandler := handlers.NewExportHandler(exporter)
+	userHandler := handlers.NewUserHandler(stores.Users, stores.Campgrounds, stores.Comments)
+	oauthHandler := handlers.NewOAuthHandler(newOAuthProviders(cfg.OAuth),
+		auth.NewSocialLogin(stores.Users, stores.Accounts, stores.Sessions, stores.APIKeys), tokens, twoFactor,
+		cfg.JWTSecret, cfg.AppURL)
+	geocoder, err := newGeocoder(cfg.Geocoder)
+	if err != nil {
+		log.Fatal("Invalid geocoder configuration: ", err)
+	}
+	var locator *geocode.Locator
+	if geocoder != nil {
+		locator = geocode.NewLocator(stores.Campgrounds, geocoder)
+	}
+	campgroundHandler := handlers.NewCampgroundHandler(stores.Campgrounds, stores.Comments, locator)
+	commentHandler := handlers.NewCommentHandler(stores.Campgrounds, stores.Comments)
+	adminHandler := handlers.NewAdminHandler(stores.Users, stores.AuditLogs, tokens, apiKeys, resets, guard)
+	healthHandler := handlers.NewHealthHandler(db)
+	jwksHandler := handlers.NewJWKSHandler(keys)
+
+	// Rate limits: credential endpoints get the strict auth policy, the
+	// rest a read or write policy by method
+	noLimit := func(next http.Handler) http.Handler { return next }
+	limitAuth, limitByMethod := noLimit, noLimit
+	var limiter *mw.RateLimiter
+	if cfg.RateLimit.Enabled {
+		limiter = mw.NewRateLimiter(newRateLimitStore(cfg.RateLimit, stores))
+		limitAuth = limiter.Limit(ratePolicy("auth", cfg.RateLimit.Auth))
+		limitByMethod = limiter.ByMethod(ratePolicy("read", cfg.RateLimit.Read), ratePolicy("write", cfg.RateLimit.Write))
+	}
 
 	// Setup router
 	r := chi.NewRouter()
 
 	// Middleware
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
 	r.Use(middleware.RequestID)
+	r.Use(mw.ClientIP(trustedProxies(cfg.Server.TrustedProxies)))
+	r.Use(middleware.Logger)
+	r.Use(mw.Recoverer)
 	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"http://localhost:3000", "http://localhost:3003"},
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
+		ExposedHeaders:   []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After"},
 		AllowCredentials: true,
 	}))
 
-	// Health check
-	r.Get("/api/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(`{"status":"ok","service":"YelpCamp API (Go)"}`))
-	})
+	// Errors are problem details, also for routes that don't exist
+	r.NotFound(problem.NotFoundHandler)
+	r.MethodNotAllowed(problem.MethodNotAllowedHandler)
+
+	// Optionally hold back new content until the author's email is verified
+	var requireVerified []func(http.Handler) http.Handler
+	if cfg.Auth.RequireVerifiedEmail {
+		requireVerified = append(requireVerified, mw.RequireVerifiedEmail(stores.Users))
+	}
+
+	// Health checks
+	r.Get("/api/health", healthHandler.Health)
+	r.Get("/api/ready", healthHandler.Ready)
+	r.Get("/.well-known/jwks.json", jwksHandler.JWKS)
 
 	// Auth routes
 	r.Route("/api/auth", func(r chi.Router) {
-		r.Post("/register", authHandler.Register)
-		r.Post("/login", authHandler.Login)
-		r.Post("/logout", authHandler.Logout)
-		r.With(mw.RequireAuth).Get("/me", authHandler.Me)
+		r.Group(func(r chi.Router) {
+			r.Use(limitAuth)
+			r.Post("/register", authHandler.Register)
+			r.Post("/login", authHandler.Login)
+			r.Post("/login/2fa", authHandler.LoginTwoFactor)
+			r.Post("/refresh", authHandler.Refresh)
+			r.Post("/password/forgot", passwordHandler.Forgot)
+			r.Post("/password/reset", passwordHandler.Reset)
+			r.Post("/verify-email", authHandler.VerifyEmail)
+			r.Get("/oauth/{provider}", oauthHandler.Start)
+			r.Get("/oauth/{provider}/callback", oauthHandler.Callback)
+		})
+		r.With(limitByMethod).Get("/oauth", oauthHandler.Providers)
+		r.With(authenticator.OptionalAuth, limitByMethod).Post("/logout", authHandler.Logout)
+
+		r.Group(func(r chi.Router) {
+			r.Use(authenticator.RequireAuth)
+			r.Use(limitByMethod)
+			r.Post("/verify-email/resend", authHandler.ResendVerification)
+			r.Get("/me", authHandler.Me)
+		})
+
+		// Session and credential management needs a real login, not an API key
+		r.Group(func(r chi.Router) {
+			r.Use(authenticator.RequireAuth)
+			r.Use(limitByMethod)
+			r.Use(mw.RequireSession)
+			r.Get("/sessions", sessionHandler.List)
+			r.Delete("/sessions", sessionHandler.RevokeAll)
+			r.Delete("/sessions/{id}", sessionHandler.Revoke)
+			r.Post("/2fa/enroll", twoFactorHandler.Enroll)
+			r.Post("/2fa/confirm", twoFactorHandler.Confirm)
+			r.Post("/2fa/disable", twoFactorHandler.Disable)
+			r.Post("/2fa/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes)
+			r.Put("/password", accountHandler.ChangePassword)
+			r.Put("/email", accountHandler.ChangeEmail)
+			r.Delete("/me", accountHandler.DeleteAccount)
+			r.Get("/api-keys", apiKeyHandler.List)
+			r.Post("/api-keys", apiKeyHandler.Create)
+			r.Delete("/api-keys/{id}", apiKeyHandler.Revoke)
+		})
+	})
+
+	// Public profiles
+	r.Route("/api/users/{username}", func(r chi.Router) {
+		r.Use(limitByMethod)
+		r.Get("/", userHandler.Profile)
+		r.Get("/campgrounds", userHandler.Campgrounds)
+		r.Get("/comments", userHandler.Comments)
+	})
+
+	r.Route("/api/me", func(r chi.Router) {
+		r.Use(authenticator.RequireAuth)
+		r.Use(limitByMethod)
+		r.Put("/profile", userHandler.UpdateProfile)
+
+		// Personal data export; like credential management it needs a real login
+		r.Group(func(r chi.Router) {
+			r.Use(mw.RequireSession)
+			r.Post("/export", exportHandler.Request)
+			r.Get("/export/{id}", exportHandler.Status)
+			r.Get("/export/{id}/download", exportHandler.Download)
+		})
 	})
 
 	// Campground routes
 	r.Route("/api/campgrounds", func(r chi.Router) {
-		r.Get("/", campgroundHandler.List)
-		r.Get("/{id}", campgroundHandler.GetByID)
+		r.With(limitByMethod).Get("/", campgroundHandler.List)
+		r.With(limitByMethod).Get("/{id}", campgroundHandler.GetByID)
 
 		r.Group(func(r chi.Router) {
-			r.Use(mw.RequireAuth)
-			r.Post("/", campgroundHandler.Create)
+			r.Use(authenticator.RequireAuth)
+			r.Use(limitByMethod)
+			r.With(requireVerified...).Post("/", campgroundHandler.Create)
 			r.Put("/{id}", campgroundHandler.Update)
 			r.Delete("/{id}", campgroundHandler.Delete)
 		})
@@ -75,24 +251,180 @@ func main() {
 
 	// Comment routes
 	r.Route("/api/campgrounds/{campgroundId}/comments", func(r chi.Router) {
-		r.Use(mw.RequireAuth)
-		r.Post("/", commentHandler.Create)
+		r.Use(authenticator.RequireAuth)
+		r.Use(limitByMethod)
+		r.With(requireVerified...).Post("/", commentHandler.Create)
 	})
 
 	r.Route("/api/comments", func(r chi.Router) {
-		r.Use(mw.RequireAuth)
+		r.Use(authenticator.RequireAuth)
+		r.Use(limitByMethod)
 		r.Put("/{id}", commentHandler.Update)
 		r.Delete("/{id}", commentHandler.Delete)
 	})
 
+	// Admin routes
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Use(authenticator.RequireAuth)
+		r.Use(mw.RequireSession)
+		r.Use(mw.RequireRole(models.RoleAdmin))
+		r.Use(limitByMethod)
+		r.Get("/users", adminHandler.ListUsers)
+		r.Get("/users/{id}", adminHandler.GetUser)
+		r.Delete("/users/{id}", adminHandler.DeleteUser)
+		r.Put("/users/{id}/role", adminHandler.UpdateRole)
+		r.Post("/users/{id}/suspend", adminHandler.Suspend)
+		r.Post("/users/{id}/unsuspend", adminHandler.Unsuspend)
+		r.Post("/users/{id}/unlock", adminHandler.Unlock)
+		r.Post("/users/{id}/password-reset", adminHandler.ForcePasswordReset)
+		r.Get("/audit-logs", adminHandler.ListAuditLogs)
+	})
+
 	// Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "3004"
+	srv := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           r,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Background workers keep running while requests drain, and are waited
+	// for before the database closes under them
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var workers sync.WaitGroup
+	startWorker := func(run func(ctx context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workerCtx)
+		}()
+	}
+	startWorker(exporter.Run)
+	if locator != nil {
+		startWorker(locator.Run)
 	}
+	if limiter != nil {
+		idle := max(cfg.RateLimit.Auth.Period, cfg.RateLimit.Read.Period, cfg.RateLimit.Write.Period)
+		startWorker(func(ctx context.Context) { limiter.Prune(ctx, time.Minute, idle) })
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Server starting on port %s", cfg.Port)
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+		return
+	case <-ctx.Done():
+	}
+	stop()
+
+	// Fail readiness first and give load balancers time to notice before
+	// the listener closes, then drain in-flight requests.
+	log.Println("Shutting down...")
+	healthHandler.SetReady(false)
+	time.Sleep(cfg.Server.ShutdownDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Println("Graceful shutdown did not finish:", err)
+		srv.Close()
+	}
+	if err := tasks.Wait(shutdownCtx); err != nil {
+		log.Println("Background tasks did not finish:", err)
+	}
+	stopWorkers()
+	workers.Wait()
+
+	db.Close()
+	log.Println("Server stopped")
+}
+
+func newMailer(cfg config.MailConfig) (mailer.Mailer, error) {
+	if cfg.Driver == "smtp" {
+		return mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From)
+	}
+	return mailer.NewLogMailer(cfg.Dir, cfg.From), nil
+}
+
+// newGeocoder returns nil when geocoding is off. Answers are cached either
+// way, so fixture setups behave like production ones.
+func newGeocoder(cfg config.GeocoderConfig) (geocode.Geocoder, error) {
+	var g geocode.Geocoder
+	switch cfg.Driver {
+	case "http":
+		g = geocode.NewHTTPGeocoder(geocode.HTTPConfig{
+			URL:          cfg.URL,
+			LatField:     cfg.LatField,
+			LngField:     cfg.LngField,
+			AddressField: cfg.AddressField,
+			Headers:      cfg.Headers,
+			Timeout:      cfg.Timeout,
+		})
+	case "fixture":
+		fixtures, err := geocode.LoadFixtureGeocoder(cfg.FixtureFile)
+		if err != nil {
+			return nil, err
+		}
+		g = fixtures
+	default:
+		return nil, nil
+	}
+	return geocode.NewCache(g, cfg.CacheTTL, cfg.CacheSize), nil
+}
+
+func newKeySet(cfg *config.Config) (*auth.KeySet, error) {
+	if len(cfg.JWT.Keys) == 0 {
+		return auth.NewHMACKeySet(cfg.JWTSecret), nil
+	}
+	files := make([]auth.KeyFile, len(cfg.JWT.Keys))
+	for i, k := range cfg.JWT.Keys {
+		files[i] = auth.KeyFile{ID: k.ID, Path: k.File}
+	}
+	return auth.LoadKeySet(files, cfg.JWT.SigningKeyID)
+}
+
+// newRateLimitStore shares buckets between replicas through Postgres, or
+// keeps them in this process.
+func newRateLimitStore(cfg config.RateLimitConfig, stores *store.Stores) store.RateLimitStore {
+	if cfg.Backend == "postgres" {
+		return stores.RateLimits
+	}
+	return memory.NewRateLimitStore(memory.NewDB())
+}
+
+func ratePolicy(name string, rate config.Rate) mw.RatePolicy {
+	return mw.RatePolicy{Name: name, Limit: rate.Limit, Period: rate.Period}
+}
+
+// trustedProxies parses TRUSTED_PROXIES, which Validate already checked.
+func trustedProxies(addrs []string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, a := range addrs {
+		if network, err := config.ParseNetwork(a); err == nil {
+			networks = append(networks, network)
+		}
+	}
+	return networks
+}
 
-	log.Printf("Server starting on port %s", port)
-	if err := http.ListenAndServe(":"+port, r); err != nil {
-		log.Fatal(err)
+func newOAuthProviders(cfgs map[string]config.OAuthProviderConfig) []*oauth.Provider {
+	var providers []*oauth.Provider
+	for name, p := range cfgs {
+		providers = append(providers, oauth.NewProvider(name, p.Issuer, p.ClientID, p.ClientSecret,
+			p.RedirectURL, p.Scopes))
 	}
+	return providers
 }
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store/postgres"
+)
+
+const roleUsage = "usage: server role USERNAME user|moderator|admin"
+
+// runRole changes a user's role from the command line, which is how the
+// first admin gets appointed.
+func runRole(db *pgxpool.Pool, args []string) error {
+	if len(args) != 2 {
+		return errors.New(roleUsage)
+	}
+	role := models.Role(args[1])
+	if !role.Valid() {
+		return errors.New(roleUsage)
+	}
+
+	ctx := context.Background()
+	users := postgres.NewUserStore(db)
+	user, err := users.GetByUsername(ctx, args[0])
+	if err != nil {
+		return fmt.Errorf("find user %q: %w", args[0], err)
+	}
+	if err := users.UpdateRole(ctx, user.ID, role); err != nil {
+		return err
+	}
+	fmt.Printf("%s is now %s\n", user.Username, role)
+	return nil
+}
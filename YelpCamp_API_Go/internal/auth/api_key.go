@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+const (
+	// apiKeyPrefix marks our keys so secret scanners and people can spot them.
+	apiKeyPrefix = "yc_"
+	// apiKeyShownChars is how much of a key is kept in clear for display.
+	apiKeyShownChars = len(apiKeyPrefix) + 6
+	// apiKeyTouchInterval limits last-used updates to one write per key per
+	// interval, rather than one per request.
+	apiKeyTouchInterval = time.Minute
+)
+
+// APIKeys manages personal API keys. They authenticate as their user, but
+// without a session, so they survive logouts and password changes until
+// revoked or expired.
+type APIKeys struct {
+	keys  store.APIKeyStore
+	users store.UserStore
+}
+
+func NewAPIKeys(keys store.APIKeyStore, users store.UserStore) *APIKeys {
+	return &APIKeys{keys: keys, users: users}
+}
+
+// Create generates a key for the user. The returned raw key can't be
+// recovered later.
+func (a *APIKeys) Create(ctx context.Context, userID, name string, scopes []string,
+	expiresAt *time.Time) (*models.APIKey, string, error) {
+	token, err := GenerateToken()
+	if err != nil {
+		return nil, "", err
+	}
+	raw := apiKeyPrefix + token
+
+	key := &models.APIKey{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    raw[:apiKeyShownChars],
+		KeyHash:   HashToken(raw),
+		Scopes:    normalizeScopes(scopes),
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+	if err := a.keys.Create(ctx, key); err != nil {
+		return nil, "", err
+	}
+	return key, raw, nil
+}
+
+func (a *APIKeys) List(ctx context.Context, userID string) ([]models.APIKey, error) {
+	return a.keys.ListByUser(ctx, userID)
+}
+
+func (a *APIKeys) Revoke(ctx context.Context, userID, id string) error {
+	return a.keys.Delete(ctx, userID, id)
+}
+
+// RevokeAll deletes all of the user's keys. Account recovery does this along
+// with ending sessions, since keys would otherwise outlive both.
+func (a *APIKeys) RevokeAll(ctx context.Context, userID string) error {
+	return a.keys.DeleteByUser(ctx, userID)
+}
+
+// Authenticate looks up a raw key and its user. Unknown and expired keys
+// give ErrInvalidToken, keys of suspended users ErrSuspended.
+func (a *APIKeys) Authenticate(ctx context.Context, raw string) (*models.APIKey, *models.User, error) {
+	key, err := a.keys.GetByHash(ctx, HashToken(raw))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+		return nil, nil, ErrInvalidToken
+	}
+
+	user, err := a.users.GetByID(ctx, key.UserID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.SuspendedAt != nil {
+		return nil, nil, ErrSuspended
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := a.keys.TouchLastUsed(ctx, key.ID, now); err != nil {
+			return nil, nil, err
+		}
+		key.LastUsedAt = &now
+	}
+	return key, user, nil
+}
+
+// normalizeScopes sorts and dedupes scopes; write implies read.
+func normalizeScopes(scopes []string) []string {
+	set := map[string]bool{}
+	for _, s := range scopes {
+		set[s] = true
+	}
+	if set[models.APIKeyScopeWrite] {
+		set[models.APIKeyScopeRead] = true
+	}
+	normalized := make([]string, 0, len(set))
+	for s := range set {
+		normalized = append(normalized, s)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
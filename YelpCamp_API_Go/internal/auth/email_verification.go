@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/mailer"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+const PurposeEmailVerification = "email-verification"
+
+var ErrAlreadyVerified = errors.New("email already verified")
+
+// ThrottledError is returned when an action is retried too soon, e.g. a
+// verification email resend or a login after failed attempts.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+type EmailVerification struct {
+	users          store.UserStore
+	verifications  store.VerificationStore
+	verifier       *Verifier
+	mailer         mailer.Mailer
+	appURL         string
+	ttl            time.Duration
+	resendInterval time.Duration
+}
+
+func NewEmailVerification(users store.UserStore, verifications store.VerificationStore, m mailer.Mailer,
+	appURL string, ttl, resendInterval time.Duration) *EmailVerification {
+	return &EmailVerification{
+		users:          users,
+		verifications:  verifications,
+		verifier:       NewVerifier(verifications),
+		mailer:         m,
+		appURL:         appURL,
+		ttl:            ttl,
+		resendInterval: resendInterval,
+	}
+}
+
+// Send emails the user a fresh verification link. It refuses with a
+// *ThrottledError if the previous link went out less than the resend
+// interval ago.
+func (e *EmailVerification) Send(ctx context.Context, user *models.User) error {
+	if user.EmailVerified {
+		return ErrAlreadyVerified
+	}
+
+	identifier := PurposeEmailVerification + ":" + user.ID
+	last, err := e.verifications.GetByIdentifier(ctx, identifier)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	if last != nil {
+		if wait := e.resendInterval - time.Since(last.CreatedAt); wait > 0 {
+			return &ThrottledError{RetryAfter: wait}
+		}
+	}
+
+	return e.send(ctx, user)
+}
+
+// ChangeEmail switches the user to a new, unverified email and sends a
+// verification link there right away, ignoring the resend interval. The old
+// address is told about the change, so its owner notices if someone else
+// made it. It returns store.ErrConflict if the email is taken.
+func (e *EmailVerification) ChangeEmail(ctx context.Context, user *models.User, email string) error {
+	if err := e.users.UpdateEmail(ctx, user.ID, email); err != nil {
+		return err
+	}
+
+	oldEmail := user.Email
+	changed := *user
+	changed.Email, changed.EmailVerified = email, false
+	if err := e.send(ctx, &changed); err != nil {
+		return err
+	}
+
+	return e.mailer.Send(ctx, mailer.Message{
+		To:      oldEmail,
+		Subject: "Your YelpCamp email was changed",
+		Body: fmt.Sprintf("Hi %s,\n\nThe email address of your YelpCamp account was changed to %s.\n\n"+
+			"If you didn't make this change, someone else may be using your account; please contact "+
+			"YelpCamp support.\n", user.Username, email),
+	})
+}
+
+// send issues a verification token, replacing any older link, and mails it.
+func (e *EmailVerification) send(ctx context.Context, user *models.User) error {
+	token, err := e.verifier.Issue(ctx, PurposeEmailVerification, user.ID, e.ttl)
+	if err != nil {
+		return err
+	}
+
+	link := e.appURL + "/verify-email?token=" + url.QueryEscape(token)
+	return e.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your YelpCamp email",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below.\n\n%s\n\n"+
+			"If you didn't create a YelpCamp account, you can ignore this email.\n",
+			user.Username, link),
+	})
+}
+
+// Confirm redeems a verification token and marks the user's email as
+// verified.
+func (e *EmailVerification) Confirm(ctx context.Context, token string) (string, error) {
+	userID, err := e.verifier.Consume(ctx, PurposeEmailVerification, token)
+	if err != nil {
+		return "", err
+	}
+	if err := e.users.MarkEmailVerified(ctx, userID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return "", ErrInvalidToken
+		}
+		return "", err
+	}
+	return userID, nil
+}
@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// hmacKeyID is the kid of the JWT_SECRET key used when no asymmetric
+	// keys are configured.
+	hmacKeyID = "hs256"
+
+	minRSAKeyBits = 2048
+)
+
+// SigningKey is one access token key. Keys loaded from a public key file
+// can only verify; they are kept around after a rotation until the tokens
+// they signed have expired.
+type SigningKey struct {
+	ID      string
+	method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+}
+
+// KeySet holds the key new access tokens are signed with and every key
+// tokens are still accepted from, looked up by the token's kid header.
+// Each key only verifies tokens of its own algorithm.
+type KeySet struct {
+	signing *SigningKey
+	keys    map[string]*SigningKey
+	order   []string
+}
+
+// KeyFile names a PEM file holding a key and the kid to publish it under.
+type KeyFile struct {
+	ID   string
+	Path string
+}
+
+// NewHMACKeySet signs and verifies with a single HS256 secret. It isn't
+// published in the JWKS, so only this service can verify its tokens.
+func NewHMACKeySet(secret string) *KeySet {
+	key := &SigningKey{ID: hmacKeyID, method: jwt.SigningMethodHS256, private: []byte(secret), public: []byte(secret)}
+	return &KeySet{signing: key, keys: map[string]*SigningKey{key.ID: key}, order: []string{key.ID}}
+}
+
+// LoadKeySet reads RS256 and EdDSA keys from PEM files. New tokens are
+// signed with signingID, which must name a private key; the others only
+// verify.
+func LoadKeySet(files []KeyFile, signingID string) (*KeySet, error) {
+	set := &KeySet{keys: map[string]*SigningKey{}}
+	for _, f := range files {
+		data, err := os.ReadFile(f.Path)
+		if err != nil {
+			return nil, fmt.Errorf("read JWT key %s: %w", f.ID, err)
+		}
+		key, err := ParseSigningKey(f.ID, data)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := set.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate JWT key ID %q", key.ID)
+		}
+		set.keys[key.ID] = key
+		set.order = append(set.order, key.ID)
+	}
+
+	signing, ok := set.keys[signingID]
+	if !ok {
+		return nil, fmt.Errorf("JWT signing key %q not found", signingID)
+	}
+	if signing.private == nil {
+		return nil, fmt.Errorf("JWT signing key %q is a public key", signingID)
+	}
+	set.signing = signing
+	return set, nil
+}
+
+// ParseSigningKey reads a PKCS#8 or PKCS#1 private key, or a PKIX public
+// key, for RSA or Ed25519.
+func ParseSigningKey(id string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("JWT key %s: no PEM data", id)
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("JWT key %s: unsupported PEM block %q", id, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("JWT key %s: %w", id, err)
+	}
+
+	key := &SigningKey{ID: id}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.method, key.public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.method, key.public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("JWT key %s: only RSA and Ed25519 keys are supported", id)
+	}
+	if pub, ok := key.public.(*rsa.PublicKey); ok && pub.N.BitLen() < minRSAKeyBits {
+		return nil, fmt.Errorf("JWT key %s: RSA keys must be at least %d bits", id, minRSAKeyBits)
+	}
+	return key, nil
+}
+
+// sign signs claims with the signing key and sets the kid header.
+func (s *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.signing.method, claims)
+	token.Header["kid"] = s.signing.ID
+	return token.SignedString(s.signing.private)
+}
+
+// algorithms lists the algorithms of all keys, for pinning in the parser.
+func (s *KeySet) algorithms() []string {
+	var algs []string
+	seen := map[string]bool{}
+	for _, id := range s.order {
+		alg := s.keys[id].method.Alg()
+		if !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+	return algs
+}
+
+// keyfunc picks the verification key by kid and rejects tokens whose
+// algorithm isn't that key's, so e.g. an RSA public key can never be used
+// as an HMAC secret.
+func (s *KeySet) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, errors.New("unknown key ID")
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("unexpected signing algorithm")
+	}
+	return key.public, nil
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys other services can verify our access tokens
+// with. HMAC secrets are never included.
+func (s *KeySet) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, id := range s.order {
+		key := s.keys[id]
+		jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.method.Alg()}
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
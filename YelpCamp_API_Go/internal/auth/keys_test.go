@@ -0,0 +1,301 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+)
+
+var (
+	rsaKeyOnce sync.Once
+	rsaKey     *rsa.PrivateKey
+)
+
+// testRSAKey generates one 2048-bit key for the whole package run.
+func testRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	rsaKeyOnce.Do(func() {
+		var err error
+		if rsaKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			t.Fatal(err)
+		}
+	})
+	return rsaKey
+}
+
+// writePEM marshals key as PKCS#8 or, for public keys, PKIX and writes it
+// to a file in dir.
+func writePEM(t *testing.T, dir, name string, key interface{}) string {
+	t.Helper()
+	var block *pem.Block
+	switch key.(type) {
+	case *rsa.PublicKey, ed25519.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	default:
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+type testKeys struct {
+	rsa     *rsa.PrivateKey
+	ed      ed25519.PrivateKey
+	rsaPath string
+	edPath  string
+	// edPublicPath holds the public half only, as after a rotation
+	edPublicPath string
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	k := testKeys{rsa: testRSAKey(t), ed: priv}
+	k.rsaPath = writePEM(t, dir, "rsa.pem", k.rsa)
+	k.edPath = writePEM(t, dir, "ed.pem", priv)
+	k.edPublicPath = writePEM(t, dir, "ed.pub.pem", pub)
+	return k
+}
+
+func parse(s *KeySet, token string) error {
+	_, err := jwt.Parse(token, s.keyfunc, jwt.WithValidMethods(s.algorithms()))
+	return err
+}
+
+func testClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{Subject: "u1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	k := newTestKeys(t)
+
+	// Before: tokens are signed with the Ed25519 key
+	before, err := LoadKeySet([]KeyFile{{ID: "ed-1", Path: k.edPath}}, "ed-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := before.sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// After: RSA signs, and the old key only verifies until its tokens expire
+	after, err := LoadKeySet([]KeyFile{{ID: "rsa-2", Path: k.rsaPath}, {ID: "ed-1", Path: k.edPublicPath}}, "rsa-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := after.sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, _, _ := jwt.NewParser().ParseUnverified(current, jwt.MapClaims{})
+	if header.Header["kid"] != "rsa-2" || header.Method.Alg() != "RS256" {
+		t.Errorf("signed with kid %v, alg %s", header.Header["kid"], header.Method.Alg())
+	}
+
+	if err := parse(after, old); err != nil {
+		t.Errorf("token from the rotated-out key: %v", err)
+	}
+	if err := parse(after, current); err != nil {
+		t.Errorf("token from the current key: %v", err)
+	}
+	if err := parse(before, current); err == nil {
+		t.Error("old key set accepted a token from a key it doesn't know")
+	}
+	if want := []string{"RS256", "EdDSA"}; !reflect.DeepEqual(after.algorithms(), want) {
+		t.Errorf("algorithms %v, want %v", after.algorithms(), want)
+	}
+}
+
+func TestKeySetPinsAlgorithms(t *testing.T) {
+	k := newTestKeys(t)
+	set, err := LoadKeySet([]KeyFile{{ID: "rsa-1", Path: k.rsaPath}, {ID: "ed-1", Path: k.edPath}}, "rsa-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM, err := os.ReadFile(writePEM(t, t.TempDir(), "rsa.pub.pem", &k.rsa.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forge := func(method jwt.SigningMethod, kid string, key interface{}) string {
+		t.Helper()
+		token := jwt.NewWithClaims(method, testClaims())
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	tests := map[string]string{
+		// The classic confusion attack: the public key used as an HMAC secret
+		"HS256 with the RSA public key": forge(jwt.SigningMethodHS256, "rsa-1", publicPEM),
+		"none":                          forge(jwt.SigningMethodNone, "rsa-1", jwt.UnsafeAllowNoneSignatureType),
+		"EdDSA under the RSA kid":       forge(jwt.SigningMethodEdDSA, "rsa-1", k.ed),
+		"RS256 under the EdDSA kid":     forge(jwt.SigningMethodRS256, "ed-1", k.rsa),
+		"unknown kid":                   forge(jwt.SigningMethodRS256, "rsa-9", k.rsa),
+		"no kid":                        forge(jwt.SigningMethodRS256, "", k.rsa),
+	}
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := parse(set, token); err == nil {
+				t.Error("token was accepted")
+			}
+		})
+	}
+
+	if err := parse(set, forge(jwt.SigningMethodEdDSA, "ed-1", k.ed)); err != nil {
+		t.Errorf("EdDSA token under its own kid: %v", err)
+	}
+}
+
+func TestHMACKeySet(t *testing.T) {
+	set := NewHMACKeySet("test-secret-of-at-least-32-chars")
+	token, err := set.sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parse(set, token); err != nil {
+		t.Fatal(err)
+	}
+	other := NewHMACKeySet("another-secret-of-at-least-32-chars")
+	if err := parse(other, token); err == nil {
+		t.Error("token verified with another secret")
+	}
+	// The secret must never be published
+	if keys := set.JWKS().Keys; len(keys) != 0 {
+		t.Errorf("JWKS published %+v", keys)
+	}
+}
+
+func TestLoadKeySetErrors(t *testing.T) {
+	k := newTestKeys(t)
+	dir := t.TempDir()
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	smallPath := writePEM(t, dir, "small.pem", small)
+	garbage := filepath.Join(dir, "garbage.pem")
+	if err := os.WriteFile(garbage, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		files   []KeyFile
+		signing string
+	}{
+		{"signing key missing", []KeyFile{{ID: "rsa-1", Path: k.rsaPath}}, "rsa-2"},
+		{"signing key is public", []KeyFile{{ID: "ed-1", Path: k.edPublicPath}}, "ed-1"},
+		{"duplicate kid", []KeyFile{{ID: "k", Path: k.rsaPath}, {ID: "k", Path: k.edPath}}, "k"},
+		{"short RSA key", []KeyFile{{ID: "small", Path: smallPath}}, "small"},
+		{"not PEM", []KeyFile{{ID: "bad", Path: garbage}}, "bad"},
+		{"no file", []KeyFile{{ID: "gone", Path: filepath.Join(dir, "gone.pem")}}, "gone"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadKeySet(tt.files, tt.signing); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	k := newTestKeys(t)
+	set, err := LoadKeySet([]KeyFile{{ID: "rsa-1", Path: k.rsaPath}, {ID: "ed-1", Path: k.edPublicPath}}, "rsa-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := set.JWKS().Keys
+	if len(keys) != 2 {
+		t.Fatalf("got %d keys, want 2", len(keys))
+	}
+	rsaJWK, edJWK := keys[0], keys[1]
+	if rsaJWK.KeyType != "RSA" || rsaJWK.KeyID != "rsa-1" || rsaJWK.Algorithm != "RS256" || rsaJWK.Use != "sig" {
+		t.Errorf("RSA key %+v", rsaJWK)
+	}
+	n, _ := base64.RawURLEncoding.DecodeString(rsaJWK.N)
+	e, _ := base64.RawURLEncoding.DecodeString(rsaJWK.E)
+	if new(big.Int).SetBytes(n).Cmp(k.rsa.N) != 0 || new(big.Int).SetBytes(e).Int64() != int64(k.rsa.E) {
+		t.Error("RSA modulus or exponent doesn't round-trip")
+	}
+
+	x, _ := base64.RawURLEncoding.DecodeString(edJWK.X)
+	if edJWK.KeyType != "OKP" || edJWK.Curve != "Ed25519" || edJWK.Algorithm != "EdDSA" ||
+		!ed25519.PublicKey(x).Equal(k.ed.Public()) {
+		t.Errorf("Ed25519 key %+v", edJWK)
+	}
+}
+
+func TestAccessTokenClaims(t *testing.T) {
+	keys := NewHMACKeySet("test-secret-of-at-least-32-chars")
+	tokens := NewTokenService(keys, "yelpcamp-api", "yelpcamp", time.Minute, time.Hour, nil, nil, nil)
+
+	claims := func(issuer, audience string) Claims {
+		return Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    issuer,
+				Subject:   "u1",
+				Audience:  jwt.ClaimStrings{audience},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+			SessionID: "s1",
+			Role:      models.RoleUser,
+		}
+	}
+	tests := []struct {
+		name   string
+		claims Claims
+		valid  bool
+	}{
+		{"ours", claims("yelpcamp-api", "yelpcamp"), true},
+		{"another issuer", claims("someone-else", "yelpcamp"), false},
+		{"another audience", claims("yelpcamp-api", "another-app"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := keys.sign(tt.claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = tokens.parseAccessToken(signed)
+			if (err == nil) != tt.valid {
+				t.Errorf("parse: %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
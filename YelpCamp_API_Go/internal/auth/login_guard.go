@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/background"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/mailer"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// Failures allowed before backoff starts. An IP gets more, since many
+	// users can share one.
+	freeUserFailures = 3
+	freeIPFailures   = 10
+)
+
+// dummyPasswordHash is compared against when there is no real hash to check,
+// so a login for an unknown user costs as much as a wrong password.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("yelpcamp-timing-equalizer"), bcrypt.DefaultCost)
+
+// CheckPassword reports whether password matches the user's hash. It always
+// runs bcrypt, also for a nil user or a user without a password.
+func CheckPassword(user *models.User, password string) bool {
+	if user == nil || user.Password == "" {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) == nil
+}
+
+// LockoutNotifier is told when an account gets locked, e.g. to warn its
+// owner that someone is guessing their password.
+type LockoutNotifier interface {
+	AccountLocked(ctx context.Context, user *models.User, until time.Time) error
+}
+
+type LoginGuardConfig struct {
+	// MaxFailures locks a username after that many failures in a row, and
+	// MaxIPFailures blocks an address after that many across all usernames.
+	MaxFailures   int
+	MaxIPFailures int
+	// LockoutDuration is how long a lock lasts. Failures are forgotten once
+	// none happened for FailureWindow.
+	LockoutDuration time.Duration
+	FailureWindow   time.Duration
+	// BackoffBase is the wait after the first failure past the free ones;
+	// it doubles with each further failure.
+	BackoffBase time.Duration
+}
+
+// LoginGuard tracks failed logins per username and per IP address. Past a
+// few free failures each one doubles the wait before the next attempt, and
+// at the maximum the username or address is locked out for a while.
+// Usernames are tracked whether or not they exist, so the responses don't
+// reveal which do.
+type LoginGuard struct {
+	throttles store.LoginThrottleStore
+	notifier  LockoutNotifier
+	tasks     *background.Runner
+	cfg       LoginGuardConfig
+}
+
+// NewLoginGuard notifies of lockouts on tasks, so the failed login that
+// caused one isn't held up; notifier may be nil.
+func NewLoginGuard(throttles store.LoginThrottleStore, notifier LockoutNotifier, tasks *background.Runner,
+	cfg LoginGuardConfig) *LoginGuard {
+	return &LoginGuard{throttles: throttles, notifier: notifier, tasks: tasks, cfg: cfg}
+}
+
+// Check returns a *ThrottledError if the username or address must wait
+// before trying again.
+func (g *LoginGuard) Check(ctx context.Context, username, ip string) error {
+	var wait time.Duration
+	for _, k := range g.keys(username, ip) {
+		t, err := g.throttles.Get(ctx, k.key)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if w := g.waitFor(t, k.free, time.Now()); w > wait {
+			wait = w
+		}
+	}
+	if wait > 0 {
+		return &ThrottledError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// Fail records a failed login. user is the account the username belongs
+// to, if any; its owner is notified when the username gets locked.
+func (g *LoginGuard) Fail(ctx context.Context, username, ip string, user *models.User) error {
+	now := time.Now()
+	for _, k := range g.keys(username, ip) {
+		t, err := g.throttles.RecordFailure(ctx, k.key, now, now.Add(-g.cfg.FailureWindow))
+		if err != nil {
+			return err
+		}
+		if t.Failures < k.max {
+			continue
+		}
+		until := now.Add(g.cfg.LockoutDuration)
+		if err := g.throttles.Lock(ctx, k.key, until); err != nil {
+			return err
+		}
+		if k.isUser && user != nil && g.notifier != nil {
+			locked := *user
+			g.tasks.Go(func(ctx context.Context) { g.notify(ctx, locked, until) })
+		}
+	}
+	return nil
+}
+
+// Succeed forgets the username's failures. The address keeps its count, so
+// an attacker can't reset it by logging into their own account.
+func (g *LoginGuard) Succeed(ctx context.Context, username string) error {
+	return g.throttles.Delete(ctx, userThrottleKey(username))
+}
+
+// Unlock lifts a lockout and forgets the username's failures.
+func (g *LoginGuard) Unlock(ctx context.Context, username string) error {
+	return g.throttles.Delete(ctx, userThrottleKey(username))
+}
+
+type throttleKey struct {
+	key    string
+	free   int
+	max    int
+	isUser bool
+}
+
+func (g *LoginGuard) keys(username, ip string) []throttleKey {
+	return []throttleKey{
+		{key: userThrottleKey(username), free: freeUserFailures, max: g.cfg.MaxFailures, isUser: true},
+		{key: "ip:" + ip, free: freeIPFailures, max: g.cfg.MaxIPFailures},
+	}
+}
+
+func userThrottleKey(username string) string {
+	return "user:" + username
+}
+
+// waitFor is how long until the next attempt is allowed: the rest of a
+// lockout, or the backoff after the last failure.
+func (g *LoginGuard) waitFor(t *models.LoginThrottle, free int, now time.Time) time.Duration {
+	if t.LockedUntil != nil && now.Before(*t.LockedUntil) {
+		return t.LockedUntil.Sub(now)
+	}
+	if now.Sub(t.LastFailedAt) >= g.cfg.FailureWindow || t.Failures <= free {
+		return 0
+	}
+	backoff := g.cfg.LockoutDuration
+	if exp := t.Failures - free - 1; exp < 30 {
+		backoff = min(g.cfg.BackoffBase<<exp, g.cfg.LockoutDuration)
+	}
+	return max(t.LastFailedAt.Add(backoff).Sub(now), 0)
+}
+
+func (g *LoginGuard) notify(ctx context.Context, user models.User, until time.Time) {
+	if err := g.notifier.AccountLocked(ctx, &user, until); err != nil {
+		log.Printf("login guard: notify %s of lockout: %v", user.ID, err)
+	}
+}
+
+// MailLockoutNotifier emails the account owner about a lockout.
+type MailLockoutNotifier struct {
+	mailer mailer.Mailer
+	appURL string
+}
+
+func NewMailLockoutNotifier(m mailer.Mailer, appURL string) *MailLockoutNotifier {
+	return &MailLockoutNotifier{mailer: m, appURL: appURL}
+}
+
+func (n *MailLockoutNotifier) AccountLocked(ctx context.Context, user *models.User, until time.Time) error {
+	return n.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your YelpCamp account was locked",
+		Body: fmt.Sprintf("Hi %s,\n\nThere were too many failed attempts to log into your account, so logins "+
+			"are blocked for %s.\n\nIf this wasn't you, someone may be guessing your password. Consider "+
+			"resetting it once the lock ends:\n\n%s/forgot-password\n",
+			user.Username, formatTTL(time.Until(until)), n.appURL),
+	})
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/mailer"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+// PasswordReset mails single-use links for choosing a new password.
+type PasswordReset struct {
+	verifier *Verifier
+	mailer   mailer.Mailer
+	appURL   string
+	ttl      time.Duration
+}
+
+func NewPasswordReset(verifications store.VerificationStore, m mailer.Mailer, appURL string, ttl time.Duration) *PasswordReset {
+	return &PasswordReset{
+		verifier: NewVerifier(verifications),
+		mailer:   m,
+		appURL:   appURL,
+		ttl:      ttl,
+	}
+}
+
+// Send emails the user a fresh reset link.
+func (p *PasswordReset) Send(ctx context.Context, user *models.User) error {
+	token, err := p.verifier.Issue(ctx, PurposePasswordReset, user.ID, p.ttl)
+	if err != nil {
+		return err
+	}
+
+	link := p.appURL + "/reset-password?token=" + url.QueryEscape(token)
+	return p.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your YelpCamp password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password. It expires in %s.\n\n%s\n\n"+
+			"If you didn't ask to reset your password, you can ignore this email.\n",
+			user.Username, formatTTL(p.ttl), link),
+	})
+}
+
+// Consume redeems a reset token and returns the user it was issued for.
+func (p *PasswordReset) Consume(ctx context.Context, token string) (string, error) {
+	return p.verifier.Consume(ctx, PurposePasswordReset, token)
+}
+
+// Revoke invalidates any reset link the user still has, e.g. after their
+// password or email changed.
+func (p *PasswordReset) Revoke(ctx context.Context, userID string) error {
+	return p.verifier.Revoke(ctx, PurposePasswordReset, userID)
+}
+
+// formatTTL renders a link lifetime for email copy, e.g. "1 hour".
+func formatTTL(d time.Duration) string {
+	unit, n := "minute", int(d.Round(time.Minute)/time.Minute)
+	if d >= time.Hour && d%time.Hour == 0 {
+		unit, n = "hour", int(d/time.Hour)
+	}
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
@@ -0,0 +1,293 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenReused  = errors.New("refresh token reused")
+	ErrSuspended    = errors.New("account suspended")
+)
+
+// Claims carries the user's role so authorization doesn't need a database
+// round trip; a role change takes effect at the next refresh.
+type Claims struct {
+	jwt.RegisteredClaims
+	SessionID string      `json:"sid"`
+	Role      models.Role `json:"role"`
+}
+
+// Tokens is what a client receives when a session starts or is refreshed.
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+}
+
+// ClientInfo describes where a session was started from.
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+// TokenService manages server-side sessions. Each session hands out
+// short-lived access JWTs bound to it and a family of rotating refresh
+// tokens; deleting the session invalidates both. The session row's token is
+// the hash of its current refresh token.
+type TokenService struct {
+	keys          *KeySet
+	issuer        string
+	audience      string
+	accessTTL     time.Duration
+	refreshTTL    time.Duration
+	users         store.UserStore
+	sessions      store.SessionStore
+	refreshTokens store.RefreshTokenStore
+}
+
+// NewTokenService signs access tokens with keys and stamps them with issuer
+// and audience, which are required when verifying.
+func NewTokenService(keys *KeySet, issuer, audience string, accessTTL, refreshTTL time.Duration,
+	users store.UserStore, sessions store.SessionStore, refreshTokens store.RefreshTokenStore) *TokenService {
+	return &TokenService{
+		keys:          keys,
+		issuer:        issuer,
+		audience:      audience,
+		accessTTL:     accessTTL,
+		refreshTTL:    refreshTTL,
+		users:         users,
+		sessions:      sessions,
+		refreshTokens: refreshTokens,
+	}
+}
+
+func (s *TokenService) AccessTTL() time.Duration  { return s.accessTTL }
+func (s *TokenService) RefreshTTL() time.Duration { return s.refreshTTL }
+
+// StartSession records a new session for the user, e.g. on login, and
+// issues its first token pair. Suspended users get ErrSuspended.
+func (s *TokenService) StartSession(ctx context.Context, user *models.User, client ClientInfo) (*Tokens, error) {
+	if user.SuspendedAt != nil {
+		return nil, ErrSuspended
+	}
+
+	sessionID := uuid.New().String()
+	refreshToken, token, err := s.newRefreshToken(user.ID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &models.Session{
+		ID:        sessionID,
+		UserID:    user.ID,
+		Token:     token.TokenHash,
+		ExpiresAt: token.ExpiresAt,
+		IPAddress: optional(client.IPAddress),
+		UserAgent: optional(client.UserAgent),
+		CreatedAt: token.CreatedAt,
+		UpdatedAt: token.CreatedAt,
+	}
+	if err := s.sessions.Create(ctx, session); err != nil {
+		return nil, err
+	}
+	if err := s.refreshTokens.Create(ctx, token); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.issueAccessToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &Tokens{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+// Authenticate validates an access token and checks that its session is
+// still live.
+func (s *TokenService) Authenticate(ctx context.Context, accessToken string) (*Claims, *models.Session, error) {
+	claims, err := s.parseAccessToken(accessToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	session, err := s.sessions.GetByID(ctx, claims.SessionID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if session.UserID != claims.Subject || time.Now().After(session.ExpiresAt) {
+		return nil, nil, ErrInvalidToken
+	}
+	return claims, session, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair in the same
+// session and extends the session. Presenting a token that was already
+// rotated means it leaked, so the whole session is ended and
+// ErrTokenReused is returned.
+func (s *TokenService) Refresh(ctx context.Context, raw string) (*Tokens, error) {
+	current, err := s.refreshTokens.GetByHash(ctx, HashToken(raw))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if current.RevokedAt != nil {
+		return nil, s.endReusedSession(ctx, current)
+	}
+	if time.Now().After(current.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+
+	session, err := s.sessions.GetByID(ctx, current.FamilyID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.GetByID(ctx, current.UserID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.SuspendedAt != nil {
+		return nil, ErrSuspended
+	}
+
+	refreshToken, token, err := s.newRefreshToken(user.ID, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.refreshTokens.Rotate(ctx, current.ID, token)
+	if errors.Is(err, store.ErrConflict) {
+		// Lost a race with another rotation of the same token
+		return nil, s.endReusedSession(ctx, current)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sessions.Renew(ctx, session.ID, token.TokenHash, token.ExpiresAt); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.issueAccessToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &Tokens{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+// ListSessions returns the user's live sessions with the caller's own
+// session marked as current.
+func (s *TokenService) ListSessions(ctx context.Context, userID, currentID string) ([]models.Session, error) {
+	sessions, err := s.sessions.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+	return sessions, nil
+}
+
+// EndSession revokes one of the user's sessions.
+func (s *TokenService) EndSession(ctx context.Context, userID, sessionID string) error {
+	return s.sessions.Delete(ctx, userID, sessionID)
+}
+
+// EndAllSessions revokes every session of the user except exceptID, which
+// may be empty to sign out everywhere.
+func (s *TokenService) EndAllSessions(ctx context.Context, userID, exceptID string) error {
+	return s.sessions.DeleteByUser(ctx, userID, exceptID)
+}
+
+// EndSessionByRefreshToken ends the session a refresh token belongs to,
+// e.g. on logout with an expired access token. Unknown tokens are ignored.
+func (s *TokenService) EndSessionByRefreshToken(ctx context.Context, raw string) error {
+	current, err := s.refreshTokens.GetByHash(ctx, HashToken(raw))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	err = s.sessions.Delete(ctx, current.UserID, current.FamilyID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (s *TokenService) endReusedSession(ctx context.Context, token *models.RefreshToken) error {
+	err := s.sessions.Delete(ctx, token.UserID, token.FamilyID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	return ErrTokenReused
+}
+
+func (s *TokenService) issueAccessToken(user *models.User, sessionID string) (string, error) {
+	now := time.Now()
+	return s.keys.sign(Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Subject:   user.ID,
+			Audience:  jwt.ClaimStrings{s.audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.accessTTL)),
+		},
+		SessionID: sessionID,
+		Role:      user.Role,
+	})
+}
+
+func (s *TokenService) parseAccessToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, s.keys.keyfunc,
+		jwt.WithValidMethods(s.keys.algorithms()),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithAudience(s.audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || !token.Valid || claims.Subject == "" || claims.SessionID == "" || !claims.Role.Valid() {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+func (s *TokenService) newRefreshToken(userID, sessionID string) (string, *models.RefreshToken, error) {
+	raw, err := GenerateToken()
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now()
+	return raw, &models.RefreshToken{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		FamilyID:  sessionID,
+		TokenHash: HashToken(raw),
+		ExpiresAt: now.Add(s.refreshTTL),
+		CreatedAt: now,
+	}, nil
+}
+
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
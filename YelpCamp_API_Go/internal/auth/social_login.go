@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/oauth"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+	"golang.org/x/oauth2"
+)
+
+// ErrEmailNotVerified is returned for a provider identity that isn't linked
+// yet and whose email the provider hasn't verified; linking or creating an
+// account on an unverified email would let anyone claim someone else's.
+var ErrEmailNotVerified = errors.New("provider email not verified")
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9]`)
+
+// SocialLogin maps provider identities to users.
+type SocialLogin struct {
+	users    store.UserStore
+	accounts store.AccountStore
+	sessions store.SessionStore
+	apiKeys  store.APIKeyStore
+}
+
+func NewSocialLogin(users store.UserStore, accounts store.AccountStore, sessions store.SessionStore,
+	apiKeys store.APIKeyStore) *SocialLogin {
+	return &SocialLogin{users: users, accounts: accounts, sessions: sessions, apiKeys: apiKeys}
+}
+
+// Resolve returns the user for a provider identity. An identity already
+// linked signs in its user; otherwise it is linked to the user with the same
+// verified email, or a new user is created for it.
+func (s *SocialLogin) Resolve(ctx context.Context, providerID string, identity *oauth.Identity,
+	token *oauth2.Token) (*models.User, error) {
+	account, err := s.accounts.GetByProvider(ctx, providerID, identity.Subject)
+	if err == nil {
+		applyProviderToken(account, token)
+		if err := s.accounts.UpdateTokens(ctx, account); err != nil {
+			return nil, err
+		}
+		return s.users.GetByID(ctx, account.UserID)
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, ErrEmailNotVerified
+	}
+	user, err := s.users.GetByEmail(ctx, identity.Email)
+	if errors.Is(err, store.ErrNotFound) {
+		user, err = s.createUser(ctx, identity)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !user.EmailVerified {
+		if err := s.claimUnverifiedUser(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	account = &models.Account{
+		ID:         uuid.New().String(),
+		UserID:     user.ID,
+		ProviderID: providerID,
+		AccountID:  identity.Subject,
+		CreatedAt:  now,
+	}
+	applyProviderToken(account, token)
+	if err := s.accounts.Create(ctx, account); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// claimUnverifiedUser hands an account whose email was never verified to
+// the provider identity, which has proven it owns the email. Whoever
+// registered it may not be the owner, so their password, sessions and API
+// keys go.
+func (s *SocialLogin) claimUnverifiedUser(ctx context.Context, user *models.User) error {
+	if err := s.users.UpdatePassword(ctx, user.ID, ""); err != nil {
+		return err
+	}
+	if err := s.sessions.DeleteByUser(ctx, user.ID, ""); err != nil {
+		return err
+	}
+	if err := s.apiKeys.DeleteByUser(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := s.users.MarkEmailVerified(ctx, user.ID); err != nil {
+		return err
+	}
+	user.EmailVerified = true
+	return nil
+}
+
+// createUser registers a password-less user named after the identity.
+func (s *SocialLogin) createUser(ctx context.Context, identity *oauth.Identity) (*models.User, error) {
+	base := identity.Username
+	if base == "" {
+		base, _, _ = strings.Cut(identity.Email, "@")
+	}
+	base = usernameInvalidChars.ReplaceAllString(base, "")
+	if len(base) < 3 {
+		base = "camper"
+	}
+	if len(base) > 24 {
+		base = base[:24]
+	}
+
+	// Usernames are unique, so retry with a random suffix on collision
+	for attempt := 0; attempt < 5; attempt++ {
+		username := base
+		if attempt > 0 {
+			n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+			if err != nil {
+				return nil, err
+			}
+			username = fmt.Sprintf("%s%d", base, n.Int64())
+		}
+		exists, err := s.users.ExistsByUsernameOrEmail(ctx, username, identity.Email)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			continue
+		}
+
+		now := time.Now()
+		user := &models.User{
+			ID:            uuid.New().String(),
+			Username:      username,
+			Email:         identity.Email,
+			EmailVerified: true,
+			Role:          models.RoleUser,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		err = s.users.Create(ctx, user)
+		if errors.Is(err, store.ErrConflict) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+	return nil, errors.New("could not find a free username")
+}
+
+func applyProviderToken(account *models.Account, token *oauth2.Token) {
+	account.AccessToken = optional(token.AccessToken)
+	account.RefreshToken = optional(token.RefreshToken)
+	account.AccessTokenExpiresAt = nil
+	if !token.Expiry.IsZero() {
+		expiry := token.Expiry
+		account.AccessTokenExpiresAt = &expiry
+	}
+	if scope, ok := token.Extra("scope").(string); ok {
+		account.Scope = optional(scope)
+	}
+	account.UpdatedAt = time.Now()
+}
@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+const (
+	PurposeTwoFactorLogin = "two-factor-login"
+
+	recoveryCodeCount = 10
+)
+
+var (
+	ErrTwoFactorEnabled    = errors.New("two-factor authentication already enabled")
+	ErrTwoFactorNotEnabled = errors.New("two-factor authentication not enabled")
+	ErrInvalidCode         = errors.New("invalid two-factor code")
+)
+
+// TwoFactor manages TOTP enrollment and the second step of login. Login
+// with two-factor on is split in two: the password step gets a short-lived,
+// single-use challenge token, which is then exchanged together with a TOTP
+// or recovery code for a session.
+type TwoFactor struct {
+	twoFactors   store.TwoFactorStore
+	verifier     *Verifier
+	issuer       string
+	challengeTTL time.Duration
+}
+
+func NewTwoFactor(twoFactors store.TwoFactorStore, verifications store.VerificationStore, issuer string,
+	challengeTTL time.Duration) *TwoFactor {
+	return &TwoFactor{
+		twoFactors:   twoFactors,
+		verifier:     NewVerifier(verifications),
+		issuer:       issuer,
+		challengeTTL: challengeTTL,
+	}
+}
+
+func (t *TwoFactor) ChallengeTTL() time.Duration { return t.challengeTTL }
+
+// Enroll generates a new secret for the user. It has no effect on login
+// until Confirm is called with a code from the authenticator app.
+func (t *TwoFactor) Enroll(ctx context.Context, user *models.User) (secret, uri string, err error) {
+	if user.TwoFactorEnabled {
+		return "", "", ErrTwoFactorEnabled
+	}
+	secret, err = GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	err = t.twoFactors.Save(ctx, &models.TwoFactor{
+		UserID:    user.ID,
+		Secret:    secret,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if errors.Is(err, store.ErrConflict) {
+		return "", "", ErrTwoFactorEnabled
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return secret, TOTPURI(t.issuer, user.Username, secret), nil
+}
+
+// Confirm turns two-factor on once the user proves their app produces valid
+// codes, and returns the recovery codes. They are only ever shown here.
+func (t *TwoFactor) Confirm(ctx context.Context, userID, code string) ([]string, error) {
+	tf, err := t.twoFactors.Get(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tf.ConfirmedAt != nil {
+		return nil, ErrTwoFactorEnabled
+	}
+	if err := t.checkTOTP(ctx, tf, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = t.twoFactors.Enable(ctx, userID, hashes)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrTwoFactorEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (t *TwoFactor) Disable(ctx context.Context, userID string) error {
+	return t.twoFactors.Disable(ctx, userID)
+}
+
+// RegenerateRecoveryCodes replaces all of the user's recovery codes.
+func (t *TwoFactor) RegenerateRecoveryCodes(ctx context.Context, user *models.User) ([]string, error) {
+	if !user.TwoFactorEnabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := t.twoFactors.ReplaceRecoveryCodes(ctx, user.ID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// StartChallenge is called once the password checks out and returns the
+// challenge token for the second step.
+func (t *TwoFactor) StartChallenge(ctx context.Context, userID string) (string, error) {
+	return t.verifier.Issue(ctx, PurposeTwoFactorLogin, userID, t.challengeTTL)
+}
+
+// CompleteChallenge redeems a challenge token with a TOTP or recovery code
+// and returns the user to start a session for. The challenge is used up
+// even if the code is wrong, so each password entry allows one guess. With
+// ErrInvalidCode the user is returned too, so the guess can be counted
+// against them.
+func (t *TwoFactor) CompleteChallenge(ctx context.Context, challengeToken, code string) (string, error) {
+	userID, err := t.verifier.Consume(ctx, PurposeTwoFactorLogin, challengeToken)
+	if err != nil {
+		return "", err
+	}
+
+	tf, err := t.twoFactors.Get(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return "", ErrInvalidToken
+	}
+	if err != nil {
+		return "", err
+	}
+	if tf.ConfirmedAt == nil {
+		return "", ErrInvalidToken
+	}
+
+	if isRecoveryCode(code) {
+		err = t.twoFactors.UseRecoveryCode(ctx, userID, HashToken(normalizeRecoveryCode(code)))
+		if errors.Is(err, store.ErrNotFound) {
+			err = ErrInvalidCode
+		}
+	} else {
+		err = t.checkTOTP(ctx, tf, code)
+	}
+	if errors.Is(err, ErrInvalidCode) {
+		return userID, err
+	}
+	if err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+// checkTOTP validates code and burns its time step so it can't be replayed.
+func (t *TwoFactor) checkTOTP(ctx context.Context, tf *models.TwoFactor, code string) error {
+	step, ok := ValidateTOTP(tf.Secret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return ErrInvalidCode
+	}
+	err := t.twoFactors.UseStep(ctx, tf.UserID, step)
+	if errors.Is(err, store.ErrConflict) {
+		return ErrInvalidCode
+	}
+	return err
+}
+
+// Recovery codes look like "k7f2m-q9xd4": ten base32 characters, shown with
+// a dash for readability. They are matched without case or separators.
+const recoveryAlphabet = "abcdefghijklmnopqrstuvwxyz234567"
+
+func generateRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		for j := range b {
+			b[j] = recoveryAlphabet[b[j]&31]
+		}
+		code := string(b[:5]) + "-" + string(b[5:])
+		codes = append(codes, code)
+		hashes = append(hashes, HashToken(normalizeRecoveryCode(code)))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// isRecoveryCode tells recovery codes apart from all-digit TOTP codes.
+func isRecoveryCode(code string) bool {
+	return len(normalizeRecoveryCode(code)) == 10
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+const PurposePasswordReset = "password-reset"
+
+// Verifier issues single-use, expiring tokens stored hashed in the
+// verifications table, keyed by purpose and subject (usually a user ID).
+type Verifier struct {
+	verifications store.VerificationStore
+}
+
+func NewVerifier(verifications store.VerificationStore) *Verifier {
+	return &Verifier{verifications: verifications}
+}
+
+// Issue replaces any outstanding token for the same purpose and subject.
+func (v *Verifier) Issue(ctx context.Context, purpose, subject string, ttl time.Duration) (string, error) {
+	identifier := purpose + ":" + subject
+	if err := v.verifications.DeleteByIdentifier(ctx, identifier); err != nil {
+		return "", err
+	}
+
+	raw, err := GenerateToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	err = v.verifications.Create(ctx, &models.Verification{
+		ID:         uuid.New().String(),
+		Identifier: identifier,
+		Value:      verificationHash(purpose, raw),
+		ExpiresAt:  now.Add(ttl),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	})
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// Consume redeems a token and returns its subject. The purpose is part of
+// the stored hash, so a token can't be redeemed for a different purpose.
+func (v *Verifier) Consume(ctx context.Context, purpose, raw string) (string, error) {
+	verification, err := v.verifications.Consume(ctx, verificationHash(purpose, raw))
+	if errors.Is(err, store.ErrNotFound) {
+		return "", ErrInvalidToken
+	}
+	if err != nil {
+		return "", err
+	}
+
+	subject, ok := strings.CutPrefix(verification.Identifier, purpose+":")
+	if !ok || time.Now().After(verification.ExpiresAt) {
+		return "", ErrInvalidToken
+	}
+	return subject, nil
+}
+
+// Revoke drops any outstanding token for the purpose and subject.
+func (v *Verifier) Revoke(ctx context.Context, purpose, subject string) error {
+	return v.verifications.DeleteByIdentifier(ctx, purpose+":"+subject)
+}
+
+func verificationHash(purpose, raw string) string {
+	return HashToken(purpose + ":" + raw)
+}
@@ -0,0 +1,68 @@
+// Package background runs short jobs, such as sending an email, after the
+// request that started them has been answered, and lets shutdown wait for
+// them instead of cutting them off.
+package background
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Runner tracks the jobs it starts. Each job gets a context that times out,
+// so a stuck mail server can't hold up shutdown forever.
+type Runner struct {
+	timeout time.Duration
+
+	mu      sync.Mutex
+	stopped bool
+	jobs    sync.WaitGroup
+}
+
+func NewRunner(timeout time.Duration) *Runner {
+	return &Runner{timeout: timeout}
+}
+
+// Go runs job in a new goroutine. Once Wait was called, job runs before Go
+// returns instead, since nobody would wait for it any more.
+func (r *Runner) Go(job func(ctx context.Context)) {
+	r.mu.Lock()
+	if r.stopped {
+		r.mu.Unlock()
+		r.run(job)
+		return
+	}
+	r.jobs.Add(1)
+	r.mu.Unlock()
+
+	go func() {
+		defer r.jobs.Done()
+		r.run(job)
+	}()
+}
+
+// Wait blocks until every job started with Go has finished, or returns
+// ctx's error if it is done first.
+func (r *Runner) Wait(ctx context.Context) error {
+	r.mu.Lock()
+	r.stopped = true
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.jobs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Runner) run(job func(ctx context.Context)) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	job(ctx)
+}
@@ -0,0 +1,70 @@
+package background
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunnerWaitsForJobs(t *testing.T) {
+	r := NewRunner(time.Minute)
+	release := make(chan struct{})
+	var finished atomic.Int32
+	for i := 0; i < 3; i++ {
+		r.Go(func(ctx context.Context) {
+			<-release
+			finished.Add(1)
+		})
+	}
+
+	waited := make(chan error, 1)
+	go func() { waited <- r.Wait(context.Background()) }()
+	select {
+	case <-waited:
+		t.Fatal("Wait returned while jobs were running")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	if err := <-waited; err != nil {
+		t.Fatal(err)
+	}
+	if n := finished.Load(); n != 3 {
+		t.Errorf("%d jobs finished, want 3", n)
+	}
+
+	// Nobody waits any more, so later jobs run right away
+	ran := false
+	r.Go(func(ctx context.Context) { ran = true })
+	if !ran {
+		t.Error("job after Wait didn't run before Go returned")
+	}
+}
+
+func TestRunnerWaitGivesUp(t *testing.T) {
+	r := NewRunner(time.Minute)
+	release := make(chan struct{})
+	defer close(release)
+	r.Go(func(ctx context.Context) { <-release })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := r.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait: %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestRunnerTimesJobsOut(t *testing.T) {
+	r := NewRunner(10 * time.Millisecond)
+	r.Go(func(ctx context.Context) {
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Second):
+			t.Error("job context never timed out")
+		}
+	})
+	if err := r.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,720 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+const minJWTSecretLength = 32
+
+type Config struct {
+	Port        string          `yaml:"port" toml:"port"`
+	DatabaseURL string          `yaml:"databaseUrl" toml:"database_url"`
+	JWTSecret   string          `yaml:"jwtSecret" toml:"jwt_secret"`
+	JWT         JWTConfig       `yaml:"jwt" toml:"jwt"`
+	AppURL      string          `yaml:"appUrl" toml:"app_url"`
+	Auth        AuthConfig      `yaml:"auth" toml:"auth"`
+	Mail        MailConfig      `yaml:"mail" toml:"mail"`
+	CORS        CORSConfig      `yaml:"cors" toml:"cors"`
+	Server      ServerConfig    `yaml:"server" toml:"server"`
+	RateLimit   RateLimitConfig `yaml:"rateLimit" toml:"rate_limit"`
+	Geocoder    GeocoderConfig  `yaml:"geocoder" toml:"geocoder"`
+	// DataExportTTL is how long a personal data export can be downloaded.
+	DataExportTTL time.Duration `yaml:"dataExportTtl" toml:"data_export_ttl"`
+	// OAuth lists the OpenID Connect providers offered for social login,
+	// keyed by the name used in /api/auth/oauth/{provider}.
+	OAuth map[string]OAuthProviderConfig `yaml:"oauth" toml:"oauth"`
+}
+
+// JWTConfig selects how access tokens are signed. Without Keys they are
+// HS256 with JWTSecret; with Keys they are RS256 or EdDSA, depending on
+// each key's type, and the public keys are published at
+// /.well-known/jwks.json.
+type JWTConfig struct {
+	Issuer   string `yaml:"issuer" toml:"issuer"`
+	Audience string `yaml:"audience" toml:"audience"`
+	// Keys are PEM files: private keys, or public keys that only verify
+	// tokens signed before a rotation.
+	Keys []JWTKeyConfig `yaml:"keys" toml:"keys"`
+	// SigningKeyID picks the key new tokens are signed with; it defaults to
+	// the first key.
+	SigningKeyID string `yaml:"signingKeyId" toml:"signing_key_id"`
+}
+
+type JWTKeyConfig struct {
+	ID   string `yaml:"id" toml:"id"`
+	File string `yaml:"file" toml:"file"`
+}
+
+type AuthConfig struct {
+	AccessTokenTTL       time.Duration `yaml:"accessTokenTtl" toml:"access_token_ttl"`
+	RefreshTokenTTL      time.Duration `yaml:"refreshTokenTtl" toml:"refresh_token_ttl"`
+	PasswordResetTTL     time.Duration `yaml:"passwordResetTtl" toml:"password_reset_ttl"`
+	EmailVerificationTTL time.Duration `yaml:"emailVerificationTtl" toml:"email_verification_ttl"`
+	// VerificationResendInterval throttles verification email resends.
+	VerificationResendInterval time.Duration `yaml:"verificationResendInterval" toml:"verification_resend_interval"`
+	// RequireVerifiedEmail blocks creating campgrounds and comments until
+	// the author's email is verified.
+	RequireVerifiedEmail bool `yaml:"requireVerifiedEmail" toml:"require_verified_email"`
+	// TwoFactorIssuer is the account label shown in authenticator apps.
+	TwoFactorIssuer string `yaml:"twoFactorIssuer" toml:"two_factor_issuer"`
+	// TwoFactorChallengeTTL is how long the second login step may take.
+	TwoFactorChallengeTTL time.Duration `yaml:"twoFactorChallengeTtl" toml:"two_factor_challenge_ttl"`
+	// Login brute-force protection: a username is locked for
+	// LoginLockoutDuration after LoginMaxFailures failed logins, an address
+	// after LoginMaxIPFailures. Waits between attempts start at
+	// LoginBackoffBase and double; failures are forgotten after
+	// LoginFailureWindow without one.
+	LoginMaxFailures     int           `yaml:"loginMaxFailures" toml:"login_max_failures"`
+	LoginMaxIPFailures   int           `yaml:"loginMaxIpFailures" toml:"login_max_ip_failures"`
+	LoginLockoutDuration time.Duration `yaml:"loginLockoutDuration" toml:"login_lockout_duration"`
+	LoginFailureWindow   time.Duration `yaml:"loginFailureWindow" toml:"login_failure_window"`
+	LoginBackoffBase     time.Duration `yaml:"loginBackoffBase" toml:"login_backoff_base"`
+}
+
+type MailConfig struct {
+	// Driver is "log" (write to Dir, or the log when Dir is empty) or "smtp".
+	Driver       string `yaml:"driver" toml:"driver"`
+	From         string `yaml:"from" toml:"from"`
+	Dir          string `yaml:"dir" toml:"dir"`
+	SMTPHost     string `yaml:"smtpHost" toml:"smtp_host"`
+	SMTPPort     int    `yaml:"smtpPort" toml:"smtp_port"`
+	SMTPUsername string `yaml:"smtpUsername" toml:"smtp_username"`
+	SMTPPassword string `yaml:"smtpPassword" toml:"smtp_password"`
+}
+
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowedOrigins" toml:"allowed_origins"`
+}
+
+type OAuthProviderConfig struct {
+	// Issuer is the OIDC issuer URL; endpoints are discovered from it.
+	Issuer       string `yaml:"issuer" toml:"issuer"`
+	ClientID     string `yaml:"clientId" toml:"client_id"`
+	ClientSecret string `yaml:"clientSecret" toml:"client_secret"`
+	// RedirectURL must point at /api/auth/oauth/{provider}/callback and be
+	// registered with the provider.
+	RedirectURL string   `yaml:"redirectUrl" toml:"redirect_url"`
+	Scopes      []string `yaml:"scopes" toml:"scopes"`
+}
+
+type ServerConfig struct {
+	ReadTimeout       time.Duration `yaml:"readTimeout" toml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout" toml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"writeTimeout" toml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idleTimeout" toml:"idle_timeout"`
+	// ShutdownDelay is how long readiness reports "not ready" before the
+	// listener closes; ShutdownTimeout bounds the drain that follows.
+	ShutdownDelay   time.Duration `yaml:"shutdownDelay" toml:"shutdown_delay"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout" toml:"shutdown_timeout"`
+	// TrustedProxies lists the addresses or CIDR ranges of reverse proxies
+	// whose X-Forwarded-For header is believed when finding the client IP.
+	TrustedProxies []string `yaml:"trustedProxies" toml:"trusted_proxies"`
+}
+
+// RateLimitConfig sets the token bucket rates for credential endpoints
+// (Auth), other requests that change data (Write) and reads (Read).
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+	// Backend is "memory", which limits each replica on its own, or
+	// "postgres", which shares the buckets between replicas.
+	Backend string `yaml:"backend" toml:"backend"`
+	Auth    Rate   `yaml:"auth" toml:"auth"`
+	Write   Rate   `yaml:"write" toml:"write"`
+	Read    Rate   `yaml:"read" toml:"read"`
+}
+
+// GeocoderConfig selects how campground locations are turned into
+// coordinates.
+type GeocoderConfig struct {
+	// Driver is "none", "http" (any JSON search API, see URL and the field
+	// paths) or "fixture" (canned answers from FixtureFile).
+	Driver string `yaml:"driver" toml:"driver"`
+	// URL has {query} where the location goes.
+	URL string `yaml:"url" toml:"url"`
+	// LatField, LngField and AddressField are dot-separated paths into the
+	// response, e.g. "0.lat" for the first result's lat.
+	LatField     string            `yaml:"latField" toml:"lat_field"`
+	LngField     string            `yaml:"lngField" toml:"lng_field"`
+	AddressField string            `yaml:"addressField" toml:"address_field"`
+	Headers      map[string]string `yaml:"headers" toml:"headers"`
+	Timeout      time.Duration     `yaml:"timeout" toml:"timeout"`
+	FixtureFile  string            `yaml:"fixtureFile" toml:"fixture_file"`
+	// CacheTTL and CacheSize bound the in-memory cache of answers.
+	CacheTTL  time.Duration `yaml:"cacheTtl" toml:"cache_ttl"`
+	CacheSize int           `yaml:"cacheSize" toml:"cache_size"`
+}
+
+// Rate allows Limit requests per Period, written like "60/1m".
+type Rate struct {
+	Limit  int
+	Period time.Duration
+}
+
+func (r Rate) String() string {
+	return fmt.Sprintf("%d/%s", r.Limit, r.Period)
+}
+
+func (r Rate) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Rate) UnmarshalText(text []byte) error {
+	limit, period, ok := strings.Cut(string(text), "/")
+	if !ok {
+		return fmt.Errorf("invalid rate %q, expected e.g. 60/1m", text)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(limit))
+	if err != nil {
+		return fmt.Errorf("invalid rate %q: bad limit", text)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(period))
+	if err != nil {
+		return fmt.Errorf("invalid rate %q: bad period", text)
+	}
+	r.Limit, r.Period = n, d
+	return nil
+}
+
+func defaults() *Config {
+	return &Config{
+		Port:   "3004",
+		AppURL: "http://localhost:3000",
+		JWT: JWTConfig{
+			Issuer:   "yelpcamp-api",
+			Audience: "yelpcamp",
+		},
+		Auth: AuthConfig{
+			AccessTokenTTL:             15 * time.Minute,
+			RefreshTokenTTL:            30 * 24 * time.Hour,
+			PasswordResetTTL:           time.Hour,
+			EmailVerificationTTL:       24 * time.Hour,
+			VerificationResendInterval: time.Minute,
+			TwoFactorIssuer:            "YelpCamp",
+			TwoFactorChallengeTTL:      5 * time.Minute,
+			LoginMaxFailures:           10,
+			LoginMaxIPFailures:         100,
+			LoginLockoutDuration:       15 * time.Minute,
+			LoginFailureWindow:         time.Hour,
+			LoginBackoffBase:           time.Second,
+		},
+		Mail: MailConfig{
+			Driver:   "log",
+			From:     "YelpCamp <no-reply@yelpcamp.local>",
+			SMTPPort: 587,
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"http://localhost:3000", "http://localhost:3003"},
+		},
+		Server: ServerConfig{
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: true,
+			Backend: "memory",
+			Auth:    Rate{Limit: 10, Period: time.Minute},
+			Write:   Rate{Limit: 60, Period: time.Minute},
+			Read:    Rate{Limit: 300, Period: time.Minute},
+		},
+		Geocoder: GeocoderConfig{
+			Driver:       "none",
+			LatField:     "0.lat",
+			LngField:     "0.lon",
+			AddressField: "0.display_name",
+			Timeout:      10 * time.Second,
+			CacheTTL:     24 * time.Hour,
+			CacheSize:    1000,
+		},
+		DataExportTTL: 24 * time.Hour,
+	}
+}
+
+// Load builds the configuration from defaults, then the optional file named
+// by CONFIG_FILE (.yaml, .yml or .toml), then .env and the environment, and
+// validates the result.
+func Load() (*Config, error) {
+	cfg, err := load()
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// LoadDatabase is Load for commands that only use the database, such as
+// migrations: it reads the same sources but only requires DATABASE_URL.
+func LoadDatabase() (*Config, error) {
+	cfg, err := load()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.DatabaseURL == "" {
+		return nil, errors.New("DATABASE_URL is required")
+	}
+	return cfg, nil
+}
+
+func load() (*Config, error) {
+	// .env never overrides variables already set in the environment
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("load .env: %w", err)
+	}
+
+	cfg := defaults()
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+	cfg.applyOAuthDefaults()
+	if cfg.JWT.SigningKeyID == "" && len(cfg.JWT.Keys) > 0 {
+		cfg.JWT.SigningKeyID = cfg.JWT.Keys[0].ID
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, c)
+	case ".toml":
+		err = toml.Unmarshal(data, c)
+	default:
+		return fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) loadEnv() error {
+	setString(&c.Port, "PORT")
+	setString(&c.DatabaseURL, "DATABASE_URL")
+	setString(&c.JWTSecret, "JWT_SECRET")
+	setString(&c.AppURL, "APP_URL")
+	setString(&c.JWT.Issuer, "JWT_ISSUER")
+	setString(&c.JWT.Audience, "JWT_AUDIENCE")
+	setString(&c.JWT.SigningKeyID, "JWT_SIGNING_KEY_ID")
+	setList(&c.CORS.AllowedOrigins, "CORS_ALLOWED_ORIGINS")
+	setList(&c.Server.TrustedProxies, "TRUSTED_PROXIES")
+	setString(&c.RateLimit.Backend, "RATE_LIMIT_BACKEND")
+	setString(&c.Auth.TwoFactorIssuer, "TWO_FACTOR_ISSUER")
+	setString(&c.Mail.Driver, "MAIL_DRIVER")
+	setString(&c.Mail.From, "MAIL_FROM")
+	setString(&c.Mail.Dir, "MAIL_DIR")
+	setString(&c.Mail.SMTPHost, "SMTP_HOST")
+	setString(&c.Mail.SMTPUsername, "SMTP_USERNAME")
+	setString(&c.Mail.SMTPPassword, "SMTP_PASSWORD")
+	setString(&c.Geocoder.Driver, "GEOCODER_DRIVER")
+	setString(&c.Geocoder.URL, "GEOCODER_URL")
+	setString(&c.Geocoder.LatField, "GEOCODER_LAT_FIELD")
+	setString(&c.Geocoder.LngField, "GEOCODER_LNG_FIELD")
+	setString(&c.Geocoder.AddressField, "GEOCODER_ADDRESS_FIELD")
+	setString(&c.Geocoder.FixtureFile, "GEOCODER_FIXTURE_FILE")
+	c.loadOAuthEnv()
+
+	return errors.Join(
+		setJWTKeys(&c.JWT.Keys, "JWT_KEYS"),
+		setBool(&c.Auth.RequireVerifiedEmail, "REQUIRE_VERIFIED_EMAIL"),
+		setBool(&c.RateLimit.Enabled, "RATE_LIMIT_ENABLED"),
+		setRate(&c.RateLimit.Auth, "RATE_LIMIT_AUTH"),
+		setRate(&c.RateLimit.Write, "RATE_LIMIT_WRITE"),
+		setRate(&c.RateLimit.Read, "RATE_LIMIT_READ"),
+		setInt(&c.Mail.SMTPPort, "SMTP_PORT"),
+		setHeaders(&c.Geocoder.Headers, "GEOCODER_HEADERS"),
+		setInt(&c.Geocoder.CacheSize, "GEOCODER_CACHE_SIZE"),
+		setDuration(&c.Geocoder.Timeout, "GEOCODER_TIMEOUT"),
+		setDuration(&c.Geocoder.CacheTTL, "GEOCODER_CACHE_TTL"),
+		setInt(&c.Auth.LoginMaxFailures, "LOGIN_MAX_FAILURES"),
+		setInt(&c.Auth.LoginMaxIPFailures, "LOGIN_MAX_IP_FAILURES"),
+		setDuration(&c.Auth.LoginLockoutDuration, "LOGIN_LOCKOUT_DURATION"),
+		setDuration(&c.Auth.LoginFailureWindow, "LOGIN_FAILURE_WINDOW"),
+		setDuration(&c.Auth.LoginBackoffBase, "LOGIN_BACKOFF_BASE"),
+		setDuration(&c.Auth.AccessTokenTTL, "ACCESS_TOKEN_TTL"),
+		setDuration(&c.Auth.RefreshTokenTTL, "REFRESH_TOKEN_TTL"),
+		setDuration(&c.Auth.PasswordResetTTL, "PASSWORD_RESET_TTL"),
+		setDuration(&c.Auth.EmailVerificationTTL, "EMAIL_VERIFICATION_TTL"),
+		setDuration(&c.Auth.VerificationResendInterval, "VERIFICATION_RESEND_INTERVAL"),
+		setDuration(&c.Auth.TwoFactorChallengeTTL, "TWO_FACTOR_CHALLENGE_TTL"),
+		setDuration(&c.DataExportTTL, "DATA_EXPORT_TTL"),
+		setDuration(&c.Server.ReadTimeout, "SERVER_READ_TIMEOUT"),
+		setDuration(&c.Server.ReadHeaderTimeout, "SERVER_READ_HEADER_TIMEOUT"),
+		setDuration(&c.Server.WriteTimeout, "SERVER_WRITE_TIMEOUT"),
+		setDuration(&c.Server.IdleTimeout, "SERVER_IDLE_TIMEOUT"),
+		setDuration(&c.Server.ShutdownDelay, "SHUTDOWN_DELAY"),
+		setDuration(&c.Server.ShutdownTimeout, "SHUTDOWN_TIMEOUT"),
+	)
+}
+
+// Validate reports every problem at once so a bad deploy can be fixed in
+// one pass.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Port == "" {
+		errs = append(errs, errors.New("PORT is required"))
+	}
+	if c.DatabaseURL == "" {
+		errs = append(errs, errors.New("DATABASE_URL is required"))
+	}
+	if c.JWTSecret == "" {
+		errs = append(errs, errors.New("JWT_SECRET is required"))
+	} else if len(c.JWTSecret) < minJWTSecretLength {
+		errs = append(errs, fmt.Errorf("JWT_SECRET must be at least %d characters", minJWTSecretLength))
+	}
+	if u, err := url.Parse(c.AppURL); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, errors.New("APP_URL must be an absolute URL"))
+	}
+	errs = append(errs, c.JWT.validate()...)
+	switch c.Mail.Driver {
+	case "log":
+	case "smtp":
+		if c.Mail.SMTPHost == "" {
+			errs = append(errs, errors.New("SMTP_HOST is required when MAIL_DRIVER is smtp"))
+		}
+		if c.Mail.SMTPPort <= 0 {
+			errs = append(errs, errors.New("SMTP_PORT must be positive"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("MAIL_DRIVER must be log or smtp, got %q", c.Mail.Driver))
+	}
+	if c.Auth.TwoFactorIssuer == "" {
+		errs = append(errs, errors.New("TWO_FACTOR_ISSUER is required"))
+	}
+	if c.Mail.From == "" {
+		errs = append(errs, errors.New("MAIL_FROM is required"))
+	}
+	if len(c.CORS.AllowedOrigins) == 0 {
+		errs = append(errs, errors.New("CORS_ALLOWED_ORIGINS must list at least one origin"))
+	}
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"ACCESS_TOKEN_TTL", c.Auth.AccessTokenTTL},
+		{"REFRESH_TOKEN_TTL", c.Auth.RefreshTokenTTL},
+		{"PASSWORD_RESET_TTL", c.Auth.PasswordResetTTL},
+		{"EMAIL_VERIFICATION_TTL", c.Auth.EmailVerificationTTL},
+		{"TWO_FACTOR_CHALLENGE_TTL", c.Auth.TwoFactorChallengeTTL},
+		{"LOGIN_LOCKOUT_DURATION", c.Auth.LoginLockoutDuration},
+		{"LOGIN_FAILURE_WINDOW", c.Auth.LoginFailureWindow},
+		{"LOGIN_BACKOFF_BASE", c.Auth.LoginBackoffBase},
+		{"SERVER_READ_TIMEOUT", c.Server.ReadTimeout},
+		{"SERVER_READ_HEADER_TIMEOUT", c.Server.ReadHeaderTimeout},
+		{"SERVER_WRITE_TIMEOUT", c.Server.WriteTimeout},
+		{"SERVER_IDLE_TIMEOUT", c.Server.IdleTimeout},
+		{"SHUTDOWN_TIMEOUT", c.Server.ShutdownTimeout},
+		{"DATA_EXPORT_TTL", c.DataExportTTL},
+	} {
+		if d.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", d.name))
+		}
+	}
+	if c.Auth.RefreshTokenTTL > 0 && c.Auth.RefreshTokenTTL <= c.Auth.AccessTokenTTL {
+		errs = append(errs, errors.New("REFRESH_TOKEN_TTL must be longer than ACCESS_TOKEN_TTL"))
+	}
+	if c.Auth.LoginMaxFailures <= 0 {
+		errs = append(errs, errors.New("LOGIN_MAX_FAILURES must be positive"))
+	}
+	if c.Auth.LoginMaxIPFailures <= 0 {
+		errs = append(errs, errors.New("LOGIN_MAX_IP_FAILURES must be positive"))
+	}
+	if c.Auth.VerificationResendInterval < 0 {
+		errs = append(errs, errors.New("VERIFICATION_RESEND_INTERVAL must not be negative"))
+	}
+	switch c.RateLimit.Backend {
+	case "memory", "postgres":
+	default:
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_BACKEND must be memory or postgres, got %q", c.RateLimit.Backend))
+	}
+	for _, r := range []struct {
+		name string
+		rate Rate
+	}{
+		{"RATE_LIMIT_AUTH", c.RateLimit.Auth},
+		{"RATE_LIMIT_WRITE", c.RateLimit.Write},
+		{"RATE_LIMIT_READ", c.RateLimit.Read},
+	} {
+		if r.rate.Limit <= 0 || r.rate.Period <= 0 {
+			errs = append(errs, fmt.Errorf("%s must have a positive limit and period", r.name))
+		}
+	}
+	errs = append(errs, c.Geocoder.validate()...)
+	for _, p := range c.Server.TrustedProxies {
+		if _, err := ParseNetwork(p); err != nil {
+			errs = append(errs, fmt.Errorf("TRUSTED_PROXIES: %w", err))
+		}
+	}
+	if c.Server.ShutdownDelay < 0 {
+		errs = append(errs, errors.New("SHUTDOWN_DELAY must not be negative"))
+	}
+	for _, name := range sortedKeys(c.OAuth) {
+		errs = append(errs, c.OAuth[name].validate(name)...)
+	}
+	return errors.Join(errs...)
+}
+
+// loadOAuthEnv reads the providers named in OAUTH_PROVIDERS, e.g.
+// "google,mock", from OAUTH_GOOGLE_ISSUER, OAUTH_GOOGLE_CLIENT_ID and so on.
+// Variables override the same provider's settings from the config file.
+func (c *Config) loadOAuthEnv() {
+	var names []string
+	setList(&names, "OAUTH_PROVIDERS")
+	for _, name := range names {
+		if c.OAuth == nil {
+			c.OAuth = map[string]OAuthProviderConfig{}
+		}
+		p := c.OAuth[name]
+		prefix := "OAUTH_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		setString(&p.Issuer, prefix+"ISSUER")
+		setString(&p.ClientID, prefix+"CLIENT_ID")
+		setString(&p.ClientSecret, prefix+"CLIENT_SECRET")
+		setString(&p.RedirectURL, prefix+"REDIRECT_URL")
+		setList(&p.Scopes, prefix+"SCOPES")
+		c.OAuth[name] = p
+	}
+}
+
+func (c *Config) applyOAuthDefaults() {
+	for name, p := range c.OAuth {
+		if len(p.Scopes) == 0 {
+			p.Scopes = []string{"openid", "email", "profile"}
+			c.OAuth[name] = p
+		}
+	}
+}
+
+func (j JWTConfig) validate() []error {
+	var errs []error
+	if j.Issuer == "" {
+		errs = append(errs, errors.New("JWT_ISSUER is required"))
+	}
+	if j.Audience == "" {
+		errs = append(errs, errors.New("JWT_AUDIENCE is required"))
+	}
+	ids := map[string]bool{}
+	for _, k := range j.Keys {
+		if k.ID == "" || k.File == "" {
+			errs = append(errs, errors.New("JWT_KEYS entries need both a key ID and a file"))
+		} else if ids[k.ID] {
+			errs = append(errs, fmt.Errorf("JWT_KEYS: duplicate key ID %q", k.ID))
+		}
+		ids[k.ID] = true
+	}
+	if len(j.Keys) > 0 && !ids[j.SigningKeyID] {
+		errs = append(errs, fmt.Errorf("JWT_SIGNING_KEY_ID %q is not one of JWT_KEYS", j.SigningKeyID))
+	}
+	return errs
+}
+
+var providerNamePattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+func (g GeocoderConfig) validate() []error {
+	var errs []error
+	switch g.Driver {
+	case "none":
+		return nil
+	case "http":
+		if u, err := url.Parse(g.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, errors.New("GEOCODER_URL must be an absolute URL"))
+		} else if !strings.Contains(g.URL, "{query}") {
+			errs = append(errs, errors.New("GEOCODER_URL must contain {query}"))
+		}
+		if g.LatField == "" || g.LngField == "" {
+			errs = append(errs, errors.New("GEOCODER_LAT_FIELD and GEOCODER_LNG_FIELD are required"))
+		}
+		if g.Timeout <= 0 {
+			errs = append(errs, errors.New("GEOCODER_TIMEOUT must be positive"))
+		}
+	case "fixture":
+		if g.FixtureFile == "" {
+			errs = append(errs, errors.New("GEOCODER_FIXTURE_FILE is required when GEOCODER_DRIVER is fixture"))
+		}
+	default:
+		return []error{fmt.Errorf("GEOCODER_DRIVER must be none, http or fixture, got %q", g.Driver)}
+	}
+	if g.CacheTTL <= 0 {
+		errs = append(errs, errors.New("GEOCODER_CACHE_TTL must be positive"))
+	}
+	if g.CacheSize <= 0 {
+		errs = append(errs, errors.New("GEOCODER_CACHE_SIZE must be positive"))
+	}
+	return errs
+}
+
+func (p OAuthProviderConfig) validate(name string) []error {
+	var errs []error
+	if !providerNamePattern.MatchString(name) {
+		errs = append(errs, fmt.Errorf("OAuth provider name %q must be lowercase letters, digits and dashes", name))
+	}
+	if u, err := url.Parse(p.Issuer); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("OAuth provider %s: issuer must be an absolute URL", name))
+	}
+	if p.ClientID == "" {
+		errs = append(errs, fmt.Errorf("OAuth provider %s: client ID is required", name))
+	}
+	if u, err := url.Parse(p.RedirectURL); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("OAuth provider %s: redirect URL must be an absolute URL", name))
+	}
+	return errs
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func setString(dst *string, key string) {
+	if value, ok := os.LookupEnv(key); ok {
+		*dst = value
+	}
+}
+
+func setList(dst *[]string, key string) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return
+	}
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	*dst = list
+}
+
+// setJWTKeys parses "id=path" pairs, e.g. "2024-06=keys/a.pem,2024-01=keys/b.pub".
+func setJWTKeys(dst *[]JWTKeyConfig, key string) error {
+	var items []string
+	setList(&items, key)
+	if items == nil {
+		return nil
+	}
+	keys := []JWTKeyConfig{}
+	for _, item := range items {
+		id, file, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("%s: expected id=path, got %q", key, item)
+		}
+		keys = append(keys, JWTKeyConfig{ID: strings.TrimSpace(id), File: strings.TrimSpace(file)})
+	}
+	*dst = keys
+	return nil
+}
+
+// setHeaders parses "Name=value" pairs, e.g. "X-Api-Key=abc,Accept-Language=en".
+func setHeaders(dst *map[string]string, key string) error {
+	var items []string
+	setList(&items, key)
+	if items == nil {
+		return nil
+	}
+	headers := map[string]string{}
+	for _, item := range items {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("%s: expected name=value, got %q", key, item)
+		}
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	*dst = headers
+	return nil
+}
+
+func setRate(dst *Rate, key string) error {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return nil
+	}
+	if err := dst.UnmarshalText([]byte(value)); err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	return nil
+}
+
+// ParseNetwork reads a CIDR range, or a single address as a range of one.
+func ParseNetwork(s string) (*net.IPNet, error) {
+	if _, network, err := net.ParseCIDR(s); err == nil {
+		return network, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid address or CIDR range %q", s)
+	}
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 8*net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+func setBool(dst *bool, key string) error {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("%s: invalid boolean %q", key, value)
+	}
+	*dst = b
+	return nil
+}
+
+func setInt(dst *int, key string) error {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%s: invalid number %q", key, value)
+	}
+	*dst = n
+	return nil
+}
+
+func setDuration(dst *time.Duration, key string) error {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%s: invalid duration %q", key, value)
+	}
+	*dst = d
+	return nil
+}
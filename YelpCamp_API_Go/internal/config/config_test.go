@@ -0,0 +1,129 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// valid returns the defaults plus the settings that have none.
+func valid() *Config {
+	cfg := defaults()
+	cfg.DatabaseURL = "postgres://localhost/yelpcamp"
+	cfg.JWTSecret = strings.Repeat("s", minJWTSecretLength)
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("valid config: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   []string
+	}{
+		{"no database", func(c *Config) { c.DatabaseURL = "" }, []string{"DATABASE_URL is required"}},
+		{"no secret", func(c *Config) { c.JWTSecret = "" }, []string{"JWT_SECRET is required"}},
+		{"short secret", func(c *Config) { c.JWTSecret = "too-short" }, []string{"JWT_SECRET must be at least 32"}},
+		{"no origins", func(c *Config) { c.CORS.AllowedOrigins = nil }, []string{"CORS_ALLOWED_ORIGINS"}},
+		{"zero timeout", func(c *Config) { c.Server.WriteTimeout = 0 }, []string{"SERVER_WRITE_TIMEOUT must be positive"}},
+		{"negative delay", func(c *Config) { c.Server.ShutdownDelay = -time.Second }, []string{"SHUTDOWN_DELAY"}},
+		{"every problem at once", func(c *Config) {
+			c.Port = ""
+			c.JWTSecret = ""
+			c.Server.IdleTimeout = -time.Second
+		}, []string{"PORT is required", "JWT_SECRET is required", "SERVER_IDLE_TIMEOUT must be positive"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	yaml := "port: \"4000\"\ndatabaseUrl: postgres://file/yelpcamp\n" +
+		"cors:\n  allowedOrigins: [\"https://file.example\"]\nserver:\n  readTimeout: 3s\n"
+	if err := os.WriteFile(file, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Run where there's no .env to pick up
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("PORT", "") // restored after the test, unset for it
+	t.Setenv("JWT_SECRET", strings.Repeat("e", minJWTSecretLength))
+	t.Setenv("DATABASE_URL", "postgres://env/yelpcamp")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://a.example, https://b.example,")
+	t.Setenv("SERVER_WRITE_TIMEOUT", "45s")
+	os.Unsetenv("PORT")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The environment wins over the file, which wins over the defaults
+	if cfg.Port != "4000" || cfg.DatabaseURL != "postgres://env/yelpcamp" {
+		t.Errorf("port %q, database %q", cfg.Port, cfg.DatabaseURL)
+	}
+	if want := []string{"https://a.example", "https://b.example"}; !reflect.DeepEqual(cfg.CORS.AllowedOrigins, want) {
+		t.Errorf("origins %q, want %q", cfg.CORS.AllowedOrigins, want)
+	}
+	if cfg.Server.ReadTimeout != 3*time.Second || cfg.Server.WriteTimeout != 45*time.Second ||
+		cfg.Server.IdleTimeout != 120*time.Second {
+		t.Errorf("server %+v", cfg.Server)
+	}
+
+	t.Setenv("SERVER_WRITE_TIMEOUT", "soon")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "SERVER_WRITE_TIMEOUT") {
+		t.Errorf("bad duration: %v", err)
+	}
+}
+
+func TestLoadDatabase(t *testing.T) {
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("JWT_SECRET", "") // too short for the server, fine for migrations
+	t.Setenv("DATABASE_URL", "postgres://env/yelpcamp")
+
+	if _, err := Load(); err == nil {
+		t.Error("Load accepted a config without a JWT secret")
+	}
+	cfg, err := LoadDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DatabaseURL != "postgres://env/yelpcamp" {
+		t.Errorf("database %q", cfg.DatabaseURL)
+	}
+
+	t.Setenv("DATABASE_URL", "")
+	if _, err := LoadDatabase(); err == nil {
+		t.Error("LoadDatabase accepted a config without a database")
+	}
+}
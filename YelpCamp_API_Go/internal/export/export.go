@@ -0,0 +1,298 @@
+// Package export builds archives of everything stored about a user, for
+// answering data access requests. Archives are built by a background worker
+// and kept for a limited time.
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+const (
+	// pollInterval is how often the worker looks for exports requested on
+	// other replicas; ones requested here start right away.
+	pollInterval = 30 * time.Second
+	// staleAfter is how long a running export may take before another
+	// worker assumes its worker died and starts over.
+	staleAfter = 15 * time.Minute
+	// pageSize is how many campgrounds or comments are loaded at a time.
+	pageSize = 500
+)
+
+var ErrNotReady = errors.New("export is not ready")
+
+type Exporter struct {
+	users       store.UserStore
+	campgrounds store.CampgroundStore
+	comments    store.CommentStore
+	sessions    store.SessionStore
+	exports     store.DataExportStore
+	ttl         time.Duration
+	wake        chan struct{}
+}
+
+// NewExporter keeps finished archives for ttl.
+func NewExporter(stores *store.Stores, ttl time.Duration) *Exporter {
+	return &Exporter{
+		users:       stores.Users,
+		campgrounds: stores.Campgrounds,
+		comments:    stores.Comments,
+		sessions:    stores.Sessions,
+		exports:     stores.DataExports,
+		ttl:         ttl,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Request queues an export for the user. If one is already pending or
+// running, that one is returned instead.
+func (e *Exporter) Request(ctx context.Context, userID string) (*models.DataExport, error) {
+	active, err := e.exports.GetActive(ctx, userID)
+	if err == nil {
+		return active, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+
+	export := &models.DataExport{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Status:    models.ExportPending,
+		CreatedAt: time.Now(),
+	}
+	if err := e.exports.Create(ctx, export); err != nil {
+		return nil, err
+	}
+
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+	return export, nil
+}
+
+func (e *Exporter) Get(ctx context.Context, userID, id string) (*models.DataExport, error) {
+	return e.exports.Get(ctx, userID, id)
+}
+
+// Archive returns the zip archive of a ready export, or ErrNotReady.
+func (e *Exporter) Archive(ctx context.Context, userID, id string) (*models.DataExport, []byte, error) {
+	export, err := e.exports.Get(ctx, userID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if export.Status != models.ExportReady || export.ExpiresAt.Before(time.Now()) {
+		return nil, nil, ErrNotReady
+	}
+	archive, err := e.exports.Archive(ctx, userID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return export, archive, nil
+}
+
+// Run builds queued exports until ctx is done. It also deletes expired
+// archives. Every replica may run a worker.
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		e.work(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-e.wake:
+		}
+	}
+}
+
+// work processes exports until none are left.
+func (e *Exporter) work(ctx context.Context) {
+	if err := e.exports.DeleteExpired(ctx, time.Now()); err != nil && ctx.Err() == nil {
+		log.Printf("data export: delete expired: %v", err)
+	}
+	for ctx.Err() == nil {
+		now := time.Now()
+		export, err := e.exports.Claim(ctx, now, now.Add(-staleAfter))
+		if errors.Is(err, store.ErrNotFound) {
+			return
+		}
+		if err != nil {
+			log.Printf("data export: claim: %v", err)
+			return
+		}
+		e.process(ctx, export)
+	}
+}
+
+func (e *Exporter) process(ctx context.Context, export *models.DataExport) {
+	archive, err := e.build(ctx, export.UserID)
+	if err != nil {
+		if ctx.Err() != nil {
+			// Shutting down; another worker takes it over once it's stale
+			return
+		}
+		log.Printf("data export %s: %v", export.ID, err)
+		if err := e.exports.Fail(ctx, export.ID, "Failed to build the export", time.Now()); err != nil {
+			log.Printf("data export %s: mark failed: %v", export.ID, err)
+		}
+		return
+	}
+
+	now := time.Now()
+	if err := e.exports.Complete(ctx, export.ID, archive, now, now.Add(e.ttl)); err != nil {
+		log.Printf("data export %s: save archive: %v", export.ID, err)
+	}
+}
+
+// data is the export.json document; the CSV files hold the same records.
+type data struct {
+	ExportedAt  time.Time           `json:"exportedAt"`
+	Profile     *models.User        `json:"profile"`
+	Campgrounds []models.Campground `json:"campgrounds"`
+	Comments    []models.Comment    `json:"comments"`
+	Sessions    []models.Session    `json:"sessions"`
+}
+
+// build collects the user's data and writes it as a zip archive holding
+// export.json and one CSV file per kind of record.
+func (e *Exporter) build(ctx context.Context, userID string) ([]byte, error) {
+	var d data
+	var err error
+	d.ExportedAt = time.Now()
+	if d.Profile, err = e.users.GetByID(ctx, userID); err != nil {
+		return nil, fmt.Errorf("load profile: %w", err)
+	}
+	d.Campgrounds, err = loadAll(func(offset int) ([]models.Campground, int, error) {
+		return e.campgrounds.List(ctx, store.CampgroundFilter{AuthorID: userID, Limit: pageSize, Offset: offset})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load campgrounds: %w", err)
+	}
+	d.Comments, err = loadAll(func(offset int) ([]models.Comment, int, error) {
+		return e.comments.List(ctx, store.CommentFilter{AuthorID: userID, Limit: pageSize, Offset: offset})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load comments: %w", err)
+	}
+	if d.Sessions, err = e.sessions.ListByUser(ctx, userID); err != nil {
+		return nil, fmt.Errorf("load sessions: %w", err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, err := zw.Create("export.json")
+	if err != nil {
+		return nil, err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(d); err != nil {
+		return nil, err
+	}
+
+	p := d.Profile
+	files := []struct {
+		name string
+		rows [][]string
+	}{
+		{"profile.csv", [][]string{
+			{"id", "username", "email", "email_verified", "two_factor_enabled", "role", "created_at", "updated_at"},
+			{p.ID, p.Username, p.Email, strconv.FormatBool(p.EmailVerified), strconv.FormatBool(p.TwoFactorEnabled),
+				string(p.Role), formatTime(p.CreatedAt), formatTime(p.UpdatedAt)},
+		}},
+		{"campgrounds.csv", campgroundRows(d.Campgrounds)},
+		{"comments.csv", commentRows(d.Comments)},
+		{"sessions.csv", sessionRows(d.Sessions)},
+	}
+	for _, file := range files {
+		f, err := zw.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+		if err := csv.NewWriter(f).WriteAll(file.rows); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// loadAll calls list with increasing offsets until it has every record.
+func loadAll[T any](list func(offset int) ([]T, int, error)) ([]T, error) {
+	all := []T{}
+	for {
+		page, total, err := list(len(all))
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if len(page) == 0 || len(all) >= total {
+			return all, nil
+		}
+	}
+}
+
+func campgroundRows(campgrounds []models.Campground) [][]string {
+	rows := [][]string{{"id", "name", "price_amount", "price_currency", "price_unit", "image", "description",
+		"location", "address", "latitude", "longitude", "created_at", "updated_at"}}
+	for _, c := range campgrounds {
+		rows = append(rows, []string{strconv.Itoa(c.ID), c.Name, strconv.FormatInt(c.Price.Amount, 10),
+			c.Price.Currency, string(c.Price.Unit), c.Image, c.Description, optional(c.Location),
+			optional(c.Address), optionalFloat(c.Latitude), optionalFloat(c.Longitude),
+			formatTime(c.CreatedAt), formatTime(c.UpdatedAt)})
+	}
+	return rows
+}
+
+func commentRows(comments []models.Comment) [][]string {
+	rows := [][]string{{"id", "campground_id", "text", "created_at", "updated_at"}}
+	for _, c := range comments {
+		rows = append(rows, []string{strconv.Itoa(c.ID), strconv.Itoa(c.CampgroundID), c.Text,
+			formatTime(c.CreatedAt), formatTime(c.UpdatedAt)})
+	}
+	return rows
+}
+
+func sessionRows(sessions []models.Session) [][]string {
+	rows := [][]string{{"id", "ip_address", "user_agent", "created_at", "expires_at"}}
+	for _, s := range sessions {
+		rows = append(rows, []string{s.ID, optional(s.IPAddress), optional(s.UserAgent),
+			formatTime(s.CreatedAt), formatTime(s.ExpiresAt)})
+	}
+	return rows
+}
+
+func optional(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func optionalFloat(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f, 'f', -1, 64)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
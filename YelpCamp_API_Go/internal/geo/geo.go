@@ -0,0 +1,112 @@
+// Package geo holds the coordinate types used to place and search
+// campgrounds.
+package geo
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// EarthRadiusKm is the mean radius used for great-circle distances.
+const EarthRadiusKm = 6371.0
+
+var (
+	errPointFormat = errors.New("must be latitude,longitude")
+	errBoxFormat   = errors.New("must be minLng,minLat,maxLng,maxLat")
+	errLatitude    = errors.New("latitude must be between -90 and 90")
+	errLongitude   = errors.New("longitude must be between -180 and 180")
+)
+
+type Point struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// BoundingBox is an area between two latitudes and two longitudes. If MinLng
+// is greater than MaxLng the box crosses the antimeridian.
+type BoundingBox struct {
+	MinLat, MinLng float64
+	MaxLat, MaxLng float64
+}
+
+// Distance is the great-circle distance between a and b in kilometres,
+// using the haversine formula.
+func Distance(a, b Point) float64 {
+	dLat := radians(b.Latitude - a.Latitude)
+	dLng := radians(b.Longitude - a.Longitude)
+	h := math.Pow(math.Sin(dLat/2), 2) +
+		math.Cos(radians(a.Latitude))*math.Cos(radians(b.Latitude))*math.Pow(math.Sin(dLng/2), 2)
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+func (b BoundingBox) Contains(p Point) bool {
+	if p.Latitude < b.MinLat || p.Latitude > b.MaxLat {
+		return false
+	}
+	if b.MinLng <= b.MaxLng {
+		return p.Longitude >= b.MinLng && p.Longitude <= b.MaxLng
+	}
+	return p.Longitude >= b.MinLng || p.Longitude <= b.MaxLng
+}
+
+// ParsePoint parses "lat,lng".
+func ParsePoint(s string) (Point, error) {
+	v, err := parseFloats(s, 2)
+	if err != nil {
+		return Point{}, errPointFormat
+	}
+	p := Point{Latitude: v[0], Longitude: v[1]}
+	return p, p.Validate()
+}
+
+// ParseBoundingBox parses "minLng,minLat,maxLng,maxLat", the order GeoJSON
+// uses.
+func ParseBoundingBox(s string) (BoundingBox, error) {
+	v, err := parseFloats(s, 4)
+	if err != nil {
+		return BoundingBox{}, errBoxFormat
+	}
+	b := BoundingBox{MinLng: v[0], MinLat: v[1], MaxLng: v[2], MaxLat: v[3]}
+	if b.MinLat > b.MaxLat {
+		return BoundingBox{}, errors.New("minLat must not be greater than maxLat")
+	}
+	for _, p := range []Point{{b.MinLat, b.MinLng}, {b.MaxLat, b.MaxLng}} {
+		if err := p.Validate(); err != nil {
+			return BoundingBox{}, err
+		}
+	}
+	return b, nil
+}
+
+// Validate checks the point is within the valid latitude and longitude ranges.
+func (p Point) Validate() error {
+	if p.Latitude < -90 || p.Latitude > 90 {
+		return errLatitude
+	}
+	if p.Longitude < -180 || p.Longitude > 180 {
+		return errLongitude
+	}
+	return nil
+}
+
+func parseFloats(s string, n int) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != n {
+		return nil, errPointFormat
+	}
+	v := make([]float64, n)
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, errPointFormat
+		}
+		v[i] = f
+	}
+	return v, nil
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
@@ -0,0 +1,88 @@
+package geocode
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Cache remembers answers, including ErrNoResult, for ttl so the same
+// location isn't looked up again. It holds at most size entries and drops
+// the least recently used first. Other errors aren't cached.
+type Cache struct {
+	geocoder Geocoder
+	ttl      time.Duration
+	size     int
+
+	mu      sync.Mutex
+	order   *list.List // of *cacheEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	query   string
+	result  *Result
+	expires time.Time
+}
+
+func NewCache(geocoder Geocoder, ttl time.Duration, size int) *Cache {
+	return &Cache{
+		geocoder: geocoder,
+		ttl:      ttl,
+		size:     size,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func (c *Cache) Geocode(ctx context.Context, query string) (*Result, error) {
+	key := normalize(query)
+	if result, ok := c.get(key); ok {
+		if result == nil {
+			return nil, ErrNoResult
+		}
+		return result, nil
+	}
+
+	result, err := c.geocoder.Geocode(ctx, query)
+	if err != nil && !errors.Is(err, ErrNoResult) {
+		return nil, err
+	}
+	c.put(key, result)
+	return result, err
+}
+
+func (c *Cache) get(key string) (*Result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry.result, true
+}
+
+func (c *Cache) put(key string, result *Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{query: key, result: result, expires: time.Now().Add(c.ttl)})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).query)
+	}
+}
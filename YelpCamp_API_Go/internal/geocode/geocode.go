@@ -0,0 +1,44 @@
+// Package geocode turns the free-text locations authors type into
+// coordinates and a normalized address.
+package geocode
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/geo"
+)
+
+// ErrNoResult means the provider doesn't know the location.
+var ErrNoResult = errors.New("geocode: no result")
+
+type Result struct {
+	Point geo.Point
+	// Address is the provider's normalized form of the query.
+	Address string
+}
+
+// Geocoder resolves a location. HTTPGeocoder asks a provider's API and
+// FixtureGeocoder answers from a file for development and offline tests.
+type Geocoder interface {
+	Geocode(ctx context.Context, query string) (*Result, error)
+}
+
+// TemporaryError wraps failures worth retrying, like timeouts and 5xx or
+// 429 responses.
+type TemporaryError struct {
+	Err error
+}
+
+func (e *TemporaryError) Error() string {
+	return "geocode: temporary failure: " + e.Err.Error()
+}
+
+func (e *TemporaryError) Unwrap() error {
+	return e.Err
+}
+
+func isTemporary(err error) bool {
+	var temp *TemporaryError
+	return errors.As(err, &temp)
+}
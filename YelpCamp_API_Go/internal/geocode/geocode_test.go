@@ -0,0 +1,262 @@
+package geocode
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/geo"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store/memory"
+)
+
+var yosemite = Fixture{Latitude: 37.7456, Longitude: -119.5936, Address: "Yosemite Valley, CA, USA"}
+
+func TestFixtureGeocoder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	data := `{"Yosemite Valley": {"latitude": 37.7456, "longitude": -119.5936, "address": "Yosemite Valley, CA, USA"}}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	g, err := LoadFixtureGeocoder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := g.Geocode(context.Background(), "  yosemite   VALLEY ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Result{Point: geo.Point{Latitude: 37.7456, Longitude: -119.5936}, Address: "Yosemite Valley, CA, USA"}
+	if *result != want {
+		t.Errorf("got %+v, want %+v", *result, want)
+	}
+	if _, err := g.Geocode(context.Background(), "Atlantis"); !errors.Is(err, ErrNoResult) {
+		t.Errorf("unknown location: %v, want ErrNoResult", err)
+	}
+
+	if err := os.WriteFile(path, []byte("not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFixtureGeocoder(path); err == nil {
+		t.Error("loaded a broken fixture file")
+	}
+}
+
+// scripted fails with its errors in turn, then answers from the fixtures.
+// It counts calls per normalized query.
+type scripted struct {
+	fixtures *FixtureGeocoder
+
+	mu    sync.Mutex
+	errs  []error
+	calls map[string]int
+}
+
+func newScripted(errs ...error) *scripted {
+	return &scripted{
+		fixtures: NewFixtureGeocoder(map[string]Fixture{"Yosemite Valley": yosemite}),
+		errs:     errs,
+		calls:    map[string]int{},
+	}
+}
+
+func (s *scripted) Geocode(ctx context.Context, query string) (*Result, error) {
+	s.mu.Lock()
+	s.calls[normalize(query)]++
+	var err error
+	if len(s.errs) > 0 {
+		err, s.errs = s.errs[0], s.errs[1:]
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return s.fixtures.Geocode(ctx, query)
+}
+
+func (s *scripted) callsFor(query string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[normalize(query)]
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	temporary := &TemporaryError{Err: errors.New("503 Service Unavailable")}
+	inner := newScripted(temporary)
+	cache := NewCache(inner, time.Hour, 2)
+
+	// Temporary failures aren't cached
+	if _, err := cache.Geocode(ctx, "Yosemite Valley"); !errors.Is(err, temporary) {
+		t.Fatalf("first lookup: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if result, err := cache.Geocode(ctx, "yosemite valley"); err != nil || result.Address != yosemite.Address {
+			t.Fatalf("lookup %d: %+v, %v", i, result, err)
+		}
+	}
+	if calls := inner.callsFor("Yosemite Valley"); calls != 2 {
+		t.Errorf("provider asked %d times, want 2", calls)
+	}
+
+	// Nor is a missing location asked for again
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Geocode(ctx, "Atlantis"); !errors.Is(err, ErrNoResult) {
+			t.Fatalf("Atlantis: %v", err)
+		}
+	}
+	if calls := inner.callsFor("Atlantis"); calls != 1 {
+		t.Errorf("provider asked for Atlantis %d times, want 1", calls)
+	}
+
+	// A third location evicts the least recently used
+	cache.Geocode(ctx, "El Dorado")
+	cache.Geocode(ctx, "Yosemite Valley")
+	if calls := inner.callsFor("Yosemite Valley"); calls != 3 {
+		t.Errorf("provider asked %d times after eviction, want 3", calls)
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	inner := newScripted()
+	cache := NewCache(inner, time.Millisecond, 10)
+
+	cache.Geocode(context.Background(), "Yosemite Valley")
+	time.Sleep(5 * time.Millisecond)
+	cache.Geocode(context.Background(), "Yosemite Valley")
+	if calls := inner.callsFor("Yosemite Valley"); calls != 2 {
+		t.Errorf("provider asked %d times, want 2", calls)
+	}
+}
+
+// newTestLocator returns a locator with short retry waits and the ID of a
+// campground located in Yosemite Valley.
+func newTestLocator(t *testing.T, geocoder Geocoder) (*Locator, store.CampgroundStore, int) {
+	t.Helper()
+	stores := memory.New()
+	location := "Yosemite Valley"
+	c := models.Campground{
+		Name:        "Upper Pines",
+		Price:       models.Price{Amount: 3600, Currency: "USD", Unit: models.PricePerSite},
+		Image:       "https://example.com/image.jpg",
+		Description: "A campground",
+		Location:    &location,
+	}
+	if err := stores.Campgrounds.Create(context.Background(), &c); err != nil {
+		t.Fatal(err)
+	}
+	l := NewLocator(stores.Campgrounds, geocoder)
+	l.backoff = time.Millisecond
+	return l, stores.Campgrounds, c.ID
+}
+
+func located(t *testing.T, campgrounds store.CampgroundStore, id int) bool {
+	t.Helper()
+	c, err := campgrounds.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.Latitude != nil && c.Longitude != nil && c.Address != nil
+}
+
+func TestLocatorRetries(t *testing.T) {
+	temporary := &TemporaryError{Err: errors.New("timeout")}
+	tests := []struct {
+		name    string
+		errs    []error
+		calls   int
+		located bool
+	}{
+		{"first try", nil, 1, true},
+		{"after temporary failures", []error{temporary, temporary, temporary}, attempts, true},
+		{"gives up", []error{temporary, temporary, temporary, temporary, temporary}, attempts, false},
+		{"permanent failure", []error{errors.New("invalid API key")}, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			geocoder := newScripted(tt.errs...)
+			l, campgrounds, id := newTestLocator(t, geocoder)
+
+			l.process(context.Background(), job{campgroundID: id, location: "Yosemite Valley"})
+			if calls := geocoder.callsFor("Yosemite Valley"); calls != tt.calls {
+				t.Errorf("provider asked %d times, want %d", calls, tt.calls)
+			}
+			if got := located(t, campgrounds, id); got != tt.located {
+				t.Errorf("located %v, want %v", got, tt.located)
+			}
+		})
+	}
+}
+
+func TestLocatorStopsRetryingOnShutdown(t *testing.T) {
+	geocoder := newScripted(&TemporaryError{Err: errors.New("timeout")})
+	l, _, id := newTestLocator(t, geocoder)
+	l.backoff = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		l.process(ctx, job{campgroundID: id, location: "Yosemite Valley"})
+		close(done)
+	}()
+	for geocoder.callsFor("Yosemite Valley") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("process kept waiting after shutdown")
+	}
+}
+
+func TestLocatorRun(t *testing.T) {
+	l, campgrounds, id := newTestLocator(t, newScripted())
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		l.Run(ctx)
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	l.Locate(id, "Yosemite Valley")
+	deadline := time.Now().Add(5 * time.Second)
+	for !located(t, campgrounds, id) {
+		if time.Now().After(deadline) {
+			t.Fatal("campground was never located")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLocationChangeClearsGeocode(t *testing.T) {
+	l, campgrounds, id := newTestLocator(t, newScripted())
+	l.process(context.Background(), job{campgroundID: id, location: "Yosemite Valley"})
+	if !located(t, campgrounds, id) {
+		t.Fatal("campground wasn't located")
+	}
+
+	// A lookup for the old location mustn't land after the move either
+	moved := "El Dorado"
+	err := campgrounds.Update(context.Background(), id, models.UpdateCampgroundRequest{Location: &moved}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if located(t, campgrounds, id) {
+		t.Error("coordinates of the old location were kept")
+	}
+	l.process(context.Background(), job{campgroundID: id, location: "Yosemite Valley"})
+	if located(t, campgrounds, id) {
+		t.Error("a stale lookup was stored")
+	}
+}
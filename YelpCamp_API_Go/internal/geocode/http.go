@@ -0,0 +1,125 @@
+package geocode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/geo"
+)
+
+// HTTPConfig describes a provider's search API, so any provider that
+// answers a GET with JSON can be used.
+type HTTPConfig struct {
+	// URL has {query} where the escaped location goes, e.g.
+	// https://nominatim.openstreetmap.org/search?format=jsonv2&limit=1&q={query}
+	URL string
+	// LatField, LngField and AddressField are dot-separated paths into the
+	// response, with numbers indexing arrays, e.g. "0.lat".
+	LatField     string
+	LngField     string
+	AddressField string
+	// Headers go with every request, e.g. an API key, or the User-Agent
+	// some providers insist on.
+	Headers map[string]string
+	Timeout time.Duration
+}
+
+type HTTPGeocoder struct {
+	client *http.Client
+	cfg    HTTPConfig
+}
+
+func NewHTTPGeocoder(cfg HTTPConfig) *HTTPGeocoder {
+	return &HTTPGeocoder{client: &http.Client{Timeout: cfg.Timeout}, cfg: cfg}
+}
+
+func (g *HTTPGeocoder) Geocode(ctx context.Context, query string) (*Result, error) {
+	target := strings.ReplaceAll(g.cfg.URL, "{query}", url.QueryEscape(query))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "YelpCamp API")
+	for name, value := range g.cfg.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, &TemporaryError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrNoResult
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, &TemporaryError{Err: fmt.Errorf("provider returned %s", resp.Status)}
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("geocode: provider returned %s", resp.Status)
+	}
+
+	var body interface{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("geocode: decode response: %w", err)
+	}
+	return g.result(body)
+}
+
+func (g *HTTPGeocoder) result(body interface{}) (*Result, error) {
+	lat, okLat := number(lookup(body, g.cfg.LatField))
+	lng, okLng := number(lookup(body, g.cfg.LngField))
+	if !okLat || !okLng {
+		return nil, ErrNoResult
+	}
+	p := geo.Point{Latitude: lat, Longitude: lng}
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("geocode: provider returned bad coordinates: %w", err)
+	}
+
+	address, _ := lookup(body, g.cfg.AddressField).(string)
+	return &Result{Point: p, Address: address}, nil
+}
+
+// lookup follows a dot-separated path through decoded JSON, or returns nil
+// if the path doesn't exist.
+func lookup(v interface{}, path string) interface{} {
+	if path == "" {
+		return nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			v = node[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil
+			}
+			v = node[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// number accepts JSON numbers and numeric strings; providers use both.
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
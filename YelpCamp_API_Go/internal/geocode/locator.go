@@ -0,0 +1,103 @@
+package geocode
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+const (
+	// queueSize bounds the jobs waiting for the worker; more are dropped.
+	queueSize = 1000
+	// attempts is how often a location is tried when the provider has
+	// temporary trouble, waiting retryBackoff, then twice that, and so on.
+	attempts     = 4
+	retryBackoff = 2 * time.Second
+	// lookupTimeout bounds one call to the geocoder.
+	lookupTimeout = 30 * time.Second
+)
+
+type job struct {
+	campgroundID int
+	location     string
+}
+
+// Locator geocodes campground locations in the background so that saving a
+// campground doesn't wait on the provider. One worker handles the jobs in
+// order, which also keeps within providers' request rate limits. Queued
+// jobs are lost on restart; the campground then just has no coordinates
+// until its location is edited again.
+type Locator struct {
+	campgrounds store.CampgroundStore
+	geocoder    Geocoder
+	jobs        chan job
+	// backoff is the first wait between attempts; tests shorten it.
+	backoff time.Duration
+}
+
+func NewLocator(campgrounds store.CampgroundStore, geocoder Geocoder) *Locator {
+	return &Locator{campgrounds: campgrounds, geocoder: geocoder, jobs: make(chan job, queueSize), backoff: retryBackoff}
+}
+
+// Locate queues a campground's location for geocoding. It never blocks.
+func (l *Locator) Locate(campgroundID int, location string) {
+	select {
+	case l.jobs <- job{campgroundID: campgroundID, location: location}:
+	default:
+		log.Printf("geocode: queue full, skipping campground %d", campgroundID)
+	}
+}
+
+// Run processes queued locations until ctx is done.
+func (l *Locator) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-l.jobs:
+			l.process(ctx, j)
+		}
+	}
+}
+
+func (l *Locator) process(ctx context.Context, j job) {
+	result, err := l.geocode(ctx, j.location)
+	if errors.Is(err, ErrNoResult) {
+		log.Printf("geocode: no result for campground %d location %q", j.campgroundID, j.location)
+		return
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("geocode: campground %d: %v", j.campgroundID, err)
+		}
+		return
+	}
+
+	err = l.campgrounds.SetGeocode(ctx, j.campgroundID, j.location, result.Point, result.Address)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		log.Printf("geocode: save campground %d: %v", j.campgroundID, err)
+	}
+}
+
+// geocode retries temporary failures with exponential backoff.
+func (l *Locator) geocode(ctx context.Context, location string) (*Result, error) {
+	backoff := l.backoff
+	for attempt := 1; ; attempt++ {
+		lookupCtx, cancel := context.WithTimeout(ctx, lookupTimeout)
+		result, err := l.geocoder.Geocode(lookupCtx, location)
+		cancel()
+		if err == nil || !isTemporary(err) || attempt == attempts {
+			return result, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
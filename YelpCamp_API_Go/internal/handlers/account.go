@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/auth"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/middleware"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/problem"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+	"github.com/sangnn2012/yelpcamp-api-go/pkg/validator"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AccountHandler lets signed-in users change their own credentials and
+// delete their account. Each change asks for the current password, so a
+// session left open on someone else's computer can't take over the account.
+type AccountHandler struct {
+	users  store.UserStore
+	tokens *auth.TokenService
+	emails *auth.EmailVerification
+	resets *auth.PasswordReset
+}
+
+func NewAccountHandler(users store.UserStore, tokens *auth.TokenService, emails *auth.EmailVerification,
+	resets *auth.PasswordReset) *AccountHandler {
+	return &AccountHandler{users: users, tokens: tokens, emails: emails, resets: resets}
+}
+
+// ChangePassword sets a new password and ends every other session, keeping
+// the caller signed in.
+func (h *AccountHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, r, http.StatusBadRequest, problem.InvalidBody, "Invalid request body")
+		return
+	}
+
+	if err := validator.Validate(req); err != nil {
+		respondValidationError(w, r, err)
+		return
+	}
+
+	user, ok := checkPassword(w, r, h.users, req.CurrentPassword)
+	if !ok {
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to hash password")
+		return
+	}
+	if err := h.users.UpdatePassword(r.Context(), user.ID, string(hashedPassword)); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to change password")
+		return
+	}
+
+	if err := h.tokens.EndAllSessions(r.Context(), user.ID, middleware.GetSessionID(r)); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to revoke sessions")
+		return
+	}
+	if err := h.resets.Revoke(r.Context(), user.ID); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to revoke reset links")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Password changed"})
+}
+
+// ChangeEmail switches to the new email right away, unverified until the
+// link sent there is opened. Reset links sent to the old address stop
+// working.
+func (h *AccountHandler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	var req models.ChangeEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, r, http.StatusBadRequest, problem.InvalidBody, "Invalid request body")
+		return
+	}
+
+	if err := validator.Validate(req); err != nil {
+		respondValidationError(w, r, err)
+		return
+	}
+
+	user, ok := checkPassword(w, r, h.users, req.Password)
+	if !ok {
+		return
+	}
+	if req.Email == user.Email {
+		respondError(w, r, http.StatusBadRequest, problem.EmailUnchanged, "That is already your email")
+		return
+	}
+
+	if err := h.resets.Revoke(r.Context(), user.ID); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to revoke reset links")
+		return
+	}
+	err := h.emails.ChangeEmail(r.Context(), user, req.Email)
+	if errors.Is(err, store.ErrConflict) {
+		respondError(w, r, http.StatusConflict, problem.EmailTaken, "Email already in use")
+		return
+	}
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to change email")
+		return
+	}
+
+	user, err = h.users.GetByID(r.Context(), user.ID)
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Database error")
+		return
+	}
+	respondJSON(w, http.StatusOK, user)
+}
+
+// DeleteAccount deletes the caller's account and signs them out. Their
+// campgrounds and comments stay up without an author unless the request
+// asks for them to be deleted too.
+func (h *AccountHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	// Accounts without a password may send no body at all
+	var req models.DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(w, r, http.StatusBadRequest, problem.InvalidBody, "Invalid request body")
+		return
+	}
+
+	user, ok := checkPassword(w, r, h.users, req.Password)
+	if !ok {
+		return
+	}
+
+	var err error
+	if req.DeleteContent {
+		err = h.users.DeleteWithContent(r.Context(), user.ID)
+	} else {
+		err = h.users.Delete(r.Context(), user.ID)
+	}
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to delete account")
+		return
+	}
+
+	clearAuthCookies(w)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Account deleted"})
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/auth"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/middleware"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/problem"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+	"github.com/sangnn2012/yelpcamp-api-go/pkg/validator"
+)
+
+const adminPageSize = 20
+
+// AdminHandler manages user accounts. Its routes are mounted behind
+// RequireRole(admin), and every change it makes is written to the audit log.
+type AdminHandler struct {
+	users     store.UserStore
+	auditLogs store.AuditLogStore
+	tokens    *auth.TokenService
+	apiKeys   *auth.APIKeys
+	resets    *auth.PasswordReset
+	guard     *auth.LoginGuard
+}
+
+func NewAdminHandler(users store.UserStore, auditLogs store.AuditLogStore, tokens *auth.TokenService,
+	apiKeys *auth.APIKeys, resets *auth.PasswordReset, guard *auth.LoginGuard) *AdminHandler {
+	return &AdminHandler{users: users, auditLogs: auditLogs, tokens: tokens, apiKeys: apiKeys, resets: resets, guard: guard}
+}
+
+func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	page, ok := pageParam(w, r)
+	if !ok {
+		return
+	}
+	users, total, err := h.users.List(r.Context(), store.UserFilter{
+		Search: r.URL.Query().Get("search"),
+		Limit:  adminPageSize,
+		Offset: (page - 1) * adminPageSize,
+	})
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Database error")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, paginated(users, page, adminPageSize, total))
+}
+
+func (h *AdminHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.loadUser(w, r)
+	if !ok {
+		return
+	}
+	respondJSON(w, http.StatusOK, user)
+}
+
+// UpdateRole changes the user's role. Access tokens carry the role, so a
+// demotion ends the user's sessions rather than leaving the old rights in
+// place until the tokens expire.
+func (h *AdminHandler) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.loadOtherUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, r, http.StatusBadRequest, problem.InvalidBody, "Invalid request body")
+		return
+	}
+
+	if err := validator.Validate(req); err != nil {
+		respondValidationError(w, r, err)
+		return
+	}
+
+	if err := h.users.UpdateRole(r.Context(), user.ID, req.Role); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to update role")
+		return
+	}
+	if !req.Role.AtLeast(user.Role) {
+		if err := h.tokens.EndAllSessions(r.Context(), user.ID, ""); err != nil {
+			respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to revoke sessions")
+			return
+		}
+	}
+	h.audit(r, "user.role_change", user.ID, map[string]interface{}{
+		"from": user.Role,
+		"to":   req.Role,
+	})
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Role updated"})
+}
+
+// Suspend blocks the user from signing in and ends their sessions, so
+// their access tokens stop working immediately.
+func (h *AdminHandler) Suspend(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.loadOtherUser(w, r)
+	if !ok {
+		return
+	}
+
+	// The reason is optional, so an empty body is fine
+	var req models.SuspendUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(w, r, http.StatusBadRequest, problem.InvalidBody, "Invalid request body")
+		return
+	}
+
+	if err := validator.Validate(req); err != nil {
+		respondValidationError(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	if err := h.users.SetSuspended(r.Context(), user.ID, &now); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to suspend user")
+		return
+	}
+	if err := h.tokens.EndAllSessions(r.Context(), user.ID, ""); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to revoke sessions")
+		return
+	}
+
+	details := map[string]interface{}{}
+	if req.Reason != "" {
+		details["reason"] = req.Reason
+	}
+	h.audit(r, "user.suspend", user.ID, details)
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "User suspended"})
+}
+
+func (h *AdminHandler) Unsuspend(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.loadOtherUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.users.SetSuspended(r.Context(), user.ID, nil); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to unsuspend user")
+		return
+	}
+	h.audit(r, "user.unsuspend", user.ID, nil)
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "User unsuspended"})
+}
+
+// Unlock lifts a lockout from failed logins before it runs out.
+func (h *AdminHandler) Unlock(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.loadUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.guard.Unlock(r.Context(), user.Username); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to unlock user")
+		return
+	}
+	h.audit(r, "user.unlock", user.ID, nil)
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "User unlocked"})
+}
+
+// ForcePasswordReset clears the user's password, signs them out everywhere,
+// revokes their API keys and emails them a reset link, e.g. after their
+// account was compromised.
+func (h *AdminHandler) ForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.loadUser(w, r)
+	if !ok {
+		return
+	}
+
+	// An empty hash never matches, so only the reset link gets them back in
+	if err := h.users.UpdatePassword(r.Context(), user.ID, ""); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to reset password")
+		return
+	}
+	if err := h.tokens.EndAllSessions(r.Context(), user.ID, ""); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to revoke sessions")
+		return
+	}
+	if err := h.apiKeys.RevokeAll(r.Context(), user.ID); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to revoke API keys")
+		return
+	}
+	if err := h.resets.Send(r.Context(), user); err != nil {
+		log.Printf("admin: send password reset to %s: %v", user.ID, err)
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to send password reset email")
+		return
+	}
+	h.audit(r, "user.password_reset", user.ID, nil)
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Password reset email sent"})
+}
+
+func (h *AdminHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.loadOtherUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.users.Delete(r.Context(), user.ID); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to delete user")
+		return
+	}
+	h.audit(r, "user.delete", user.ID, map[string]interface{}{
+		"username": user.Username,
+		"email":    user.Email,
+	})
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "User deleted"})
+}
+
+func (h *AdminHandler) ListAuditLogs(w http.ResponseWriter, r *http.Request) {
+	page, ok := pageParam(w, r)
+	if !ok {
+		return
+	}
+	entries, total, err := h.auditLogs.List(r.Context(), store.AuditLogFilter{
+		ActorID:  r.URL.Query().Get("actorId"),
+		TargetID: r.URL.Query().Get("targetId"),
+		Limit:    adminPageSize,
+		Offset:   (page - 1) * adminPageSize,
+	})
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Database error")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, paginated(entries, page, adminPageSize, total))
+}
+
+// loadUser writes the error response and returns false unless the user in
+// the URL exists.
+func (h *AdminHandler) loadUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	user, err := h.users.GetByID(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, store.ErrNotFound) {
+		respondError(w, r, http.StatusNotFound, problem.NotFound, "User not found")
+		return nil, false
+	}
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Database error")
+		return nil, false
+	}
+	return user, true
+}
+
+// loadOtherUser is loadUser for actions admins may not take on their own
+// account, so the last admin can't lock everyone out by accident.
+func (h *AdminHandler) loadOtherUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	if chi.URLParam(r, "id") == middleware.GetUserID(r) {
+		respondError(w, r, http.StatusBadRequest, problem.CannotModifySelf, "You can't do that to your own account")
+		return nil, false
+	}
+	return h.loadUser(w, r)
+}
+
+// audit records an action taken on a user. The change has already been
+// made, so a failure to record it is logged rather than returned.
+func (h *AdminHandler) audit(r *http.Request, action, targetID string, details map[string]interface{}) {
+	actorID, ip := middleware.GetUserID(r), clientInfo(r).IPAddress
+	entry := &models.AuditLog{
+		ID:         uuid.New().String(),
+		ActorID:    &actorID,
+		Action:     action,
+		TargetType: "user",
+		TargetID:   targetID,
+		Details:    details,
+		IPAddress:  &ip,
+		CreatedAt:  time.Now(),
+	}
+	if err := h.auditLogs.Create(context.WithoutCancel(r.Context()), entry); err != nil {
+		log.Printf("audit: record %s on %s by %s: %v", action, targetID, actorID, err)
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/auth"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/middleware"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/problem"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+	"github.com/sangnn2012/yelpcamp-api-go/pkg/validator"
+)
+
+type APIKeyHandler struct {
+	apiKeys *auth.APIKeys
+}
+
+func NewAPIKeyHandler(apiKeys *auth.APIKeys) *APIKeyHandler {
+	return &APIKeyHandler{apiKeys: apiKeys}
+}
+
+func (h *APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.apiKeys.List(r.Context(), middleware.GetUserID(r))
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Database error")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, keys)
+}
+
+// Create returns the new key in full; this is the only time it is shown.
+func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, r, http.StatusBadRequest, problem.InvalidBody, "Invalid request body")
+		return
+	}
+
+	if err := validator.Validate(req); err != nil {
+		respondValidationError(w, r, err)
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		problem.Write(w, r, problem.InvalidField("expiresAt", "future", "expiresAt must be in the future"))
+		return
+	}
+
+	key, raw, err := h.apiKeys.Create(r.Context(), middleware.GetUserID(r), req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to create API key")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, models.APIKeyCreatedResponse{APIKey: *key, Key: raw})
+}
+
+func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	err := h.apiKeys.Revoke(r.Context(), middleware.GetUserID(r), chi.URLParam(r, "id"))
+	if errors.Is(err, store.ErrNotFound) {
+		respondError(w, r, http.StatusNotFound, problem.NotFound, "API key not found")
+		return
+	}
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to revoke API key")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "API key revoked"})
+}
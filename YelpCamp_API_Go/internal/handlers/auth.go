@@ -3,167 +3,358 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"log"
+	"net"
 	"net/http"
-	"os"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/auth"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/background"
 	"github.com/sangnn2012/yelpcamp-api-go/internal/middleware"
 	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/problem"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
 	"github.com/sangnn2012/yelpcamp-api-go/pkg/validator"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const refreshCookiePath = "/api/auth"
+
 type AuthHandler struct {
-	db *pgxpool.Pool
+	users     store.UserStore
+	tokens    *auth.TokenService
+	emails    *auth.EmailVerification
+	twoFactor *auth.TwoFactor
+	guard     *auth.LoginGuard
+	tasks     *background.Runner
 }
 
-func NewAuthHandler(db *pgxpool.Pool) *AuthHandler {
-	return &AuthHandler{db: db}
+// NewAuthHandler sends verification emails on tasks, so signing up doesn't
+// wait for the mail server.
+func NewAuthHandler(users store.UserStore, tokens *auth.TokenService, emails *auth.EmailVerification,
+	twoFactor *auth.TwoFactor, guard *auth.LoginGuard, tasks *background.Runner) *AuthHandler {
+	return &AuthHandler{users: users, tokens: tokens, emails: emails, twoFactor: twoFactor, guard: guard, tasks: tasks}
 }
 
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	var req models.RegisterRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid request body")
+		respondError(w, r, http.StatusBadRequest, problem.InvalidBody, "Invalid request body")
 		return
 	}
 
 	if err := validator.Validate(req); err != nil {
-		errors := validator.ValidationErrors(err)
-		respondError(w, http.StatusBadRequest, errors[0])
+		respondValidationError(w, r, err)
 		return
 	}
 
 	// Check if user exists
-	var exists bool
-	err := h.db.QueryRow(context.Background(),
-		"SELECT EXISTS(SELECT 1 FROM users WHERE username = $1 OR email = $2)",
-		req.Username, req.Email).Scan(&exists)
+	exists, err := h.users.ExistsByUsernameOrEmail(r.Context(), req.Username, req.Email)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Database error")
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Database error")
 		return
 	}
 	if exists {
-		respondError(w, http.StatusConflict, "Username or email already exists")
+		respondError(w, r, http.StatusConflict, problem.Conflict, "Username or email already exists")
 		return
 	}
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to hash password")
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to hash password")
 		return
 	}
 
 	// Create user
-	userID := uuid.New().String()
 	now := time.Now()
-	_, err = h.db.Exec(context.Background(),
-		`INSERT INTO users (id, username, email, password, created_at, updated_at)
-		 VALUES ($1, $2, $3, $4, $5, $6)`,
-		userID, req.Username, req.Email, string(hashedPassword), now, now)
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to create user")
-		return
-	}
-
-	// Generate token and set cookie
-	token := generateToken(userID)
-	setTokenCookie(w, token)
-
-	respondJSON(w, http.StatusCreated, models.User{
-		ID:        userID,
+	user := models.User{
+		ID:        uuid.New().String(),
 		Username:  req.Username,
 		Email:     req.Email,
+		Role:      models.RoleUser,
+		Password:  string(hashedPassword),
 		CreatedAt: now,
 		UpdatedAt: now,
-	})
+	}
+	if err := h.users.Create(r.Context(), &user); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			respondError(w, r, http.StatusConflict, problem.Conflict, "Username or email already exists")
+			return
+		}
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to create user")
+		return
+	}
+
+	// Issue tokens and set cookies
+	if err := startSession(w, r, h.tokens, &user); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to issue token")
+		return
+	}
+
+	h.tasks.Go(func(ctx context.Context) { h.sendVerificationEmail(ctx, user) })
+
+	respondJSON(w, http.StatusCreated, user)
 }
 
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req models.LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid request body")
+		respondError(w, r, http.StatusBadRequest, problem.InvalidBody, "Invalid request body")
 		return
 	}
 
 	if err := validator.Validate(req); err != nil {
-		errors := validator.ValidationErrors(err)
-		respondError(w, http.StatusBadRequest, errors[0])
+		respondValidationError(w, r, err)
 		return
 	}
 
-	// Find user
-	var user models.User
-	var hashedPassword string
-	err := h.db.QueryRow(context.Background(),
-		`SELECT id, username, email, password, created_at, updated_at
-		 FROM users WHERE username = $1`,
-		req.Username).Scan(&user.ID, &user.Username, &user.Email, &hashedPassword, &user.CreatedAt, &user.UpdatedAt)
+	// Back off after failed attempts for this username or address
+	ip := clientInfo(r).IPAddress
+	err := h.guard.Check(r.Context(), req.Username, ip)
+	var throttled *auth.ThrottledError
+	if errors.As(err, &throttled) {
+		setRetryAfter(w, throttled.RetryAfter)
+		respondError(w, r, http.StatusTooManyRequests, problem.LoginThrottled, "Too many failed login attempts, "+throttled.Error())
+		return
+	}
 	if err != nil {
-		respondError(w, http.StatusUnauthorized, "Invalid credentials")
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Database error")
+		return
+	}
+
+	// Find user; an unknown one is checked against a dummy hash so the
+	// response time doesn't give it away
+	user, err := h.users.GetByUsername(r.Context(), req.Username)
+	if errors.Is(err, store.ErrNotFound) {
+		user = nil
+	} else if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Database error")
 		return
 	}
 
 	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(req.Password)); err != nil {
-		respondError(w, http.StatusUnauthorized, "Invalid credentials")
+	if !auth.CheckPassword(user, req.Password) {
+		if err := h.guard.Fail(r.Context(), req.Username, ip, user); err != nil {
+			log.Printf("login guard: record failure for %q: %v", req.Username, err)
+		}
+		respondError(w, r, http.StatusUnauthorized, problem.InvalidCredentials, "Invalid credentials")
 		return
 	}
 
-	// Generate token and set cookie
-	token := generateToken(user.ID)
-	setTokenCookie(w, token)
+	// With two-factor on, the password only earns a challenge for step two;
+	// failed logins are only forgotten once that succeeds
+	if user.TwoFactorEnabled {
+		challenge, err := h.twoFactor.StartChallenge(r.Context(), user.ID)
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to start two-factor login")
+			return
+		}
+		respondJSON(w, http.StatusOK, models.TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+			ExpiresIn:         int(h.twoFactor.ChallengeTTL().Seconds()),
+		})
+		return
+	}
+
+	// Issue tokens and set cookies
+	err = startSession(w, r, h.tokens, user)
+	if errors.Is(err, auth.ErrSuspended) {
+		respondError(w, r, http.StatusForbidden, problem.AccountSuspended, "Account suspended")
+		return
+	}
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to issue token")
+		return
+	}
+	h.succeed(r, user)
 
 	respondJSON(w, http.StatusOK, user)
 }
 
-func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
-	http.SetCookie(w, &http.Cookie{
-		Name:     "token",
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		HttpOnly: true,
+// succeed forgets the user's failed logins once they have fully signed in.
+func (h *AuthHandler) succeed(r *http.Request, user *models.User) {
+	if err := h.guard.Succeed(r.Context(), user.Username); err != nil {
+		log.Printf("login guard: reset failures for %q: %v", user.Username, err)
+	}
+}
+
+// LoginTwoFactor is the second login step: it trades the challenge token
+// from Login and a TOTP or recovery code for a session.
+func (h *AuthHandler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req models.TwoFactorLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, r, http.StatusBadRequest, problem.InvalidBody, "Invalid request body")
+		return
+	}
+
+	if err := validator.Validate(req); err != nil {
+		respondValidationError(w, r, err)
+		return
+	}
+
+	userID, err := h.twoFactor.CompleteChallenge(r.Context(), req.ChallengeToken, req.Code)
+	if errors.Is(err, auth.ErrInvalidCode) {
+		// A wrong code counts like a wrong password
+		if user, err := h.users.GetByID(r.Context(), userID); err == nil {
+			if err := h.guard.Fail(r.Context(), user.Username, clientInfo(r).IPAddress, user); err != nil {
+				log.Printf("login guard: record failure for %q: %v", user.Username, err)
+			}
+		}
+	}
+	if errors.Is(err, auth.ErrInvalidToken) || errors.Is(err, auth.ErrInvalidCode) {
+		respondError(w, r, http.StatusUnauthorized, problem.InvalidTwoFactorCode, "Invalid or expired two-factor code, please log in again")
+		return
+	}
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to verify two-factor code")
+		return
+	}
+
+	user, err := h.users.GetByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, r, http.StatusUnauthorized, problem.InvalidCredentials, "Invalid credentials")
+		return
+	}
+
+	// Issue tokens and set cookies
+	err = startSession(w, r, h.tokens, user)
+	if errors.Is(err, auth.ErrSuspended) {
+		respondError(w, r, http.StatusForbidden, problem.AccountSuspended, "Account suspended")
+		return
+	}
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to issue token")
+		return
+	}
+	h.succeed(r, user)
+
+	respondJSON(w, http.StatusOK, user)
+}
+
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	// Browsers send the cookie; scripted clients may post the token instead
+	raw := ""
+	if cookie, err := r.Cookie("refresh_token"); err == nil {
+		raw = cookie.Value
+	} else {
+		var req models.RefreshRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err == nil {
+			raw = req.RefreshToken
+		}
+	}
+	if raw == "" {
+		respondError(w, r, http.StatusUnauthorized, problem.Unauthorized, "Refresh token required")
+		return
+	}
+
+	tokens, err := h.tokens.Refresh(r.Context(), raw)
+	if errors.Is(err, auth.ErrInvalidToken) || errors.Is(err, auth.ErrTokenReused) {
+		clearAuthCookies(w)
+		respondError(w, r, http.StatusUnauthorized, problem.InvalidToken, "Invalid refresh token")
+		return
+	}
+	if errors.Is(err, auth.ErrSuspended) {
+		clearAuthCookies(w)
+		respondError(w, r, http.StatusForbidden, problem.AccountSuspended, "Account suspended")
+		return
+	}
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to refresh token")
+		return
+	}
+	setAuthCookies(w, h.tokens, tokens)
+
+	respondJSON(w, http.StatusOK, models.TokenResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    int(h.tokens.AccessTTL().Seconds()),
 	})
+}
+
+// Logout ends the caller's session. It runs behind OptionalAuth so a client
+// whose access token already expired can still end it via the refresh cookie.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var err error
+	if sessionID := middleware.GetSessionID(r); sessionID != "" {
+		err = h.tokens.EndSession(r.Context(), middleware.GetUserID(r), sessionID)
+	} else if cookie, cookieErr := r.Cookie("refresh_token"); cookieErr == nil {
+		err = h.tokens.EndSessionByRefreshToken(r.Context(), cookie.Value)
+	}
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to log out")
+		return
+	}
+
+	clearAuthCookies(w)
 	respondJSON(w, http.StatusOK, map[string]string{"message": "Logged out"})
 }
 
 func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 	userID := middleware.GetUserID(r)
 
-	var user models.User
-	err := h.db.QueryRow(context.Background(),
-		`SELECT id, username, email, created_at, updated_at
-		 FROM users WHERE id = $1`, userID).
-		Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt, &user.UpdatedAt)
+	user, err := h.users.GetByID(r.Context(), userID)
 	if err != nil {
-		respondError(w, http.StatusNotFound, "User not found")
+		respondError(w, r, http.StatusNotFound, problem.NotFound, "User not found")
 		return
 	}
 
 	respondJSON(w, http.StatusOK, user)
 }
 
-func generateToken(userID string) string {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": userID,
-		"exp": time.Now().Add(7 * 24 * time.Hour).Unix(),
-	})
-	tokenString, _ := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
-	return tokenString
+// startSession starts a new session for the user and sets both cookies.
+func startSession(w http.ResponseWriter, r *http.Request, service *auth.TokenService, user *models.User) error {
+	tokens, err := service.StartSession(r.Context(), user, clientInfo(r))
+	if err != nil {
+		return err
+	}
+	setAuthCookies(w, service, tokens)
+	return nil
 }
 
-func setTokenCookie(w http.ResponseWriter, token string) {
+func setAuthCookies(w http.ResponseWriter, service *auth.TokenService, tokens *auth.Tokens) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     "token",
-		Value:    token,
+		Value:    tokens.AccessToken,
 		Path:     "/",
-		MaxAge:   7 * 24 * 60 * 60,
+		MaxAge:   int(service.AccessTTL().Seconds()),
 		HttpOnly: true,
 		SameSite: http.SameSiteLaxMode,
 	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     "refresh_token",
+		Value:    tokens.RefreshToken,
+		Path:     refreshCookiePath,
+		MaxAge:   int(service.RefreshTTL().Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func clearAuthCookies(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "token",
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     "refresh_token",
+		Value:    "",
+		Path:     refreshCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+}
+
+func clientInfo(r *http.Request) auth.ClientInfo {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+	return auth.ClientInfo{IPAddress: ip, UserAgent: r.UserAgent()}
 }
@@ -0,0 +1,259 @@
+package handlers_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/problem"
+)
+
+func TestRegister(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.register("bob")
+
+	if w := s.do("GET", "/api/auth/me", "", token); w.Code != http.StatusOK {
+		t.Fatalf("me: status %d", w.Code)
+	}
+	w := s.do("POST", "/api/auth/register", `{"username":"bob","email":"other@example.com","password":"secret1"}`)
+	expectProblem(t, w, http.StatusConflict, problem.Conflict)
+	w = s.do("POST", "/api/auth/register", `{"username":"al","email":"al@example.com","password":"secret1"}`)
+	expectProblem(t, w, http.StatusBadRequest, problem.ValidationFailed)
+
+	// Every invalid field is reported, not just the first
+	w = s.do("POST", "/api/auth/register", `{"username":"al","email":"nope","password":"x"}`)
+	p := expectProblem(t, w, http.StatusBadRequest, problem.ValidationFailed)
+	var fields []string
+	for _, e := range p.Errors {
+		fields = append(fields, e.Field)
+	}
+	if !reflect.DeepEqual(fields, []string{"username", "email", "password"}) {
+		t.Errorf("errors for %q, want username, email and password", fields)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	s := newTestServer(t)
+	s.register("bob")
+
+	expectProblem(t, s.login("bob", "wrong"), http.StatusUnauthorized, problem.InvalidCredentials)
+	expectProblem(t, s.login("nobody", "secret1"), http.StatusUnauthorized, problem.InvalidCredentials)
+
+	w := s.login("bob", "secret1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: status %d: %s", w.Code, w.Body)
+	}
+	if w := s.do("GET", "/api/auth/me", "", mustCookie(t, w, "token")); w.Code != http.StatusOK {
+		t.Fatalf("me: status %d", w.Code)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	s := newTestServer(t)
+	s.register("bob")
+
+	for i := 0; i < testMaxFailures; i++ {
+		expectProblem(t, s.login("bob", "wrong"), http.StatusUnauthorized, problem.InvalidCredentials)
+	}
+	// Locked out, even with the right password
+	w := s.login("bob", "secret1")
+	expectProblem(t, w, http.StatusTooManyRequests, problem.LoginThrottled)
+	if w.Header().Get("Retry-After") == "" {
+		t.Fatal("no Retry-After header")
+	}
+
+	// Unknown usernames are throttled the same way
+	for i := 0; i < testMaxFailures; i++ {
+		s.login("nobody", "wrong")
+	}
+	expectProblem(t, s.login("nobody", "wrong"), http.StatusTooManyRequests, problem.LoginThrottled)
+}
+
+func TestLoginSuccessResetsFailures(t *testing.T) {
+	s := newTestServer(t)
+	s.register("bob")
+
+	for round := 0; round < 2; round++ {
+		for i := 0; i < testMaxFailures-1; i++ {
+			expectProblem(t, s.login("bob", "wrong"), http.StatusUnauthorized, problem.InvalidCredentials)
+		}
+		if w := s.login("bob", "secret1"); w.Code != http.StatusOK {
+			t.Fatalf("round %d: login: status %d: %s", round, w.Code, w.Body)
+		}
+	}
+}
+
+func TestSuspendedLoginKeepsFailures(t *testing.T) {
+	s := newTestServer(t)
+	s.register("bob")
+	user, err := s.stores.Users.GetByUsername(context.Background(), "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < testMaxFailures-1; i++ {
+		s.login("bob", "wrong")
+	}
+	now := time.Now()
+	if err := s.stores.Users.SetSuspended(context.Background(), user.ID, &now); err != nil {
+		t.Fatal(err)
+	}
+	// The right password doesn't sign a suspended user in, so it mustn't
+	// wipe the failures either
+	expectProblem(t, s.login("bob", "secret1"), http.StatusForbidden, problem.AccountSuspended)
+	expectProblem(t, s.login("bob", "wrong"), http.StatusUnauthorized, problem.InvalidCredentials)
+	expectProblem(t, s.login("bob", "secret1"), http.StatusTooManyRequests, problem.LoginThrottled)
+}
+
+func TestRefreshRotatesTokens(t *testing.T) {
+	s := newTestServer(t)
+	_, refresh := s.register("bob")
+
+	w := s.do("POST", "/api/auth/refresh", "", refresh)
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh: status %d: %s", w.Code, w.Body)
+	}
+	access2, refresh2 := mustCookie(t, w, "token"), mustCookie(t, w, "refresh_token")
+	if refresh2.Value == refresh.Value {
+		t.Fatal("refresh token was not rotated")
+	}
+	if w := s.do("GET", "/api/auth/me", "", access2); w.Code != http.StatusOK {
+		t.Fatalf("me with new access token: status %d", w.Code)
+	}
+
+	// The rotated token works once more
+	w = s.do("POST", "/api/auth/refresh", "", refresh2)
+	if w.Code != http.StatusOK {
+		t.Fatalf("second refresh: status %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestRefreshTokenReuseEndsSession(t *testing.T) {
+	s := newTestServer(t)
+	_, refresh := s.register("bob")
+
+	w := s.do("POST", "/api/auth/refresh", "", refresh)
+	access2, refresh2 := mustCookie(t, w, "token"), mustCookie(t, w, "refresh_token")
+
+	// Presenting the old token again means it leaked: the whole session ends
+	expectProblem(t, s.do("POST", "/api/auth/refresh", "", refresh), http.StatusUnauthorized, problem.InvalidToken)
+	expectProblem(t, s.do("POST", "/api/auth/refresh", "", refresh2), http.StatusUnauthorized, problem.InvalidToken)
+	expectProblem(t, s.do("GET", "/api/auth/me", "", access2), http.StatusUnauthorized, problem.InvalidToken)
+}
+
+func TestLoginTwoFactor(t *testing.T) {
+	s := newTestServer(t)
+	secret, now := enableTwoFactor(t, s, "bob")
+
+	// The password alone only earns a challenge
+	w := s.login("bob", "secret1")
+	var challenge models.TwoFactorChallengeResponse
+	decode(t, w, &challenge)
+	if w.Code != http.StatusOK || !challenge.TwoFactorRequired || challenge.ChallengeToken == "" {
+		t.Fatalf("login: status %d: %s", w.Code, w.Body)
+	}
+	if cookie(w, "token") != nil {
+		t.Fatal("login set a session cookie before the second step")
+	}
+
+	// The step used to confirm enrollment can't be replayed, and the failed
+	// attempt uses up the challenge
+	w = s.loginTwoFactor(challenge.ChallengeToken, totp(t, secret, now))
+	expectProblem(t, w, http.StatusUnauthorized, problem.InvalidTwoFactorCode)
+	w = s.loginTwoFactor(challenge.ChallengeToken, totp(t, secret, now.Add(30*time.Second)))
+	expectProblem(t, w, http.StatusUnauthorized, problem.InvalidTwoFactorCode)
+
+	decode(t, s.login("bob", "secret1"), &challenge)
+	w = s.loginTwoFactor(challenge.ChallengeToken, totp(t, secret, now.Add(30*time.Second)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("second step: status %d: %s", w.Code, w.Body)
+	}
+	if w := s.do("GET", "/api/auth/me", "", mustCookie(t, w, "token")); w.Code != http.StatusOK {
+		t.Fatalf("me: status %d", w.Code)
+	}
+}
+
+func TestLoginTwoFactorWrongCodesLockOut(t *testing.T) {
+	s := newTestServer(t)
+	secret, now := enableTwoFactor(t, s, "bob")
+	wrong := wrongTOTP(t, secret, now)
+
+	// Each right password with a wrong code is a failure, so asking for new
+	// challenges doesn't give unlimited guesses
+	for i := 0; i < testMaxFailures; i++ {
+		var challenge models.TwoFactorChallengeResponse
+		w := s.login("bob", "secret1")
+		decode(t, w, &challenge)
+		if challenge.ChallengeToken == "" {
+			t.Fatalf("attempt %d: no challenge: status %d: %s", i, w.Code, w.Body)
+		}
+		w = s.loginTwoFactor(challenge.ChallengeToken, wrong)
+		expectProblem(t, w, http.StatusUnauthorized, problem.InvalidTwoFactorCode)
+	}
+	expectProblem(t, s.login("bob", "secret1"), http.StatusTooManyRequests, problem.LoginThrottled)
+}
+
+func (s *testServer) loginTwoFactor(challengeToken, code string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	return s.do("POST", "/api/auth/login/2fa", `{"challengeToken":"`+challengeToken+`","code":"`+code+`"}`)
+}
+
+// enableTwoFactor registers the user and turns on two-factor login. It
+// returns the TOTP secret and the time of the code used to confirm it.
+func enableTwoFactor(t *testing.T, s *testServer, username string) (string, time.Time) {
+	t.Helper()
+	access, _ := s.register(username)
+
+	var enroll models.TwoFactorEnrollResponse
+	w := s.do("POST", "/api/auth/2fa/enroll", "", access)
+	decode(t, w, &enroll)
+	if w.Code != http.StatusOK || enroll.Secret == "" {
+		t.Fatalf("enroll: status %d: %s", w.Code, w.Body)
+	}
+
+	now := time.Now()
+	w = s.do("POST", "/api/auth/2fa/confirm", `{"code":"`+totp(t, enroll.Secret, now)+`"}`, access)
+	if w.Code != http.StatusOK {
+		t.Fatalf("confirm: status %d: %s", w.Code, w.Body)
+	}
+	return enroll.Secret, now
+}
+
+// totp computes the RFC 6238 code an authenticator app would show at t.
+func totp(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatalf("decode secret: %v", err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(at.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff%1_000_000)
+}
+
+// wrongTOTP returns a code that isn't valid around t.
+func wrongTOTP(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	valid := map[string]bool{}
+	for step := -2; step <= 2; step++ {
+		valid[totp(t, secret, at.Add(time.Duration(step)*30*time.Second))] = true
+	}
+	for i := 0; ; i++ {
+		if code := fmt.Sprintf("%06d", i); !valid[code] {
+			return code
+		}
+	}
+}
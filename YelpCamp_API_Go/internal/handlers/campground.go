@@ -1,145 +1,118 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/geo"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/geocode"
 	"github.com/sangnn2012/yelpcamp-api-go/internal/middleware"
 	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/policy"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/problem"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
 	"github.com/sangnn2012/yelpcamp-api-go/pkg/validator"
 )
 
 type CampgroundHandler struct {
-	db *pgxpool.Pool
+	campgrounds store.CampgroundStore
+	comments    store.CommentStore
+	locator     *geocode.Locator
 }
 
-func NewCampgroundHandler(db *pgxpool.Pool) *CampgroundHandler {
-	return &CampgroundHandler{db: db}
+// NewCampgroundHandler takes a nil locator when geocoding is off.
+func NewCampgroundHandler(campgrounds store.CampgroundStore, comments store.CommentStore, locator *geocode.Locator) *CampgroundHandler {
+	return &CampgroundHandler{campgrounds: campgrounds, comments: comments, locator: locator}
 }
 
 func (h *CampgroundHandler) List(w http.ResponseWriter, r *http.Request) {
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	if page < 1 {
-		page = 1
+	if !knownParams(w, r, campgroundListParams) {
+		return
+	}
+	page, ok := pageParam(w, r)
+	if !ok {
+		return
 	}
 	limit := 12
 	offset := (page - 1) * limit
-	search := r.URL.Query().Get("search")
-
-	// Count total
-	var total int
-	countQuery := "SELECT COUNT(*) FROM campgrounds"
-	args := []interface{}{}
-	if search != "" {
-		countQuery += " WHERE name ILIKE $1 OR description ILIKE $1 OR location ILIKE $1"
-		args = append(args, "%"+search+"%")
-	}
-	h.db.QueryRow(context.Background(), countQuery, args...).Scan(&total)
-
-	// Get campgrounds
-	query := `
-		SELECT c.id, c.name, c.price, c.image, c.description, c.location, c.author_id,
-			   c.created_at, c.updated_at, u.id, u.username
-		FROM campgrounds c
-		LEFT JOIN users u ON c.author_id = u.id
-	`
-	if search != "" {
-		query += " WHERE c.name ILIKE $1 OR c.description ILIKE $1 OR c.location ILIKE $1"
-		query += " ORDER BY c.created_at DESC LIMIT $2 OFFSET $3"
-		args = append(args, limit, offset)
-	} else {
-		query += " ORDER BY c.created_at DESC LIMIT $1 OFFSET $2"
-		args = []interface{}{limit, offset}
-	}
-
-	rows, err := h.db.Query(context.Background(), query, args...)
+
+	query := r.URL.Query()
+	filter := store.CampgroundFilter{
+		Search:   query.Get("search"),
+		Author:   query.Get("author"),
+		Currency: strings.ToUpper(query.Get("currency")),
+		Limit:    limit,
+		Offset:   offset,
+	}
+	if filter.Currency != "" && validator.Var(filter.Currency, "iso4217") != nil {
+		problem.Write(w, r, problem.InvalidField("currency", "iso4217", "currency must be an ISO 4217 currency code, e.g. USD"))
+		return
+	}
+	if filter.CreatedAfter, ok = timeParam(w, r, "createdAfter"); !ok {
+		return
+	}
+	if filter.CreatedBefore, ok = timeParam(w, r, "createdBefore"); !ok {
+		return
+	}
+	if !filter.CreatedAfter.IsZero() && !filter.CreatedBefore.IsZero() && !filter.CreatedAfter.Before(filter.CreatedBefore) {
+		problem.Write(w, r, problem.InvalidField("createdBefore", "gtfield", "createdBefore must be after createdAfter"))
+		return
+	}
+	switch has := query.Get("has"); has {
+	case "":
+	case "comments":
+		filter.HasComments = true
+	default:
+		problem.Write(w, r, problem.InvalidField("has", "oneof", "has must be comments"))
+		return
+	}
+	if filter.PriceMin, ok = priceParam(w, r, "priceMin"); !ok {
+		return
+	}
+	if filter.PriceMax, ok = priceParam(w, r, "priceMax"); !ok {
+		return
+	}
+	if !geoParams(w, r, &filter) {
+		return
+	}
+	if !sortParam(w, r, &filter) {
+		return
+	}
+
+	campgrounds, total, err := h.campgrounds.List(r.Context(), filter)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Database error")
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Database error")
 		return
 	}
-	defer rows.Close()
 
-	campgrounds := []models.Campground{}
-	for rows.Next() {
-		var c models.Campground
-		var authorID, authorUsername *string
-		err := rows.Scan(&c.ID, &c.Name, &c.Price, &c.Image, &c.Description, &c.Location,
-			&c.AuthorID, &c.CreatedAt, &c.UpdatedAt, &authorID, &authorUsername)
-		if err != nil {
-			continue
-		}
-		if authorID != nil && authorUsername != nil {
-			c.Author = &models.Author{ID: *authorID, Username: *authorUsername}
-		}
-		campgrounds = append(campgrounds, c)
-	}
-
-	totalPages := (total + limit - 1) / limit
-	respondJSON(w, http.StatusOK, models.PaginatedResponse{
-		Data: campgrounds,
-		Pagination: models.Pagination{
-			Page:       page,
-			Limit:      limit,
-			Total:      total,
-			TotalPages: totalPages,
-			HasMore:    page < totalPages,
-		},
-	})
+	respondJSON(w, http.StatusOK, paginated(campgrounds, page, limit, total))
 }
 
 func (h *CampgroundHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid campground ID")
+		respondError(w, r, http.StatusBadRequest, problem.InvalidParameter, "Invalid campground ID")
 		return
 	}
 
-	var c models.Campground
-	var authorID, authorUsername *string
-	err = h.db.QueryRow(context.Background(), `
-		SELECT c.id, c.name, c.price, c.image, c.description, c.location, c.author_id,
-			   c.created_at, c.updated_at, u.id, u.username
-		FROM campgrounds c
-		LEFT JOIN users u ON c.author_id = u.id
-		WHERE c.id = $1
-	`, id).Scan(&c.ID, &c.Name, &c.Price, &c.Image, &c.Description, &c.Location,
-		&c.AuthorID, &c.CreatedAt, &c.UpdatedAt, &authorID, &authorUsername)
-
+	c, err := h.campgrounds.GetByID(r.Context(), id)
 	if err != nil {
-		respondError(w, http.StatusNotFound, "Campground not found")
+		respondError(w, r, http.StatusNotFound, problem.NotFound, "Campground not found")
 		return
 	}
 
-	if authorID != nil && authorUsername != nil {
-		c.Author = &models.Author{ID: *authorID, Username: *authorUsername}
-	}
-
 	// Get comments
-	rows, _ := h.db.Query(context.Background(), `
-		SELECT c.id, c.text, c.campground_id, c.author_id, c.created_at, c.updated_at,
-			   u.id, u.username
-		FROM comments c
-		LEFT JOIN users u ON c.author_id = u.id
-		WHERE c.campground_id = $1
-		ORDER BY c.created_at DESC
-	`, id)
-	defer rows.Close()
-
-	c.Comments = []models.Comment{}
-	for rows.Next() {
-		var comment models.Comment
-		var aID, aUsername *string
-		rows.Scan(&comment.ID, &comment.Text, &comment.CampgroundID, &comment.AuthorID,
-			&comment.CreatedAt, &comment.UpdatedAt, &aID, &aUsername)
-		if aID != nil && aUsername != nil {
-			comment.Author = &models.Author{ID: *aID, Username: *aUsername}
-		}
-		c.Comments = append(c.Comments, comment)
+	c.Comments, err = h.comments.ListByCampground(r.Context(), id)
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Database error")
+		return
 	}
 
 	respondJSON(w, http.StatusOK, c)
@@ -149,105 +122,238 @@ func (h *CampgroundHandler) Create(w http.ResponseWriter, r *http.Request) {
 	userID := middleware.GetUserID(r)
 
 	var req models.CreateCampgroundRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid request body")
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if errors.Is(err, models.ErrPriceText) {
+		problem.Write(w, r, problem.InvalidField("price", "price", "price must contain an amount, e.g. 15.00"))
 		return
 	}
-
-	if err := validator.Validate(req); err != nil {
-		errors := validator.ValidationErrors(err)
-		respondError(w, http.StatusBadRequest, errors[0])
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, problem.InvalidBody, "Invalid request body")
 		return
 	}
 
-	now := time.Now()
-	var id int
-	err := h.db.QueryRow(context.Background(), `
-		INSERT INTO campgrounds (name, price, image, description, location, author_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id
-	`, req.Name, req.Price, req.Image, req.Description, req.Location, userID, now, now).Scan(&id)
-
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to create campground")
+	if err := validator.Validate(req); err != nil {
+		respondValidationError(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusCreated, models.Campground{
-		ID:          id,
+	now := time.Now()
+	c := models.Campground{
 		Name:        req.Name,
 		Price:       req.Price,
 		Image:       req.Image,
 		Description: req.Description,
 		Location:    req.Location,
+		Latitude:    req.Latitude,
+		Longitude:   req.Longitude,
 		AuthorID:    &userID,
 		CreatedAt:   now,
 		UpdatedAt:   now,
-	})
+	}
+	if err := h.campgrounds.Create(r.Context(), &c); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to create campground")
+		return
+	}
+	h.locate(c.ID, req.Location, req.Latitude)
+
+	respondJSON(w, http.StatusCreated, c)
 }
 
 func (h *CampgroundHandler) Update(w http.ResponseWriter, r *http.Request) {
-	userID := middleware.GetUserID(r)
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid campground ID")
+		respondError(w, r, http.StatusBadRequest, problem.InvalidParameter, "Invalid campground ID")
 		return
 	}
 
-	// Check ownership
-	var authorID *string
-	h.db.QueryRow(context.Background(), "SELECT author_id FROM campgrounds WHERE id = $1", id).Scan(&authorID)
-	if authorID == nil || *authorID != userID {
-		respondError(w, http.StatusForbidden, "You don't have permission to do that")
+	// Check permission
+	if !h.authorize(w, r, id, policy.ActionUpdate) {
 		return
 	}
 
 	var req models.UpdateCampgroundRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid request body")
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if errors.Is(err, models.ErrPriceText) {
+		problem.Write(w, r, problem.InvalidField("price", "price", "price must contain an amount, e.g. 15.00"))
+		return
+	}
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, problem.InvalidBody, "Invalid request body")
 		return
 	}
 
-	_, err = h.db.Exec(context.Background(), `
-		UPDATE campgrounds SET
-			name = COALESCE($1, name),
-			price = COALESCE($2, price),
-			image = COALESCE($3, image),
-			description = COALESCE($4, description),
-			location = COALESCE($5, location),
-			updated_at = $6
-		WHERE id = $7
-	`, req.Name, req.Price, req.Image, req.Description, req.Location, time.Now(), id)
+	if err := validator.Validate(req); err != nil {
+		respondValidationError(w, r, err)
+		return
+	}
 
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to update campground")
+	if err := h.campgrounds.Update(r.Context(), id, req, time.Now()); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to update campground")
 		return
 	}
+	h.locate(id, req.Location, req.Latitude)
 
 	respondJSON(w, http.StatusOK, map[string]string{"message": "Campground updated"})
 }
 
 func (h *CampgroundHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	userID := middleware.GetUserID(r)
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid campground ID")
+		respondError(w, r, http.StatusBadRequest, problem.InvalidParameter, "Invalid campground ID")
 		return
 	}
 
-	// Check ownership
-	var authorID *string
-	h.db.QueryRow(context.Background(), "SELECT author_id FROM campgrounds WHERE id = $1", id).Scan(&authorID)
-	if authorID == nil || *authorID != userID {
-		respondError(w, http.StatusForbidden, "You don't have permission to do that")
+	// Check permission
+	if !h.authorize(w, r, id, policy.ActionDelete) {
 		return
 	}
 
-	_, err = h.db.Exec(context.Background(), "DELETE FROM campgrounds WHERE id = $1", id)
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to delete campground")
+	if err := h.campgrounds.Delete(r.Context(), id); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to delete campground")
 		return
 	}
 
 	respondJSON(w, http.StatusOK, map[string]string{"message": "Campground deleted"})
 }
+
+// authorize writes the error response and returns false unless the caller
+// may perform action on the campground.
+func (h *CampgroundHandler) authorize(w http.ResponseWriter, r *http.Request, id int, action policy.Action) bool {
+	c, err := h.campgrounds.GetByID(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		respondError(w, r, http.StatusNotFound, problem.NotFound, "Campground not found")
+		return false
+	}
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Database error")
+		return false
+	}
+	if !policy.Can(currentUser(r), action, policy.Campground(c)) {
+		respondError(w, r, http.StatusForbidden, problem.Forbidden, "You don't have permission to do that")
+		return false
+	}
+	return true
+}
+
+// campgroundListParams are the query parameters List understands; any
+// other is rejected rather than silently ignored.
+var campgroundListParams = map[string]bool{
+	"page": true, "search": true, "sort": true, "author": true, "createdAfter": true, "createdBefore": true,
+	"has": true, "currency": true, "priceMin": true, "priceMax": true, "near": true, "radius": true, "bbox": true,
+}
+
+// sortParam reads the sort query parameter, one of store.CampgroundSorts,
+// into the filter. It writes the error response and returns false if it is
+// invalid.
+func sortParam(w http.ResponseWriter, r *http.Request, filter *store.CampgroundFilter) bool {
+	sort := store.CampgroundSort(r.URL.Query().Get("sort"))
+	switch {
+	case sort == store.SortDefault:
+		return true
+	case sort == store.SortRelevance && filter.Search == "":
+		problem.Write(w, r, problem.InvalidField("sort", "required_with", "sort=relevance needs search"))
+		return false
+	case (sort == store.SortPrice || sort == store.SortPriceDesc) && filter.Currency == "":
+		problem.Write(w, r, problem.InvalidField("sort", "required_with", "sort="+string(sort)+" needs currency"))
+		return false
+	case !slices.Contains(store.CampgroundSorts, sort):
+		names := make([]string, len(store.CampgroundSorts))
+		for i, s := range store.CampgroundSorts {
+			names[i] = string(s)
+		}
+		problem.Write(w, r, problem.InvalidField("sort", "oneof", "sort must be one of "+strings.Join(names, ", ")))
+		return false
+	}
+	filter.Sort = sort
+	return true
+}
+
+// timeParam reads an RFC 3339 time or a date, which means its start in UTC.
+// It returns the zero time if the parameter is missing.
+func timeParam(w http.ResponseWriter, r *http.Request, name string) (time.Time, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return time.Time{}, true
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(time.DateOnly, raw); err == nil {
+		return t, true
+	}
+	problem.Write(w, r, problem.InvalidField(name, "datetime", name+" must be an RFC 3339 time or a date like 2024-06-01"))
+	return time.Time{}, false
+}
+
+// priceParam reads an optional price bound in minor units from the query.
+// Minor units differ between currencies, so it needs currency as well. It
+// writes the error response and returns false if the value is invalid.
+func priceParam(w http.ResponseWriter, r *http.Request, name string) (*int64, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return nil, true
+	}
+	if r.URL.Query().Get("currency") == "" {
+		problem.Write(w, r, problem.InvalidField(name, "required_with", name+" needs currency"))
+		return nil, false
+	}
+	amount, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || amount < 0 {
+		problem.Write(w, r, problem.InvalidField(name, "number", name+" must be a whole number of minor units, e.g. cents"))
+		return nil, false
+	}
+	return &amount, true
+}
+
+// locate geocodes a new location in the background, unless the author gave
+// coordinates along with it.
+func (h *CampgroundHandler) locate(id int, location *string, latitude *float64) {
+	if h.locator != nil && location != nil && *location != "" && latitude == nil {
+		h.locator.Locate(id, *location)
+	}
+}
+
+const (
+	defaultRadiusKm = 50
+	maxRadiusKm     = 1000
+)
+
+// geoParams reads the near=lat,lng, radius=km and bbox=minLng,minLat,maxLng,maxLat
+// query parameters into the filter. It writes the error response and returns
+// false if any of them is invalid.
+func geoParams(w http.ResponseWriter, r *http.Request, filter *store.CampgroundFilter) bool {
+	query := r.URL.Query()
+	if raw := query.Get("bbox"); raw != "" {
+		box, err := geo.ParseBoundingBox(raw)
+		if err != nil {
+			problem.Write(w, r, problem.InvalidField("bbox", "bbox", "bbox "+err.Error()))
+			return false
+		}
+		filter.BBox = &box
+	}
+
+	raw := query.Get("near")
+	if raw == "" {
+		if query.Has("radius") {
+			problem.Write(w, r, problem.InvalidField("radius", "required_with", "radius needs near"))
+			return false
+		}
+		return true
+	}
+	near, err := geo.ParsePoint(raw)
+	if err != nil {
+		problem.Write(w, r, problem.InvalidField("near", "point", "near "+err.Error()))
+		return false
+	}
+	filter.Near, filter.RadiusKm = &near, defaultRadiusKm
+	if raw := query.Get("radius"); raw != "" {
+		radius, err := strconv.ParseFloat(raw, 64)
+		if err != nil || !(radius > 0 && radius <= maxRadiusKm) {
+			problem.Write(w, r, problem.InvalidField("radius", "range",
+				fmt.Sprintf("radius must be a number of km above 0 and at most %d", maxRadiusKm)))
+			return false
+		}
+		filter.RadiusKm = radius
+	}
+	return true
+}
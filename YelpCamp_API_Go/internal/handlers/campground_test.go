@@ -0,0 +1,362 @@
+package handlers_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/problem"
+)
+
+// createCampground creates a campground as the user behind token and
+// returns it.
+func (s *testServer) createCampground(token *http.Cookie, name string) models.Campground {
+	s.t.Helper()
+	w := s.do("POST", "/api/campgrounds/", `{"name":"`+name+`","price":{"amount":1500,"currency":"USD","unit":"night"},`+
+		`"image":"https://example.com/image.jpg","description":"A campground"}`, token)
+	if w.Code != http.StatusCreated {
+		s.t.Fatalf("create %s: status %d: %s", name, w.Code, w.Body)
+	}
+	var c models.Campground
+	decode(s.t, w, &c)
+	return c
+}
+
+func TestCampgroundLifecycle(t *testing.T) {
+	s := newTestServer(t)
+	bob, _ := s.register("bob")
+	alice, _ := s.register("alice")
+	c := s.createCampground(bob, "Pine Hollow")
+	path := fmt.Sprintf("/api/campgrounds/%d", c.ID)
+
+	if w := s.do("POST", path+"/comments", `{"text":"Lovely spot"}`, alice); w.Code != http.StatusCreated {
+		t.Fatalf("comment: status %d: %s", w.Code, w.Body)
+	}
+	var got models.Campground
+	decode(t, s.do("GET", path, ""), &got)
+	if got.Name != "Pine Hollow" || got.Author == nil || got.Author.Username != "bob" ||
+		len(got.Comments) != 1 || got.Comments[0].Text != "Lovely spot" {
+		t.Fatalf("get: %+v", got)
+	}
+
+	if want := (models.Price{Amount: 1500, Currency: "USD", Unit: models.PricePerNight}); got.Price != want {
+		t.Fatalf("price %+v, want %+v", got.Price, want)
+	}
+
+	// Only the author may change it
+	expectProblem(t, s.do("PUT", path, `{"name":"Mine now"}`, alice), http.StatusForbidden, problem.Forbidden)
+	expectProblem(t, s.do("DELETE", path, "", alice), http.StatusForbidden, problem.Forbidden)
+	expectProblem(t, s.do("PUT", path, `{"name":"Nope"}`), http.StatusUnauthorized, problem.Unauthorized)
+
+	if w := s.do("PUT", path, `{"name":"Pine Hollow East"}`, bob); w.Code != http.StatusOK {
+		t.Fatalf("update: status %d: %s", w.Code, w.Body)
+	}
+	decode(t, s.do("GET", path, ""), &got)
+	if got.Name != "Pine Hollow East" {
+		t.Fatalf("name after update %q", got.Name)
+	}
+
+	if w := s.do("DELETE", path, "", bob); w.Code != http.StatusOK {
+		t.Fatalf("delete: status %d: %s", w.Code, w.Body)
+	}
+	expectProblem(t, s.do("GET", path, ""), http.StatusNotFound, problem.NotFound)
+	expectProblem(t, s.do("POST", path+"/comments", `{"text":"Gone?"}`, alice), http.StatusNotFound, problem.NotFound)
+}
+
+func TestListCampgroundsSearchAndPages(t *testing.T) {
+	s := newTestServer(t)
+	bob, _ := s.register("bob")
+	for i := 1; i <= 13; i++ {
+		s.createCampground(bob, fmt.Sprintf("Site %02d", i))
+	}
+	s.createCampground(bob, "Lakeside")
+
+	list := func(query string) ([]string, models.Pagination) {
+		t.Helper()
+		w := s.do("GET", "/api/campgrounds/?"+query, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("list %q: status %d: %s", query, w.Code, w.Body)
+		}
+		var page struct {
+			Data       []models.Campground `json:"data"`
+			Pagination models.Pagination   `json:"pagination"`
+		}
+		decode(t, w, &page)
+		names := []string{}
+		for _, c := range page.Data {
+			names = append(names, c.Name)
+		}
+		return names, page.Pagination
+	}
+
+	names, pagination := list("")
+	if len(names) != 12 || pagination.Total != 14 || pagination.TotalPages != 2 || !pagination.HasMore {
+		t.Fatalf("first page: %q, %+v", names, pagination)
+	}
+	if names, pagination = list("page=2"); len(names) != 2 || pagination.HasMore {
+		t.Fatalf("second page: %q, %+v", names, pagination)
+	}
+	if names, _ = list("search=LAKE"); !reflect.DeepEqual(names, []string{"Lakeside"}) {
+		t.Fatalf("search: %q", names)
+	}
+}
+
+func TestCreateCampgroundInvalidPrice(t *testing.T) {
+	s := newTestServer(t)
+	bob, _ := s.register("bob")
+
+	w := s.do("POST", "/api/campgrounds/", `{"name":"Pine Hollow","price":{"amount":-5,"currency":"usd","unit":"week"},`+
+		`"image":"https://example.com/image.jpg","description":"A campground"}`, bob)
+	p := expectProblem(t, w, http.StatusBadRequest, problem.ValidationFailed)
+	var fields []string
+	for _, e := range p.Errors {
+		fields = append(fields, e.Field)
+	}
+	if want := []string{"price.amount", "price.currency", "price.unit"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("errors for %q, want %q", fields, want)
+	}
+}
+
+func TestCampgroundTextPrice(t *testing.T) {
+	s := newTestServer(t)
+	bob, _ := s.register("bob")
+	create := func(price string) *httptest.ResponseRecorder {
+		return s.do("POST", "/api/campgrounds/", `{"name":"Pine Hollow","price":`+price+`,`+
+			`"image":"https://example.com/image.jpg","description":"A campground"}`, bob)
+	}
+
+	// Older clients send and read the price as text in major units
+	w := create(`"€20 per person"`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body)
+	}
+	var body struct {
+		ID          int          `json:"id"`
+		Price       string       `json:"price"`
+		PriceDetail models.Price `json:"priceDetail"`
+	}
+	decode(t, w, &body)
+	if want := (models.Price{Amount: 2000, Currency: "EUR", Unit: models.PricePerPerson}); body.PriceDetail != want {
+		t.Errorf("price %+v, want %+v", body.PriceDetail, want)
+	}
+	if body.Price != "20.00" {
+		t.Errorf("price text %q, want 20.00", body.Price)
+	}
+
+	path := fmt.Sprintf("/api/campgrounds/%d", body.ID)
+	if w := s.do("PUT", path, `{"price":"15"}`, bob); w.Code != http.StatusOK {
+		t.Fatalf("update: status %d: %s", w.Code, w.Body)
+	}
+	decode(t, s.do("GET", path, ""), &body)
+	if want := (models.Price{Amount: 1500, Currency: "USD", Unit: models.PricePerNight}); body.PriceDetail != want || body.Price != "15.00" {
+		t.Errorf("after update: %q, %+v", body.Price, body.PriceDetail)
+	}
+
+	p := expectProblem(t, create(`"free"`), http.StatusBadRequest, problem.ValidationFailed)
+	if len(p.Errors) != 1 || p.Errors[0].Field != "price" {
+		t.Errorf("errors %+v, want one for price", p.Errors)
+	}
+}
+
+// seedCampgrounds stores four campgrounds by two authors, one a month from
+// January 2024 on, and a comment on Cedar Camp.
+func seedCampgrounds(t *testing.T, s *testServer) {
+	t.Helper()
+	ctx := context.Background()
+	for _, u := range []models.User{
+		{ID: "u1", Username: "alice", Email: "alice@example.com", Role: models.RoleUser},
+		{ID: "u2", Username: "bob", Email: "bob@example.com", Role: models.RoleUser},
+	} {
+		if err := s.stores.Users.Create(ctx, &u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	campgrounds := []struct {
+		name     string
+		amount   int64
+		currency string
+		authorID string
+	}{
+		{"Birch Flat", 2500, "USD", "u1"},
+		{"aspen Grove", 1500, "USD", "u2"},
+		{"Cedar Camp", 3000, "JPY", "u1"},
+		{"Dune Site", 1000, "USD", "u2"},
+	}
+	for i, c := range campgrounds {
+		created := time.Date(2024, time.Month(i+1), 10, 12, 0, 0, 0, time.UTC)
+		campground := models.Campground{
+			Name:        c.name,
+			Price:       models.Price{Amount: c.amount, Currency: c.currency, Unit: models.PricePerNight},
+			Image:       "https://example.com/image.jpg",
+			Description: "A campground",
+			AuthorID:    &campgrounds[i].authorID,
+			CreatedAt:   created,
+			UpdatedAt:   created,
+		}
+		if err := s.stores.Campgrounds.Create(ctx, &campground); err != nil {
+			t.Fatal(err)
+		}
+		if c.name == "Cedar Camp" {
+			comment := models.Comment{Text: "Nice", CampgroundID: campground.ID, AuthorID: &campgrounds[0].authorID,
+				CreatedAt: created, UpdatedAt: created}
+			if err := s.stores.Comments.Create(ctx, &comment); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestListCampgroundsByRating(t *testing.T) {
+	s := newTestServer(t)
+	alice, _ := s.register("alice")
+	bob, _ := s.register("bob")
+	ids := map[string]int{}
+	for _, name := range []string{"Aspen", "Birch", "Cedar"} {
+		ids[name] = s.createCampground(alice, name).ID
+	}
+	comment := func(name string, token *http.Cookie, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		return s.do("POST", fmt.Sprintf("/api/campgrounds/%d/comments", ids[name]), body, token)
+	}
+	for _, c := range []struct {
+		name  string
+		token *http.Cookie
+		body  string
+	}{
+		{"Aspen", alice, `{"text":"Great","rating":5}`},
+		{"Aspen", bob, `{"text":"Fine","rating":3}`},
+		{"Birch", bob, `{"text":"Perfect","rating":5}`},
+		{"Cedar", bob, `{"text":"Haven't stayed yet"}`},
+	} {
+		if w := comment(c.name, c.token, c.body); w.Code != http.StatusCreated {
+			t.Fatalf("comment on %s: status %d: %s", c.name, w.Code, w.Body)
+		}
+	}
+	p := expectProblem(t, comment("Cedar", bob, `{"text":"Too good","rating":6}`),
+		http.StatusBadRequest, problem.ValidationFailed)
+	if len(p.Errors) != 1 || p.Errors[0].Field != "rating" {
+		t.Errorf("errors %+v, want one for rating", p.Errors)
+	}
+
+	var aspen models.Campground
+	decode(t, s.do("GET", fmt.Sprintf("/api/campgrounds/%d", ids["Aspen"]), ""), &aspen)
+	if aspen.Rating == nil || *aspen.Rating != 4 || aspen.RatingCount != 2 {
+		t.Errorf("Aspen rating %v from %d ratings, want 4 from 2", aspen.Rating, aspen.RatingCount)
+	}
+
+	// Unrated campgrounds come last both ways
+	for query, want := range map[string][]string{
+		"sort=-rating": {"Birch", "Aspen", "Cedar"},
+		"sort=rating":  {"Aspen", "Birch", "Cedar"},
+	} {
+		var page struct {
+			Data []models.Campground `json:"data"`
+		}
+		decode(t, s.do("GET", "/api/campgrounds?"+query, ""), &page)
+		got := []string{}
+		for _, c := range page.Data {
+			got = append(got, c.Name)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %q, want %q", query, got, want)
+		}
+	}
+}
+
+func TestListCampgroundsFilterAndSort(t *testing.T) {
+	s := newTestServer(t)
+	seedCampgrounds(t, s)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"Dune Site", "Cedar Camp", "aspen Grove", "Birch Flat"}},
+		{"sort=-created_at", []string{"Dune Site", "Cedar Camp", "aspen Grove", "Birch Flat"}},
+		{"sort=created_at", []string{"Birch Flat", "aspen Grove", "Cedar Camp", "Dune Site"}},
+		{"sort=name", []string{"aspen Grove", "Birch Flat", "Cedar Camp", "Dune Site"}},
+		{"sort=-name", []string{"Dune Site", "Cedar Camp", "Birch Flat", "aspen Grove"}},
+		{"currency=usd&sort=price", []string{"Dune Site", "aspen Grove", "Birch Flat"}},
+		{"currency=USD&sort=-price", []string{"Birch Flat", "aspen Grove", "Dune Site"}},
+		{"currency=USD&priceMin=1500&priceMax=2500", []string{"aspen Grove", "Birch Flat"}},
+		{"author=alice", []string{"Cedar Camp", "Birch Flat"}},
+		{"author=alice&sort=name", []string{"Birch Flat", "Cedar Camp"}},
+		{"author=nobody", []string{}},
+		{"createdAfter=2024-02-10T12:00:00Z&createdBefore=2024-04-10T12:00:00Z", []string{"Cedar Camp", "aspen Grove"}},
+		{"createdAfter=2024-03-01", []string{"Dune Site", "Cedar Camp"}},
+		{"createdBefore=2024-02-01", []string{"Birch Flat"}},
+		{"has=comments", []string{"Cedar Camp"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := s.do("GET", "/api/campgrounds?"+tt.query, "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			var page struct {
+				Data []models.Campground `json:"data"`
+			}
+			decode(t, w, &page)
+			got := []string{}
+			for _, c := range page.Data {
+				got = append(got, c.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListCampgroundsInvalidQuery(t *testing.T) {
+	s := newTestServer(t)
+
+	tests := []struct {
+		query string
+		field string
+	}{
+		{"sort=bogus", "sort"},
+		{"sort=relevance", "sort"},
+		{"currency=USD&priceMax=-1", "priceMax"},
+		{"currency=dollars", "currency"},
+		{"currency=XYZ&sort=price", "currency"},
+		{"has=photos", "has"},
+		{"createdAfter=yesterday", "createdAfter"},
+		{"createdAfter=2024-03-01&createdBefore=2024-02-01", "createdBefore"},
+		{"radius=5", "radius"},
+		{"priceMin=100", "priceMin"},
+		{"sort=price", "sort"},
+		{"limit=100", "limit"},
+		{"page=10001", "page"},
+		{"page=99999999999999999999", "page"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			p := expectProblem(t, s.do("GET", "/api/campgrounds?"+tt.query, ""),
+				http.StatusBadRequest, problem.ValidationFailed)
+			if len(p.Errors) != 1 || p.Errors[0].Field != tt.field {
+				t.Errorf("errors %+v, want one for %s", p.Errors, tt.field)
+			}
+		})
+	}
+}
+
+func TestListCampgroundsUnknownParams(t *testing.T) {
+	s := newTestServer(t)
+
+	p := expectProblem(t, s.do("GET", "/api/campgrounds?page=1&order=name&filter=x", ""),
+		http.StatusBadRequest, problem.ValidationFailed)
+	var fields []string
+	for _, e := range p.Errors {
+		fields = append(fields, e.Field)
+	}
+	if !reflect.DeepEqual(fields, []string{"filter", "order"}) {
+		t.Errorf("errors for %q, want filter and order", fields)
+	}
+}
@@ -1,112 +1,102 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sangnn2012/yelpcamp-api-go/internal/middleware"
 	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/policy"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/problem"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
 	"github.com/sangnn2012/yelpcamp-api-go/pkg/validator"
 )
 
 type CommentHandler struct {
-	db *pgxpool.Pool
+	campgrounds store.CampgroundStore
+	comments    store.CommentStore
 }
 
-func NewCommentHandler(db *pgxpool.Pool) *CommentHandler {
-	return &CommentHandler{db: db}
+func NewCommentHandler(campgrounds store.CampgroundStore, comments store.CommentStore) *CommentHandler {
+	return &CommentHandler{campgrounds: campgrounds, comments: comments}
 }
 
 func (h *CommentHandler) Create(w http.ResponseWriter, r *http.Request) {
 	userID := middleware.GetUserID(r)
 	campgroundID, err := strconv.Atoi(chi.URLParam(r, "campgroundId"))
 	if err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid campground ID")
+		respondError(w, r, http.StatusBadRequest, problem.InvalidParameter, "Invalid campground ID")
 		return
 	}
 
 	// Check campground exists
-	var exists bool
-	h.db.QueryRow(context.Background(), "SELECT EXISTS(SELECT 1 FROM campgrounds WHERE id = $1)", campgroundID).Scan(&exists)
+	exists, err := h.campgrounds.Exists(r.Context(), campgroundID)
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Database error")
+		return
+	}
 	if !exists {
-		respondError(w, http.StatusNotFound, "Campground not found")
+		respondError(w, r, http.StatusNotFound, problem.NotFound, "Campground not found")
 		return
 	}
 
 	var req models.CreateCommentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid request body")
+		respondError(w, r, http.StatusBadRequest, problem.InvalidBody, "Invalid request body")
 		return
 	}
 
 	if err := validator.Validate(req); err != nil {
-		errors := validator.ValidationErrors(err)
-		respondError(w, http.StatusBadRequest, errors[0])
+		respondValidationError(w, r, err)
 		return
 	}
 
 	now := time.Now()
-	var id int
-	err = h.db.QueryRow(context.Background(), `
-		INSERT INTO comments (text, campground_id, author_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id
-	`, req.Text, campgroundID, userID, now, now).Scan(&id)
-
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to create comment")
-		return
-	}
-
-	respondJSON(w, http.StatusCreated, models.Comment{
-		ID:           id,
+	comment := models.Comment{
 		Text:         req.Text,
+		Rating:       req.Rating,
 		CampgroundID: campgroundID,
 		AuthorID:     &userID,
 		CreatedAt:    now,
 		UpdatedAt:    now,
-	})
+	}
+	if err := h.comments.Create(r.Context(), &comment); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to create comment")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, comment)
 }
 
 func (h *CommentHandler) Update(w http.ResponseWriter, r *http.Request) {
-	userID := middleware.GetUserID(r)
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid comment ID")
+		respondError(w, r, http.StatusBadRequest, problem.InvalidParameter, "Invalid comment ID")
 		return
 	}
 
-	// Check ownership
-	var authorID *string
-	h.db.QueryRow(context.Background(), "SELECT author_id FROM comments WHERE id = $1", id).Scan(&authorID)
-	if authorID == nil || *authorID != userID {
-		respondError(w, http.StatusForbidden, "You do not have permission to do that")
+	// Check permission
+	if !h.authorize(w, r, id, policy.ActionUpdate) {
 		return
 	}
 
 	var req models.UpdateCommentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid request body")
+		respondError(w, r, http.StatusBadRequest, problem.InvalidBody, "Invalid request body")
 		return
 	}
 
 	if err := validator.Validate(req); err != nil {
-		errors := validator.ValidationErrors(err)
-		respondError(w, http.StatusBadRequest, errors[0])
+		respondValidationError(w, r, err)
 		return
 	}
 
-	_, err = h.db.Exec(context.Background(), `
-		UPDATE comments SET text = $1, updated_at = $2 WHERE id = $3
-	`, req.Text, time.Now(), id)
-
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to update comment")
+	if err := h.comments.Update(r.Context(), id, req.Text, req.Rating, time.Now()); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to update comment")
 		return
 	}
 
@@ -114,26 +104,40 @@ func (h *CommentHandler) Update(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *CommentHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	userID := middleware.GetUserID(r)
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid comment ID")
+		respondError(w, r, http.StatusBadRequest, problem.InvalidParameter, "Invalid comment ID")
 		return
 	}
 
-	// Check ownership
-	var authorID *string
-	h.db.QueryRow(context.Background(), "SELECT author_id FROM comments WHERE id = $1", id).Scan(&authorID)
-	if authorID == nil || *authorID != userID {
-		respondError(w, http.StatusForbidden, "You do not have permission to do that")
+	// Check permission
+	if !h.authorize(w, r, id, policy.ActionDelete) {
 		return
 	}
 
-	_, err = h.db.Exec(context.Background(), "DELETE FROM comments WHERE id = $1", id)
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to delete comment")
+	if err := h.comments.Delete(r.Context(), id); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to delete comment")
 		return
 	}
 
 	respondJSON(w, http.StatusOK, map[string]string{"message": "Comment deleted"})
 }
+
+// authorize writes the error response and returns false unless the caller
+// may perform action on the comment.
+func (h *CommentHandler) authorize(w http.ResponseWriter, r *http.Request, id int, action policy.Action) bool {
+	comment, err := h.comments.GetByID(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		respondError(w, r, http.StatusNotFound, problem.NotFound, "Comment not found")
+		return false
+	}
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Database error")
+		return false
+	}
+	if !policy.Can(currentUser(r), action, policy.Comment(comment)) {
+		respondError(w, r, http.StatusForbidden, problem.Forbidden, "You do not have permission to do that")
+		return false
+	}
+	return true
+}
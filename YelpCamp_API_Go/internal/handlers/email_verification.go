@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/auth"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/middleware"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/problem"
+	"github.com/sangnn2012/yelpcamp-api-go/pkg/validator"
+)
+
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req models.VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, r, http.StatusBadRequest, problem.InvalidBody, "Invalid request body")
+		return
+	}
+
+	if err := validator.Validate(req); err != nil {
+		respondValidationError(w, r, err)
+		return
+	}
+
+	_, err := h.emails.Confirm(r.Context(), req.Token)
+	if errors.Is(err, auth.ErrInvalidToken) {
+		respondError(w, r, http.StatusBadRequest, problem.InvalidToken, "Invalid or expired verification token")
+		return
+	}
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to verify email")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Email verified"})
+}
+
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	user, err := h.users.GetByID(r.Context(), middleware.GetUserID(r))
+	if err != nil {
+		respondError(w, r, http.StatusNotFound, problem.NotFound, "User not found")
+		return
+	}
+
+	err = h.emails.Send(r.Context(), user)
+	var throttled *auth.ThrottledError
+	switch {
+	case errors.Is(err, auth.ErrAlreadyVerified):
+		respondError(w, r, http.StatusBadRequest, problem.EmailAlreadyVerified, "Email is already verified")
+		return
+	case errors.As(err, &throttled):
+		setRetryAfter(w, throttled.RetryAfter)
+		respondError(w, r, http.StatusTooManyRequests, problem.VerificationThrottled, "Verification email sent recently, "+throttled.Error())
+		return
+	case err != nil:
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to send verification email")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Verification email sent"})
+}
+
+func (h *AuthHandler) sendVerificationEmail(ctx context.Context, user models.User) {
+	if err := h.emails.Send(ctx, &user); err != nil {
+		log.Printf("email verification: send to %s: %v", user.ID, err)
+	}
+}
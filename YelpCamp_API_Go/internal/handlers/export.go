@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/export"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/middleware"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/problem"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+// ExportHandler lets users download a copy of their personal data. Exports
+// are built in the background: clients start one, poll its status and
+// download the archive once it's ready.
+type ExportHandler struct {
+	exporter *export.Exporter
+}
+
+func NewExportHandler(exporter *export.Exporter) *ExportHandler {
+	return &ExportHandler{exporter: exporter}
+}
+
+// Request starts an export, or returns the one already in progress.
+func (h *ExportHandler) Request(w http.ResponseWriter, r *http.Request) {
+	job, err := h.exporter.Request(r.Context(), middleware.GetUserID(r))
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to start export")
+		return
+	}
+
+	w.Header().Set("Location", "/api/me/export/"+job.ID)
+	respondJSON(w, http.StatusAccepted, job)
+}
+
+func (h *ExportHandler) Status(w http.ResponseWriter, r *http.Request) {
+	job, err := h.exporter.Get(r.Context(), middleware.GetUserID(r), chi.URLParam(r, "id"))
+	if errors.Is(err, store.ErrNotFound) {
+		respondError(w, r, http.StatusNotFound, problem.NotFound, "Export not found")
+		return
+	}
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Database error")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, job)
+}
+
+func (h *ExportHandler) Download(w http.ResponseWriter, r *http.Request) {
+	job, archive, err := h.exporter.Archive(r.Context(), middleware.GetUserID(r), chi.URLParam(r, "id"))
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		respondError(w, r, http.StatusNotFound, problem.NotFound, "Export not found")
+		return
+	case errors.Is(err, export.ErrNotReady):
+		respondError(w, r, http.StatusConflict, problem.ExportNotReady, "Export is not ready")
+		return
+	case err != nil:
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Database error")
+		return
+	}
+
+	filename := "yelpcamp-export-" + job.CreatedAt.Format("2006-01-02") + ".zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Content-Length", strconv.Itoa(len(archive)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(archive)
+}
@@ -0,0 +1,154 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/auth"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/background"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/handlers"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/mailer"
+	mw "github.com/sangnn2012/yelpcamp-api-go/internal/middleware"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/problem"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store/memory"
+)
+
+const testJWTSecret = "test-secret-of-at-least-32-chars"
+
+// testMaxFailures is the lockout threshold of the test server's login guard.
+const testMaxFailures = 3
+
+// testServer routes like cmd/server, but on the in-memory stores.
+type testServer struct {
+	t      *testing.T
+	router chi.Router
+	stores *store.Stores
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	stores := memory.New()
+	tokens := auth.NewTokenService(auth.NewHMACKeySet(testJWTSecret), "yelpcamp-api", "yelpcamp",
+		15*time.Minute, 24*time.Hour, stores.Users, stores.Sessions, stores.RefreshTokens)
+	mail := mailer.NewLogMailer("", "YelpCamp <no-reply@yelpcamp.test>")
+	emails := auth.NewEmailVerification(stores.Users, stores.Verifications, mail, "http://app.test", time.Hour, time.Minute)
+	twoFactor := auth.NewTwoFactor(stores.TwoFactors, stores.Verifications, "YelpCamp", 5*time.Minute)
+	apiKeys := auth.NewAPIKeys(stores.APIKeys, stores.Users)
+	tasks := background.NewRunner(time.Minute)
+	t.Cleanup(func() { tasks.Wait(context.Background()) })
+	guard := auth.NewLoginGuard(stores.Throttles, nil, tasks, auth.LoginGuardConfig{
+		MaxFailures:     testMaxFailures,
+		MaxIPFailures:   100,
+		LockoutDuration: time.Hour,
+		FailureWindow:   time.Hour,
+		BackoffBase:     time.Nanosecond,
+	})
+	authenticator := mw.NewAuthenticator(tokens, apiKeys)
+
+	authHandler := handlers.NewAuthHandler(stores.Users, tokens, emails, twoFactor, guard, tasks)
+	twoFactorHandler := handlers.NewTwoFactorHandler(stores.Users, twoFactor)
+	campgroundHandler := handlers.NewCampgroundHandler(stores.Campgrounds, stores.Comments, nil)
+	commentHandler := handlers.NewCommentHandler(stores.Campgrounds, stores.Comments)
+
+	r := chi.NewRouter()
+	r.NotFound(problem.NotFoundHandler)
+	r.MethodNotAllowed(problem.MethodNotAllowedHandler)
+	r.Route("/api/auth", func(r chi.Router) {
+		r.Post("/register", authHandler.Register)
+		r.Post("/login", authHandler.Login)
+		r.Post("/login/2fa", authHandler.LoginTwoFactor)
+		r.Post("/refresh", authHandler.Refresh)
+		r.With(authenticator.RequireAuth).Get("/me", authHandler.Me)
+		r.Group(func(r chi.Router) {
+			r.Use(authenticator.RequireAuth)
+			r.Use(mw.RequireSession)
+			r.Post("/2fa/enroll", twoFactorHandler.Enroll)
+			r.Post("/2fa/confirm", twoFactorHandler.Confirm)
+		})
+	})
+	r.Route("/api/campgrounds", func(r chi.Router) {
+		r.Get("/", campgroundHandler.List)
+		r.Get("/{id}", campgroundHandler.GetByID)
+		r.Group(func(r chi.Router) {
+			r.Use(authenticator.RequireAuth)
+			r.Post("/", campgroundHandler.Create)
+			r.Put("/{id}", campgroundHandler.Update)
+			r.Delete("/{id}", campgroundHandler.Delete)
+			r.Post("/{campgroundId}/comments", commentHandler.Create)
+		})
+	})
+
+	return &testServer{t: t, router: r, stores: stores}
+}
+
+func (s *testServer) do(method, path, body string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	s.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// register signs up a user with password "secret1" and returns the access
+// and refresh token cookies.
+func (s *testServer) register(username string) (access, refresh *http.Cookie) {
+	s.t.Helper()
+	w := s.do("POST", "/api/auth/register",
+		`{"username":"`+username+`","email":"`+username+`@example.com","password":"secret1"}`)
+	if w.Code != http.StatusCreated {
+		s.t.Fatalf("register %s: status %d: %s", username, w.Code, w.Body)
+	}
+	return mustCookie(s.t, w, "token"), mustCookie(s.t, w, "refresh_token")
+}
+
+func (s *testServer) login(username, password string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	return s.do("POST", "/api/auth/login", `{"username":"`+username+`","password":"`+password+`"}`)
+}
+
+func cookie(w *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == name && c.Value != "" {
+			return c
+		}
+	}
+	return nil
+}
+
+func mustCookie(t *testing.T, w *httptest.ResponseRecorder, name string) *http.Cookie {
+	t.Helper()
+	c := cookie(w, name)
+	if c == nil {
+		t.Fatalf("no %s cookie in response %d: %s", name, w.Code, w.Body)
+	}
+	return c
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode %s: %v", w.Body, err)
+	}
+}
+
+// expectProblem checks the status and problem code of an error response.
+func expectProblem(t *testing.T, w *httptest.ResponseRecorder, status int, code problem.Code) problem.Problem {
+	t.Helper()
+	var p problem.Problem
+	decode(t, w, &p)
+	if w.Code != status || p.Code != code {
+		t.Fatalf("got %d %s, want %d %s: %s", w.Code, p.Code, status, code, w.Body)
+	}
+	return p
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type HealthHandler struct {
+	db    Pinger
+	ready atomic.Bool
+}
+
+func NewHealthHandler(db Pinger) *HealthHandler {
+	h := &HealthHandler{db: db}
+	h.ready.Store(true)
+	return h
+}
+
+// SetReady flips the readiness probe; main clears it once shutdown begins so
+// load balancers stop routing new traffic while requests drain.
+func (h *HealthHandler) SetReady(ready bool) {
+	h.ready.Store(ready)
+}
+
+func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, map[string]string{"status": "ok", "service": "YelpCamp API (Go)"})
+}
+
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	if !h.ready.Load() {
+		respondJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "shutting down"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	if err := h.db.Ping(ctx); err != nil {
+		respondJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "database unavailable"})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
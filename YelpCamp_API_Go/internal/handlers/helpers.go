@@ -2,9 +2,19 @@ package handlers
 
 import (
 	"encoding/json"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
+	"time"
 
+	"github.com/sangnn2012/yelpcamp-api-go/internal/auth"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/middleware"
 	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/policy"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/problem"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+	"github.com/sangnn2012/yelpcamp-api-go/pkg/validator"
 )
 
 func respondJSON(w http.ResponseWriter, status int, data interface{}) {
@@ -13,6 +23,88 @@ func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
-func respondError(w http.ResponseWriter, status int, message string) {
-	respondJSON(w, status, models.ErrorResponse{Error: message})
+// respondError writes an RFC 7807 problem; see the problem package for the
+// codes.
+func respondError(w http.ResponseWriter, r *http.Request, status int, code problem.Code, detail string) {
+	problem.Write(w, r, problem.New(status, code, detail))
+}
+
+// respondValidationError writes a problem listing every invalid field.
+func respondValidationError(w http.ResponseWriter, r *http.Request, err error) {
+	problem.Write(w, r, problem.Validation(err))
+}
+
+// setRetryAfter tells the client how many whole seconds to wait.
+func setRetryAfter(w http.ResponseWriter, d time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+}
+
+// currentUser describes the authenticated caller for policy checks.
+func currentUser(r *http.Request) policy.User {
+	return policy.User{ID: middleware.GetUserID(r), Role: middleware.GetRole(r)}
+}
+
+// checkPassword writes the error response and returns false unless password
+// is the caller's current one. Accounts without a password, such as social
+// logins and forced resets, have nothing to check.
+func checkPassword(w http.ResponseWriter, r *http.Request, users store.UserStore, password string) (*models.User, bool) {
+	user, err := users.GetByID(r.Context(), middleware.GetUserID(r))
+	if err != nil {
+		respondError(w, r, http.StatusNotFound, problem.NotFound, "User not found")
+		return nil, false
+	}
+	if user.Password != "" && !auth.CheckPassword(user, password) {
+		respondError(w, r, http.StatusUnauthorized, problem.InvalidCredentials, "Invalid password")
+		return nil, false
+	}
+	return user, true
+}
+
+// knownParams rejects query parameters outside known, listing all of them.
+// It writes the error response and returns false if there are any.
+func knownParams(w http.ResponseWriter, r *http.Request, known map[string]bool) bool {
+	var errs []validator.FieldError
+	for name := range r.URL.Query() {
+		if !known[name] {
+			errs = append(errs, validator.FieldError{Field: name, Rule: "unknown", Message: name + " is not a supported parameter"})
+		}
+	}
+	if len(errs) == 0 {
+		return true
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	problem.Write(w, r, problem.InvalidFields(errs))
+	return false
+}
+
+// maxPage bounds ?page= so the offset computed from it can't overflow.
+const maxPage = 10000
+
+// pageParam reads the 1-based ?page= query parameter. It writes the error
+// response and returns false if the page is past maxPage.
+func pageParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	// Out of range numbers come back as the largest or smallest int
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page > maxPage {
+		problem.Write(w, r, problem.InvalidField("page", "max", "page must be at most "+strconv.Itoa(maxPage)))
+		return 0, false
+	}
+	if page < 1 {
+		page = 1
+	}
+	return page, true
+}
+
+func paginated(data interface{}, page, limit, total int) models.PaginatedResponse {
+	totalPages := (total + limit - 1) / limit
+	return models.PaginatedResponse{
+		Data: data,
+		Pagination: models.Pagination{
+			Page:       page,
+			Limit:      limit,
+			Total:      total,
+			TotalPages: totalPages,
+			HasMore:    page < totalPages,
+		},
+	}
 }
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/auth"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/oauth"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/problem"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oauthStateCookie     = "oauth_state"
+	oauthStateCookiePath = "/api/auth/oauth"
+	oauthStateTTL        = 10 * time.Minute
+)
+
+// OAuthHandler runs the browser side of social login. Start sends the
+// browser to the provider and Callback brings it back to the frontend,
+// signed in. Errors are reported to the frontend's /login page rather than
+// as JSON, since the browser is navigating.
+type OAuthHandler struct {
+	providers map[string]*oauth.Provider
+	social    *auth.SocialLogin
+	tokens    *auth.TokenService
+	twoFactor *auth.TwoFactor
+	stateKey  []byte
+	appURL    string
+}
+
+func NewOAuthHandler(providers []*oauth.Provider, social *auth.SocialLogin, tokens *auth.TokenService,
+	twoFactor *auth.TwoFactor, secret, appURL string) *OAuthHandler {
+	byName := make(map[string]*oauth.Provider, len(providers))
+	for _, p := range providers {
+		byName[p.Name()] = p
+	}
+	// Keep the state cookie key separate from the JWT signing key
+	key := sha256.Sum256([]byte("oauth-state:" + secret))
+	return &OAuthHandler{
+		providers: byName,
+		social:    social,
+		tokens:    tokens,
+		twoFactor: twoFactor,
+		stateKey:  key[:],
+		appURL:    strings.TrimSuffix(appURL, "/"),
+	}
+}
+
+// oauthState is what Start remembers for Callback, in a signed cookie.
+type oauthState struct {
+	Provider  string    `json:"provider"`
+	State     string    `json:"state"`
+	Nonce     string    `json:"nonce"`
+	Verifier  string    `json:"verifier"`
+	ReturnTo  string    `json:"returnTo"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Providers lists the configured provider names for the login page.
+func (h *OAuthHandler) Providers(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, 0, len(h.providers))
+	for name := range h.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	respondJSON(w, http.StatusOK, map[string][]string{"providers": names})
+}
+
+// Start redirects to the provider. ?returnTo=/path picks the frontend page
+// to land on afterwards.
+func (h *OAuthHandler) Start(w http.ResponseWriter, r *http.Request) {
+	provider, ok := h.providers[chi.URLParam(r, "provider")]
+	if !ok {
+		respondError(w, r, http.StatusNotFound, problem.NotFound, "Unknown provider")
+		return
+	}
+
+	st := oauthState{
+		Provider:  provider.Name(),
+		State:     oauth2.GenerateVerifier(),
+		Nonce:     oauth2.GenerateVerifier(),
+		Verifier:  oauth2.GenerateVerifier(),
+		ReturnTo:  safeReturnTo(r.URL.Query().Get("returnTo")),
+		ExpiresAt: time.Now().Add(oauthStateTTL),
+	}
+	target, err := provider.AuthCodeURL(r.Context(), st.State, st.Nonce, st.Verifier)
+	if err != nil {
+		log.Printf("oauth: start %s: %v", provider.Name(), err)
+		respondError(w, r, http.StatusBadGateway, problem.ProviderUnavailable, "Provider unavailable")
+		return
+	}
+
+	value, err := h.signState(st)
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to start login")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    value,
+		Path:     oauthStateCookiePath,
+		MaxAge:   int(oauthStateTTL.Seconds()),
+		HttpOnly: true,
+		// Lax, not Strict: the callback is a top-level navigation from the
+		// provider's site and must carry the cookie
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// Callback checks the provider's response against the state cookie, signs
+// the user in and redirects to the frontend.
+func (h *OAuthHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := h.providers[chi.URLParam(r, "provider")]
+	if !ok {
+		respondError(w, r, http.StatusNotFound, problem.NotFound, "Unknown provider")
+		return
+	}
+
+	st, err := h.readState(r)
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    "",
+		Path:     oauthStateCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+	query := r.URL.Query()
+	if err != nil || st.Provider != provider.Name() ||
+		subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(st.State)) != 1 {
+		h.redirectError(w, r, "invalid_state")
+		return
+	}
+	if query.Get("error") != "" {
+		h.redirectError(w, r, "access_denied")
+		return
+	}
+
+	identity, token, err := provider.Exchange(r.Context(), query.Get("code"), st.Verifier, st.Nonce)
+	if err != nil {
+		log.Printf("oauth: callback %s: %v", provider.Name(), err)
+		h.redirectError(w, r, "provider_error")
+		return
+	}
+
+	user, err := h.social.Resolve(r.Context(), provider.Name(), identity, token)
+	if errors.Is(err, auth.ErrEmailNotVerified) {
+		h.redirectError(w, r, "email_not_verified")
+		return
+	}
+	if err != nil {
+		log.Printf("oauth: resolve %s user: %v", provider.Name(), err)
+		h.redirectError(w, r, "server_error")
+		return
+	}
+
+	// The provider stands in for the password, not for the second factor
+	if user.TwoFactorEnabled {
+		challenge, err := h.twoFactor.StartChallenge(r.Context(), user.ID)
+		if err != nil {
+			h.redirectError(w, r, "server_error")
+			return
+		}
+		fragment := url.Values{"challengeToken": {challenge}, "returnTo": {st.ReturnTo}}
+		http.Redirect(w, r, h.appURL+"/login/2fa#"+fragment.Encode(), http.StatusFound)
+		return
+	}
+
+	err = startSession(w, r, h.tokens, user)
+	if errors.Is(err, auth.ErrSuspended) {
+		h.redirectError(w, r, "account_suspended")
+		return
+	}
+	if err != nil {
+		h.redirectError(w, r, "server_error")
+		return
+	}
+	http.Redirect(w, r, h.appURL+st.ReturnTo, http.StatusFound)
+}
+
+func (h *OAuthHandler) redirectError(w http.ResponseWriter, r *http.Request, code string) {
+	http.Redirect(w, r, h.appURL+"/login?error="+url.QueryEscape(code), http.StatusFound)
+}
+
+func (h *OAuthHandler) signState(st oauthState) (string, error) {
+	payload, err := json.Marshal(st)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(h.stateMAC(encoded)), nil
+}
+
+func (h *OAuthHandler) readState(r *http.Request) (*oauthState, error) {
+	cookie, err := r.Cookie(oauthStateCookie)
+	if err != nil {
+		return nil, err
+	}
+	encoded, sig, ok := strings.Cut(cookie.Value, ".")
+	if !ok {
+		return nil, errors.New("malformed state cookie")
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, h.stateMAC(encoded)) {
+		return nil, errors.New("bad state cookie signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	var st oauthState
+	if err := json.Unmarshal(payload, &st); err != nil {
+		return nil, err
+	}
+	if time.Now().After(st.ExpiresAt) {
+		return nil, errors.New("state cookie expired")
+	}
+	return &st, nil
+}
+
+func (h *OAuthHandler) stateMAC(encoded string) []byte {
+	mac := hmac.New(sha256.New, h.stateKey)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
+
+// safeReturnTo only allows paths on the frontend, so the flow can't be used
+// to redirect to another site.
+func safeReturnTo(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.Contains(path, `\`) {
+		return "/"
+	}
+	return path
+}
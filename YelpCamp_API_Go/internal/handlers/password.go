@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/auth"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/background"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/problem"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+	"github.com/sangnn2012/yelpcamp-api-go/pkg/validator"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type PasswordHandler struct {
+	users   store.UserStore
+	tokens  *auth.TokenService
+	apiKeys *auth.APIKeys
+	resets  *auth.PasswordReset
+	tasks   *background.Runner
+}
+
+func NewPasswordHandler(users store.UserStore, tokens *auth.TokenService, apiKeys *auth.APIKeys,
+	resets *auth.PasswordReset, tasks *background.Runner) *PasswordHandler {
+	return &PasswordHandler{users: users, tokens: tokens, apiKeys: apiKeys, resets: resets, tasks: tasks}
+}
+
+// Forgot always answers the same way so it can't be used to find out which
+// emails are registered; the email is sent in the background for the same
+// reason.
+func (h *PasswordHandler) Forgot(w http.ResponseWriter, r *http.Request) {
+	var req models.ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, r, http.StatusBadRequest, problem.InvalidBody, "Invalid request body")
+		return
+	}
+
+	if err := validator.Validate(req); err != nil {
+		respondValidationError(w, r, err)
+		return
+	}
+
+	h.tasks.Go(func(ctx context.Context) { h.sendResetEmail(ctx, req.Email) })
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"message": "If that email is registered, a password reset link has been sent",
+	})
+}
+
+func (h *PasswordHandler) Reset(w http.ResponseWriter, r *http.Request) {
+	var req models.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, r, http.StatusBadRequest, problem.InvalidBody, "Invalid request body")
+		return
+	}
+
+	if err := validator.Validate(req); err != nil {
+		respondValidationError(w, r, err)
+		return
+	}
+
+	userID, err := h.resets.Consume(r.Context(), req.Token)
+	if errors.Is(err, auth.ErrInvalidToken) {
+		respondError(w, r, http.StatusBadRequest, problem.InvalidToken, "Invalid or expired reset token")
+		return
+	}
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Database error")
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to hash password")
+		return
+	}
+	if err := h.users.UpdatePassword(r.Context(), userID, string(hashedPassword)); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to reset password")
+		return
+	}
+
+	// Whoever knew the old password may still be signed in, or hold a key
+	if err := h.tokens.EndAllSessions(r.Context(), userID, ""); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to revoke sessions")
+		return
+	}
+	if err := h.apiKeys.RevokeAll(r.Context(), userID); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to revoke API keys")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Password has been reset"})
+}
+
+func (h *PasswordHandler) sendResetEmail(ctx context.Context, email string) {
+	user, err := h.users.GetByEmail(ctx, email)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			log.Printf("password reset: lookup failed: %v", err)
+		}
+		return
+	}
+
+	if err := h.resets.Send(ctx, user); err != nil {
+		log.Printf("password reset: send to %s: %v", user.ID, err)
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/auth"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/middleware"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/problem"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+type SessionHandler struct {
+	tokens *auth.TokenService
+}
+
+func NewSessionHandler(tokens *auth.TokenService) *SessionHandler {
+	return &SessionHandler{tokens: tokens}
+}
+
+func (h *SessionHandler) List(w http.ResponseWriter, r *http.Request) {
+	sessions, err := h.tokens.ListSessions(r.Context(), middleware.GetUserID(r), middleware.GetSessionID(r))
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Database error")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, sessions)
+}
+
+func (h *SessionHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	sessionID := chi.URLParam(r, "id")
+
+	err := h.tokens.EndSession(r.Context(), userID, sessionID)
+	if errors.Is(err, store.ErrNotFound) {
+		respondError(w, r, http.StatusNotFound, problem.NotFound, "Session not found")
+		return
+	}
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to revoke session")
+		return
+	}
+
+	if sessionID == middleware.GetSessionID(r) {
+		clearAuthCookies(w)
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Session revoked"})
+}
+
+// RevokeAll signs the user out everywhere, including this session.
+func (h *SessionHandler) RevokeAll(w http.ResponseWriter, r *http.Request) {
+	if err := h.tokens.EndAllSessions(r.Context(), middleware.GetUserID(r), ""); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to revoke sessions")
+		return
+	}
+
+	clearAuthCookies(w)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "All sessions revoked"})
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/auth"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/middleware"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/problem"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+	"github.com/sangnn2012/yelpcamp-api-go/pkg/validator"
+)
+
+// TwoFactorHandler lets signed-in users manage TOTP two-factor
+// authentication. The login side lives on AuthHandler.
+type TwoFactorHandler struct {
+	users     store.UserStore
+	twoFactor *auth.TwoFactor
+}
+
+func NewTwoFactorHandler(users store.UserStore, twoFactor *auth.TwoFactor) *TwoFactorHandler {
+	return &TwoFactorHandler{users: users, twoFactor: twoFactor}
+}
+
+// Enroll returns a new secret and its otpauth:// URI. Two-factor stays off
+// until the first code is confirmed.
+func (h *TwoFactorHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	user, err := h.users.GetByID(r.Context(), middleware.GetUserID(r))
+	if err != nil {
+		respondError(w, r, http.StatusNotFound, problem.NotFound, "User not found")
+		return
+	}
+
+	secret, uri, err := h.twoFactor.Enroll(r.Context(), user)
+	if errors.Is(err, auth.ErrTwoFactorEnabled) {
+		respondError(w, r, http.StatusBadRequest, problem.TwoFactorAlreadyEnabled, "Two-factor authentication is already enabled")
+		return
+	}
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to enroll two-factor authentication")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.TwoFactorEnrollResponse{Secret: secret, OTPAuthURI: uri})
+}
+
+func (h *TwoFactorHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	var req models.TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, r, http.StatusBadRequest, problem.InvalidBody, "Invalid request body")
+		return
+	}
+
+	if err := validator.Validate(req); err != nil {
+		respondValidationError(w, r, err)
+		return
+	}
+
+	codes, err := h.twoFactor.Confirm(r.Context(), middleware.GetUserID(r), req.Code)
+	switch {
+	case errors.Is(err, auth.ErrTwoFactorNotEnabled):
+		respondError(w, r, http.StatusBadRequest, problem.TwoFactorNotEnrolled, "Start two-factor enrollment first")
+		return
+	case errors.Is(err, auth.ErrTwoFactorEnabled):
+		respondError(w, r, http.StatusBadRequest, problem.TwoFactorAlreadyEnabled, "Two-factor authentication is already enabled")
+		return
+	case errors.Is(err, auth.ErrInvalidCode):
+		respondError(w, r, http.StatusBadRequest, problem.InvalidTwoFactorCode, "Invalid two-factor code")
+		return
+	case err != nil:
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to enable two-factor authentication")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *TwoFactorHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.confirmPassword(w, r)
+	if !ok {
+		return
+	}
+	if !user.TwoFactorEnabled {
+		respondError(w, r, http.StatusBadRequest, problem.TwoFactorNotEnabled, "Two-factor authentication is not enabled")
+		return
+	}
+
+	if err := h.twoFactor.Disable(r.Context(), user.ID); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to disable two-factor authentication")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Two-factor authentication disabled"})
+}
+
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.confirmPassword(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := h.twoFactor.RegenerateRecoveryCodes(r.Context(), user)
+	if errors.Is(err, auth.ErrTwoFactorNotEnabled) {
+		respondError(w, r, http.StatusBadRequest, problem.TwoFactorNotEnabled, "Two-factor authentication is not enabled")
+		return
+	}
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to generate recovery codes")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// confirmPassword writes the error response and returns false unless the
+// request body carries the caller's current password.
+func (h *TwoFactorHandler) confirmPassword(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	var req models.PasswordConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, r, http.StatusBadRequest, problem.InvalidBody, "Invalid request body")
+		return nil, false
+	}
+
+	if err := validator.Validate(req); err != nil {
+		respondValidationError(w, r, err)
+		return nil, false
+	}
+
+	return checkPassword(w, r, h.users, req.Password)
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/middleware"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/problem"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+	"github.com/sangnn2012/yelpcamp-api-go/pkg/validator"
+)
+
+const (
+	profileCampgroundsPageSize = 12
+	profileCommentsPageSize    = 20
+)
+
+// UserHandler serves public profiles and lets users edit their own.
+type UserHandler struct {
+	users       store.UserStore
+	campgrounds store.CampgroundStore
+	comments    store.CommentStore
+}
+
+func NewUserHandler(users store.UserStore, campgrounds store.CampgroundStore, comments store.CommentStore) *UserHandler {
+	return &UserHandler{users: users, campgrounds: campgrounds, comments: comments}
+}
+
+func (h *UserHandler) Profile(w http.ResponseWriter, r *http.Request) {
+	profile, err := h.users.GetProfile(r.Context(), chi.URLParam(r, "username"))
+	if errors.Is(err, store.ErrNotFound) {
+		respondError(w, r, http.StatusNotFound, problem.NotFound, "User not found")
+		return
+	}
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Database error")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, profile)
+}
+
+func (h *UserHandler) Campgrounds(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.loadUser(w, r)
+	if !ok {
+		return
+	}
+
+	page, ok := pageParam(w, r)
+	if !ok {
+		return
+	}
+	campgrounds, total, err := h.campgrounds.List(r.Context(), store.CampgroundFilter{
+		AuthorID: user.ID,
+		Limit:    profileCampgroundsPageSize,
+		Offset:   (page - 1) * profileCampgroundsPageSize,
+	})
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Database error")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, paginated(campgrounds, page, profileCampgroundsPageSize, total))
+}
+
+func (h *UserHandler) Comments(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.loadUser(w, r)
+	if !ok {
+		return
+	}
+
+	page, ok := pageParam(w, r)
+	if !ok {
+		return
+	}
+	comments, total, err := h.comments.List(r.Context(), store.CommentFilter{
+		AuthorID: user.ID,
+		Limit:    profileCommentsPageSize,
+		Offset:   (page - 1) * profileCommentsPageSize,
+	})
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Database error")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, paginated(comments, page, profileCommentsPageSize, total))
+}
+
+// UpdateProfile changes the caller's public profile fields and returns
+// their updated account.
+func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+
+	var req models.UpdateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, r, http.StatusBadRequest, problem.InvalidBody, "Invalid request body")
+		return
+	}
+
+	if err := validator.Validate(req); err != nil {
+		respondValidationError(w, r, err)
+		return
+	}
+
+	if err := h.users.UpdateProfile(r.Context(), userID, req); err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Failed to update profile")
+		return
+	}
+
+	user, err := h.users.GetByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Database error")
+		return
+	}
+	respondJSON(w, http.StatusOK, user)
+}
+
+// loadUser writes the error response and returns false unless the user in
+// the URL exists.
+func (h *UserHandler) loadUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	user, err := h.users.GetByUsername(r.Context(), chi.URLParam(r, "username"))
+	if errors.Is(err, store.ErrNotFound) {
+		respondError(w, r, http.StatusNotFound, problem.NotFound, "User not found")
+		return nil, false
+	}
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, problem.Internal, "Database error")
+		return nil, false
+	}
+	return user, true
+}
@@ -0,0 +1,15 @@
+package mailer
+
+import "context"
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers plain-text email. SMTPMailer is used in production and
+// LogMailer for development and offline tests.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
@@ -2,68 +2,165 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
-	"os"
 	"strings"
 
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/auth"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/problem"
 )
 
 type contextKey string
 
-const UserIDKey contextKey = "userID"
+const (
+	UserIDKey    contextKey = "userID"
+	SessionIDKey contextKey = "sessionID"
+	RoleKey      contextKey = "role"
+	APIKeyKey    contextKey = "apiKey"
+)
 
-func RequireAuth(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Get token from Authorization header
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			// Try cookie
-			cookie, err := r.Cookie("token")
-			if err != nil {
-				http.Error(w, `{"error":"Authentication required"}`, http.StatusUnauthorized)
-				return
-			}
-			authHeader = "Bearer " + cookie.Value
-		}
+var (
+	errNoCredentials = problem.New(http.StatusUnauthorized, problem.Unauthorized, "Authentication required")
+	errInvalidFormat = problem.New(http.StatusUnauthorized, problem.InvalidToken, "Invalid token format")
+	errInvalidToken  = problem.New(http.StatusUnauthorized, problem.InvalidToken, "Invalid token")
+	errSessionLookup = problem.New(http.StatusInternalServerError, problem.Internal, "Failed to verify session")
+	errSuspended     = problem.New(http.StatusForbidden, problem.AccountSuspended, "Account suspended")
+	errReadOnlyKey   = problem.New(http.StatusForbidden, problem.ReadOnlyAPIKey, "API key is read-only")
+	errSessionNeeded = problem.New(http.StatusForbidden, problem.SessionRequired, "Log in to use this endpoint; API keys can't")
+)
 
-		// Parse token
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			http.Error(w, `{"error":"Invalid token format"}`, http.StatusUnauthorized)
-			return
-		}
+type Authenticator struct {
+	tokens  *auth.TokenService
+	apiKeys *auth.APIKeys
+}
 
-		// Validate token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
+func NewAuthenticator(tokens *auth.TokenService, apiKeys *auth.APIKeys) *Authenticator {
+	return &Authenticator{tokens: tokens, apiKeys: apiKeys}
+}
 
-		if err != nil || !token.Valid {
-			http.Error(w, `{"error":"Invalid token"}`, http.StatusUnauthorized)
+func (a *Authenticator) RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, err := a.authenticate(r)
+		if err != nil {
+			problem.Write(w, r, problem.From(err))
 			return
 		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
-		// Extract user ID from claims
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			http.Error(w, `{"error":"Invalid token claims"}`, http.StatusUnauthorized)
-			return
+// OptionalAuth adds the user to the context when the request carries valid
+// credentials and otherwise lets it through anonymously.
+func (a *Authenticator) OptionalAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ctx, err := a.authenticate(r); err == nil {
+			r = r.WithContext(ctx)
 		}
+		next.ServeHTTP(w, r)
+	})
+}
 
-		userID, ok := claims["sub"].(string)
-		if !ok {
-			http.Error(w, `{"error":"Invalid user ID in token"}`, http.StatusUnauthorized)
+// RequireSession goes after RequireAuth on endpoints that manage
+// credentials, so a leaked API key can't be used to mint more keys or
+// change how the account logs in.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if GetAPIKey(r) != nil {
+			problem.Write(w, r, errSessionNeeded)
 			return
 		}
-
-		// Add user ID to context
-		ctx := context.WithValue(r.Context(), UserIDKey, userID)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r)
 	})
 }
 
+func (a *Authenticator) authenticate(r *http.Request) (context.Context, error) {
+	// Get token from Authorization header
+	authHeader := r.Header.Get("Authorization")
+	if raw := strings.TrimPrefix(authHeader, "ApiKey "); raw != authHeader {
+		return a.authenticateAPIKey(r, raw)
+	}
+	if authHeader == "" {
+		// Try cookie
+		cookie, err := r.Cookie("token")
+		if err != nil {
+			return nil, errNoCredentials
+		}
+		authHeader = "Bearer " + cookie.Value
+	}
+
+	// Parse token
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokenString == authHeader {
+		return nil, errInvalidFormat
+	}
+
+	// Validate token and its session
+	claims, session, err := a.tokens.Authenticate(r.Context(), tokenString)
+	if errors.Is(err, auth.ErrInvalidToken) {
+		return nil, errInvalidToken
+	}
+	if err != nil {
+		return nil, errSessionLookup
+	}
+
+	// Add user, session and role to context
+	ctx := context.WithValue(r.Context(), UserIDKey, claims.Subject)
+	ctx = context.WithValue(ctx, SessionIDKey, session.ID)
+	ctx = context.WithValue(ctx, RoleKey, claims.Role)
+	return ctx, nil
+}
+
+// authenticateAPIKey authenticates as the key's user with their current
+// role. Read-only keys are limited to safe methods.
+func (a *Authenticator) authenticateAPIKey(r *http.Request, raw string) (context.Context, error) {
+	key, user, err := a.apiKeys.Authenticate(r.Context(), raw)
+	if errors.Is(err, auth.ErrInvalidToken) {
+		return nil, errInvalidToken
+	}
+	if errors.Is(err, auth.ErrSuspended) {
+		return nil, errSuspended
+	}
+	if err != nil {
+		return nil, errSessionLookup
+	}
+	if !isSafeMethod(r.Method) && !key.HasScope(models.APIKeyScopeWrite) {
+		return nil, errReadOnlyKey
+	}
+
+	ctx := context.WithValue(r.Context(), UserIDKey, user.ID)
+	ctx = context.WithValue(ctx, RoleKey, user.Role)
+	ctx = context.WithValue(ctx, APIKeyKey, key)
+	return ctx, nil
+}
+
+// isSafeMethod reports whether the method only reads.
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
 func GetUserID(r *http.Request) string {
 	userID, _ := r.Context().Value(UserIDKey).(string)
 	return userID
 }
+
+func GetSessionID(r *http.Request) string {
+	sessionID, _ := r.Context().Value(SessionIDKey).(string)
+	return sessionID
+}
+
+func GetRole(r *http.Request) models.Role {
+	role, _ := r.Context().Value(RoleKey).(models.Role)
+	return role
+}
+
+// GetAPIKey returns the API key the request authenticated with, or nil for
+// session logins.
+func GetAPIKey(r *http.Request) *models.APIKey {
+	key, _ := r.Context().Value(APIKeyKey).(*models.APIKey)
+	return key
+}
@@ -0,0 +1,134 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/auth"
+	mw "github.com/sangnn2012/yelpcamp-api-go/internal/middleware"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store/memory"
+)
+
+type authFixture struct {
+	authenticator *mw.Authenticator
+	tokens        *auth.TokenService
+	apiKeys       *auth.APIKeys
+	users         store.UserStore
+	user          *models.User
+}
+
+func newAuthFixture(t *testing.T) *authFixture {
+	t.Helper()
+	stores := memory.New()
+	keys := auth.NewHMACKeySet("test-secret-of-at-least-32-chars")
+	tokens := auth.NewTokenService(keys, "yelpcamp-api", "yelpcamp", 15*time.Minute, 24*time.Hour,
+		stores.Users, stores.Sessions, stores.RefreshTokens)
+	apiKeys := auth.NewAPIKeys(stores.APIKeys, stores.Users)
+
+	user := &models.User{ID: "u1", Username: "bob", Email: "bob@example.com", Role: models.RoleUser}
+	if err := stores.Users.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return &authFixture{
+		authenticator: mw.NewAuthenticator(tokens, apiKeys),
+		tokens:        tokens,
+		apiKeys:       apiKeys,
+		users:         stores.Users,
+		user:          user,
+	}
+}
+
+// apiKey creates a key for the fixture's user and returns it raw.
+func (f *authFixture) apiKey(t *testing.T, scopes []string, expiresAt *time.Time) string {
+	t.Helper()
+	_, raw, err := f.apiKeys.Create(context.Background(), f.user.ID, "script", scopes, expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// serve runs a request through handler, which is wrapped to report the
+// authenticated user in the X-User header.
+func serve(handler func(http.Handler) http.Handler, method, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-User", mw.GetUserID(r))
+	})).ServeHTTP(w, req)
+	return w
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	f := newAuthFixture(t)
+	read := "ApiKey " + f.apiKey(t, []string{models.APIKeyScopeRead}, nil)
+	write := "ApiKey " + f.apiKey(t, []string{models.APIKeyScopeWrite}, nil)
+	past := time.Now().Add(-time.Minute)
+	expired := "ApiKey " + f.apiKey(t, []string{models.APIKeyScopeWrite}, &past)
+
+	tests := []struct {
+		name, method, authorization string
+		want                        int
+	}{
+		{"read key reads", http.MethodGet, read, http.StatusOK},
+		{"read key heads", http.MethodHead, read, http.StatusOK},
+		{"read key can't post", http.MethodPost, read, http.StatusForbidden},
+		{"read key can't put", http.MethodPut, read, http.StatusForbidden},
+		{"read key can't delete", http.MethodDelete, read, http.StatusForbidden},
+		{"write key reads", http.MethodGet, write, http.StatusOK},
+		{"write key posts", http.MethodPost, write, http.StatusOK},
+		{"write key deletes", http.MethodDelete, write, http.StatusOK},
+		{"expired key", http.MethodGet, expired, http.StatusUnauthorized},
+		{"unknown key", http.MethodGet, "ApiKey yc_unknown", http.StatusUnauthorized},
+		{"no credentials", http.MethodGet, "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(f.authenticator.RequireAuth, tt.method, tt.authorization)
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if w.Code == http.StatusOK && w.Header().Get("X-User") != f.user.ID {
+				t.Errorf("authenticated as %q", w.Header().Get("X-User"))
+			}
+		})
+	}
+}
+
+func TestAPIKeySuspendedUser(t *testing.T) {
+	f := newAuthFixture(t)
+	key := "ApiKey " + f.apiKey(t, []string{models.APIKeyScopeRead}, nil)
+
+	now := time.Now()
+	if err := f.users.SetSuspended(context.Background(), f.user.ID, &now); err != nil {
+		t.Fatal(err)
+	}
+	if w := serve(f.authenticator.RequireAuth, http.MethodGet, key); w.Code != http.StatusForbidden {
+		t.Fatalf("status %d, want 403", w.Code)
+	}
+}
+
+func TestRequireSession(t *testing.T) {
+	f := newAuthFixture(t)
+	key := "ApiKey " + f.apiKey(t, []string{models.APIKeyScopeWrite}, nil)
+	tokens, err := f.tokens.StartSession(context.Background(), f.user, auth.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	both := func(next http.Handler) http.Handler { return f.authenticator.RequireAuth(mw.RequireSession(next)) }
+	if w := serve(both, http.MethodPost, key); w.Code != http.StatusForbidden {
+		t.Errorf("API key: status %d, want 403", w.Code)
+	}
+	if w := serve(both, http.MethodPost, "Bearer "+tokens.AccessToken); w.Code != http.StatusOK {
+		t.Errorf("session: status %d, want 200: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/problem"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+var errRateLimited = problem.New(http.StatusTooManyRequests, problem.RateLimited, "Too many requests, please slow down")
+
+// RatePolicy allows Limit requests per Period for each client, with bursts
+// of up to Limit. Name keeps the buckets of different policies apart.
+type RatePolicy struct {
+	Name   string
+	Limit  int
+	Period time.Duration
+}
+
+// RateLimiter applies token bucket policies. Clients are told their budget
+// in RateLimit-* headers (draft-ietf-httpapi-ratelimit-headers) and how long
+// to wait in Retry-After once it runs out.
+type RateLimiter struct {
+	buckets store.RateLimitStore
+}
+
+func NewRateLimiter(buckets store.RateLimitStore) *RateLimiter {
+	return &RateLimiter{buckets: buckets}
+}
+
+// Limit applies the policy to every request. Signed-in users are limited by
+// user ID, so it should run after the auth middleware where there is one;
+// everyone else is limited by IP address. If the backend fails, requests are
+// let through rather than taking the API down with it.
+func (l *RateLimiter) Limit(p RatePolicy) func(http.Handler) http.Handler {
+	rate := float64(p.Limit) / p.Period.Seconds()
+	policy := strconv.Itoa(p.Limit) + ";w=" + strconv.Itoa(int(math.Ceil(p.Period.Seconds())))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ok, tokens, err := l.buckets.Take(r.Context(), rateLimitKey(p.Name, r), p.Limit, p.Period, time.Now())
+			if err != nil {
+				log.Printf("rate limit %s: %v", p.Name, err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Policy", policy)
+			h.Set("RateLimit-Limit", strconv.Itoa(p.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(int(math.Floor(tokens))))
+			h.Set("RateLimit-Reset", secondsUntil(float64(p.Limit)-tokens, rate))
+			if !ok {
+				h.Set("Retry-After", secondsUntil(1-tokens, rate))
+				problem.Write(w, r, errRateLimited)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ByMethod applies the read policy to safe methods and the write policy to
+// everything else.
+func (l *RateLimiter) ByMethod(read, write RatePolicy) func(http.Handler) http.Handler {
+	limitRead, limitWrite := l.Limit(read), l.Limit(write)
+	return func(next http.Handler) http.Handler {
+		readNext, writeNext := limitRead(next), limitWrite(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isSafeMethod(r.Method) {
+				readNext.ServeHTTP(w, r)
+				return
+			}
+			writeNext.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Prune deletes buckets unused for longer than idle every interval, until
+// ctx is done. idle should be at least the longest policy period, after
+// which a bucket is full again and the same as a missing one.
+func (l *RateLimiter) Prune(ctx context.Context, interval, idle time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := l.buckets.DeleteIdle(ctx, now.Add(-idle)); err != nil && ctx.Err() == nil {
+				log.Printf("rate limit: prune buckets: %v", err)
+			}
+		}
+	}
+}
+
+func rateLimitKey(policy string, r *http.Request) string {
+	if userID := GetUserID(r); userID != "" {
+		return policy + ":user:" + userID
+	}
+	return policy + ":ip:" + remoteHost(r)
+}
+
+// secondsUntil is how many whole seconds it takes to refill tokens at rate
+// per second.
+func secondsUntil(tokens, rate float64) string {
+	return strconv.Itoa(int(math.Ceil(max(tokens, 0) / rate)))
+}
@@ -0,0 +1,119 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mw "github.com/sangnn2012/yelpcamp-api-go/internal/middleware"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store/memory"
+)
+
+func limitRequest(handler http.Handler, method, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/", nil)
+	req.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimit(t *testing.T) {
+	limiter := mw.NewRateLimiter(memory.NewRateLimitStore(memory.NewDB()))
+	handler := limiter.Limit(mw.RatePolicy{Name: "test", Limit: 2, Period: time.Minute})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i, remaining := range []string{"1", "0"} {
+		w := limitRequest(handler, http.MethodGet, "203.0.113.7:5000")
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i, w.Code)
+		}
+		h := w.Header()
+		if h.Get("RateLimit-Limit") != "2" || h.Get("RateLimit-Remaining") != remaining ||
+			h.Get("RateLimit-Policy") != "2;w=60" {
+			t.Errorf("request %d: headers %v", i, h)
+		}
+	}
+
+	// The bucket refills one token every 30 seconds
+	w := limitRequest(handler, http.MethodGet, "203.0.113.7:6000")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request: status %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Retry-After %q, want 30", got)
+	}
+	if got := w.Header().Get("RateLimit-Reset"); got != "60" {
+		t.Errorf("RateLimit-Reset %q, want 60", got)
+	}
+
+	// Other clients have their own bucket
+	if w := limitRequest(handler, http.MethodGet, "198.51.100.1:5000"); w.Code != http.StatusOK {
+		t.Fatalf("other client: status %d", w.Code)
+	}
+}
+
+func TestRateLimitByMethod(t *testing.T) {
+	limiter := mw.NewRateLimiter(memory.NewRateLimitStore(memory.NewDB()))
+	handler := limiter.ByMethod(
+		mw.RatePolicy{Name: "read", Limit: 2, Period: time.Minute},
+		mw.RatePolicy{Name: "write", Limit: 1, Period: time.Minute},
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	const client = "203.0.113.7:5000"
+	if w := limitRequest(handler, http.MethodPost, client); w.Code != http.StatusOK {
+		t.Fatalf("first write: status %d", w.Code)
+	}
+	if w := limitRequest(handler, http.MethodDelete, client); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second write: status %d, want 429", w.Code)
+	}
+	// Writing used up nothing of the read budget
+	for i := 0; i < 2; i++ {
+		if w := limitRequest(handler, http.MethodGet, client); w.Code != http.StatusOK {
+			t.Fatalf("read %d: status %d", i, w.Code)
+		}
+	}
+	if w := limitRequest(handler, http.MethodHead, client); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third read: status %d, want 429", w.Code)
+	}
+}
+
+func TestRateLimitStoreRefills(t *testing.T) {
+	buckets := memory.NewRateLimitStore(memory.NewDB())
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	take := func(at time.Duration) (bool, float64) {
+		t.Helper()
+		ok, tokens, err := buckets.Take(ctx, "k", 4, 4*time.Second, start.Add(at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok, tokens
+	}
+
+	// A burst of the full capacity, then nothing
+	for i := 0; i < 4; i++ {
+		if ok, _ := take(0); !ok {
+			t.Fatalf("burst request %d refused", i)
+		}
+	}
+	if ok, tokens := take(0); ok || tokens != 0 {
+		t.Fatalf("over capacity: ok %v, %v tokens", ok, tokens)
+	}
+	// One token a second comes back, but never more than the capacity
+	if ok, tokens := take(1500 * time.Millisecond); !ok || tokens != 0.5 {
+		t.Fatalf("after 1.5s: ok %v, %v tokens", ok, tokens)
+	}
+	if ok, tokens := take(time.Hour); !ok || tokens != 3 {
+		t.Fatalf("after an hour: ok %v, %v tokens", ok, tokens)
+	}
+
+	// Pruned buckets start full again
+	if err := buckets.DeleteIdle(ctx, start.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if ok, tokens := take(time.Hour); !ok || tokens != 3 {
+		t.Fatalf("after prune: ok %v, %v tokens", ok, tokens)
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/problem"
+)
+
+var errPanic = problem.New(http.StatusInternalServerError, problem.Internal, "Internal server error")
+
+// Recoverer turns a panic into a 500 problem response and logs the stack.
+// Like chi's Recoverer, it re-panics http.ErrAbortHandler so the server can
+// abort the response.
+func Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rvr := recover()
+			if rvr == nil {
+				return
+			}
+			if rvr == http.ErrAbortHandler {
+				panic(rvr)
+			}
+			log.Printf("panic serving %s %s: %v\n%s", r.Method, r.URL.Path, rvr, debug.Stack())
+			problem.Write(w, r, errPanic)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/problem"
+)
+
+var errNoPermission = problem.New(http.StatusForbidden, problem.Forbidden, "You don't have permission to do that")
+
+// RequireRole rejects users below the given role. It must run after
+// RequireAuth.
+func RequireRole(role models.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !GetRole(r).AtLeast(role) {
+				problem.Write(w, r, errNoPermission)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/problem"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+var (
+	errUserGone   = problem.New(http.StatusUnauthorized, problem.Unauthorized, "User not found")
+	errUnverified = problem.New(http.StatusForbidden, problem.EmailNotVerified, "Please verify your email first")
+)
+
+// RequireVerifiedEmail rejects users whose email is not verified yet. It
+// must run after RequireAuth.
+func RequireVerifiedEmail(users store.UserStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, err := users.GetByID(r.Context(), GetUserID(r))
+			if err != nil {
+				problem.Write(w, r, errUserGone)
+				return
+			}
+			if !user.EmailVerified {
+				problem.Write(w, r, errUnverified)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
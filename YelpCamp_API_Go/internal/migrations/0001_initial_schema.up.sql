@@ -0,0 +1,73 @@
+-- Mirrors the shared schema from YelpCamp_API_Hono/src/db/schema.ts. Tables
+-- use IF NOT EXISTS so a database already created by Drizzle can be adopted.
+
+CREATE TABLE IF NOT EXISTS users (
+    id               TEXT PRIMARY KEY,
+    username         VARCHAR(30)  NOT NULL UNIQUE,
+    display_username VARCHAR(30),
+    email            VARCHAR(255) NOT NULL UNIQUE,
+    name             VARCHAR(100),
+    email_verified   BOOLEAN DEFAULT FALSE,
+    image            TEXT,
+    created_at       TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at       TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- The Go API keeps the bcrypt hash on the user row.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password TEXT;
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id         TEXT PRIMARY KEY,
+    user_id    TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token      TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    ip_address TEXT,
+    user_agent TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS accounts (
+    id                       TEXT PRIMARY KEY,
+    user_id                  TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    account_id               TEXT NOT NULL,
+    provider_id              TEXT NOT NULL,
+    access_token             TEXT,
+    refresh_token            TEXT,
+    access_token_expires_at  TIMESTAMP,
+    refresh_token_expires_at TIMESTAMP,
+    scope                    TEXT,
+    password                 TEXT,
+    created_at               TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at               TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS verifications (
+    id         TEXT PRIMARY KEY,
+    identifier TEXT NOT NULL,
+    value      TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS campgrounds (
+    id          SERIAL PRIMARY KEY,
+    name        VARCHAR(100) NOT NULL,
+    price       VARCHAR(20)  NOT NULL,
+    image       TEXT NOT NULL,
+    description TEXT NOT NULL,
+    location    VARCHAR(200),
+    author_id   TEXT REFERENCES users(id) ON DELETE SET NULL,
+    created_at  TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS comments (
+    id            SERIAL PRIMARY KEY,
+    text          VARCHAR(500) NOT NULL,
+    campground_id INTEGER NOT NULL REFERENCES campgrounds(id) ON DELETE CASCADE,
+    author_id     TEXT REFERENCES users(id) ON DELETE SET NULL,
+    created_at    TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMP NOT NULL DEFAULT NOW()
+);
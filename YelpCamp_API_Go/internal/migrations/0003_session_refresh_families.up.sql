@@ -0,0 +1,10 @@
+CREATE UNIQUE INDEX sessions_token_idx ON sessions (token);
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+
+-- A refresh token family is now one session; deleting the session (logout,
+-- revocation) removes its refresh tokens. Tokens issued before sessions
+-- existed have no row to point at, so those users sign in again.
+DELETE FROM refresh_tokens;
+ALTER TABLE refresh_tokens
+    ADD CONSTRAINT refresh_tokens_family_id_fkey
+    FOREIGN KEY (family_id) REFERENCES sessions(id) ON DELETE CASCADE;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS verifications_value_idx;
+DROP INDEX IF EXISTS verifications_identifier_idx;
@@ -0,0 +1,2 @@
+CREATE INDEX verifications_identifier_idx ON verifications (identifier);
+CREATE INDEX verifications_value_idx ON verifications (value);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS accounts_user_id_idx;
+DROP INDEX IF EXISTS accounts_provider_account_idx;
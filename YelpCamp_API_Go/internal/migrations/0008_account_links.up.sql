@@ -0,0 +1,2 @@
+CREATE UNIQUE INDEX accounts_provider_account_idx ON accounts (provider_id, account_id);
+CREATE INDEX accounts_user_id_idx ON accounts (user_id);
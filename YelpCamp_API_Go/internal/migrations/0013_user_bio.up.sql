@@ -0,0 +1,2 @@
+-- name, display_username and image are part of the shared schema already.
+ALTER TABLE users ADD COLUMN bio VARCHAR(500);
@@ -0,0 +1,17 @@
+-- The text price keeps the amount in major units; currency and unit are lost.
+ALTER TABLE campgrounds ADD COLUMN IF NOT EXISTS price VARCHAR(20);
+
+UPDATE campgrounds SET price = CASE
+    WHEN price_currency IN ('BIF', 'CLP', 'DJF', 'GNF', 'ISK', 'JPY', 'KMF', 'KRW', 'PYG',
+                            'RWF', 'UGX', 'UYI', 'VND', 'VUV', 'XAF', 'XOF', 'XPF')
+        THEN price_amount::TEXT
+    WHEN price_currency IN ('BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND')
+        THEN to_char(price_amount / 1000.0, 'FM999999990.000')
+    ELSE to_char(price_amount / 100.0, 'FM999999990.00')
+END;
+
+ALTER TABLE campgrounds
+    ALTER COLUMN price SET NOT NULL,
+    DROP COLUMN IF EXISTS price_amount,
+    DROP COLUMN IF EXISTS price_currency,
+    DROP COLUMN IF EXISTS price_unit;
@@ -0,0 +1,39 @@
+-- Prices were free text such as "15", "$15/night" or "€20 per person". They
+-- become an amount in the currency's minor units, an ISO 4217 currency code
+-- and what the price is charged for. The backfill takes the first number in
+-- the text, the currency from a symbol or code (USD if there is none), and
+-- the unit from "site" or "person" (per night otherwise). Text without a
+-- number becomes 0.
+ALTER TABLE campgrounds
+    ADD COLUMN price_amount   BIGINT,
+    ADD COLUMN price_currency CHAR(3),
+    ADD COLUMN price_unit     VARCHAR(10);
+
+UPDATE campgrounds SET
+    price_currency = CASE
+        WHEN price LIKE '%€%' OR price ILIKE '%EUR%' THEN 'EUR'
+        WHEN price LIKE '%£%' OR price ILIKE '%GBP%' THEN 'GBP'
+        WHEN price LIKE '%¥%' OR price ILIKE '%JPY%' THEN 'JPY'
+        ELSE 'USD'
+    END,
+    price_unit = CASE
+        WHEN price ILIKE '%person%' THEN 'person'
+        WHEN price ILIKE '%site%' THEN 'site'
+        ELSE 'night'
+    END;
+
+-- JPY has no minor unit
+UPDATE campgrounds SET price_amount = COALESCE(ROUND(
+    substring(replace(price, ',', '') FROM '[0-9]+(?:\.[0-9]+)?')::NUMERIC
+    * CASE price_currency WHEN 'JPY' THEN 1 ELSE 100 END
+), 0);
+
+ALTER TABLE campgrounds
+    ALTER COLUMN price_amount SET NOT NULL,
+    ALTER COLUMN price_currency SET NOT NULL,
+    ALTER COLUMN price_unit SET NOT NULL,
+    ADD CONSTRAINT campgrounds_price_amount_check CHECK (price_amount >= 0),
+    ADD CONSTRAINT campgrounds_price_unit_check CHECK (price_unit IN ('night', 'site', 'person')),
+    DROP COLUMN price;
+
+CREATE INDEX campgrounds_price_idx ON campgrounds (price_currency, price_amount);
@@ -0,0 +1,3 @@
+ALTER TABLE campgrounds
+    DROP COLUMN IF EXISTS latitude,
+    DROP COLUMN IF EXISTS longitude;
@@ -0,0 +1,11 @@
+-- Coordinates are set together or not at all. Radius and bounding box
+-- searches filter on latitude first, then compute distances in plain SQL, so
+-- no extension is needed.
+ALTER TABLE campgrounds
+    ADD COLUMN latitude  DOUBLE PRECISION
+        CONSTRAINT campgrounds_latitude_check CHECK (latitude BETWEEN -90 AND 90),
+    ADD COLUMN longitude DOUBLE PRECISION
+        CONSTRAINT campgrounds_longitude_check CHECK (longitude BETWEEN -180 AND 180),
+    ADD CONSTRAINT campgrounds_coordinates_check CHECK ((latitude IS NULL) = (longitude IS NULL));
+
+CREATE INDEX campgrounds_coordinates_idx ON campgrounds (latitude, longitude);
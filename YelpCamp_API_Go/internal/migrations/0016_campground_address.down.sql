@@ -0,0 +1 @@
+ALTER TABLE campgrounds DROP COLUMN IF EXISTS address;
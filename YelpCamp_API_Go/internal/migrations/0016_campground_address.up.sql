@@ -0,0 +1,2 @@
+-- The normalized address the geocoder found for location.
+ALTER TABLE campgrounds ADD COLUMN address TEXT;
@@ -0,0 +1,9 @@
+-- Full-text search: name weighs most, then location, then description. The
+-- queries must use the same 'english' configuration to hit the index.
+ALTER TABLE campgrounds ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(location, '')), 'B') ||
+    setweight(to_tsvector('english', coalesce(description, '')), 'C')
+) STORED;
+
+CREATE INDEX campgrounds_search_idx ON campgrounds USING GIN (search_vector);
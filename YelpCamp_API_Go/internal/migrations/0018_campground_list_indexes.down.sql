@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS comments_campground_id_idx;
+DROP INDEX IF EXISTS campgrounds_name_idx;
+DROP INDEX IF EXISTS campgrounds_author_id_idx;
+DROP INDEX IF EXISTS campgrounds_created_at_idx;
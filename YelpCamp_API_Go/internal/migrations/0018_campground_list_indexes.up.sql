@@ -0,0 +1,5 @@
+-- Indexes for the campground list's filters and sorts.
+CREATE INDEX campgrounds_created_at_idx ON campgrounds (created_at);
+CREATE INDEX campgrounds_author_id_idx ON campgrounds (author_id, created_at);
+CREATE INDEX campgrounds_name_idx ON campgrounds (lower(name));
+CREATE INDEX comments_campground_id_idx ON comments (campground_id);
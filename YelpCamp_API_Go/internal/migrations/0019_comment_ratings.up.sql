@@ -0,0 +1,3 @@
+-- Comments can rate the campground from 1 to 5 stars; a campground's rating
+-- is the average of its comments' ratings.
+ALTER TABLE comments ADD COLUMN rating SMALLINT CHECK (rating BETWEEN 1 AND 5);
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS campgrounds_sync_price ON campgrounds;
+DROP FUNCTION IF EXISTS sync_campground_price();
+DROP FUNCTION IF EXISTS campground_price_text(BIGINT, CHAR(3));
+ALTER TABLE campgrounds DROP COLUMN IF EXISTS price;
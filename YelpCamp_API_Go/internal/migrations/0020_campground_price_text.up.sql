@@ -0,0 +1,60 @@
+-- Other services sharing the database still read and write the text price,
+-- so it comes back and a trigger keeps it in step with the structured
+-- columns both ways: writing those rewrites the text in major units, and
+-- writing only the text (or inserting without the structured columns)
+-- parses it like 0014 did.
+ALTER TABLE campgrounds ADD COLUMN price VARCHAR(20);
+
+CREATE FUNCTION campground_price_text(amount BIGINT, currency CHAR(3)) RETURNS VARCHAR AS $$
+    SELECT CASE
+        WHEN currency IN ('BIF', 'CLP', 'DJF', 'GNF', 'ISK', 'JPY', 'KMF', 'KRW', 'PYG',
+                          'RWF', 'UGX', 'UYI', 'VND', 'VUV', 'XAF', 'XOF', 'XPF')
+            THEN amount::TEXT
+        WHEN currency IN ('BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND')
+            THEN to_char(amount / 1000.0, 'FM999999990.000')
+        ELSE to_char(amount / 100.0, 'FM999999990.00')
+    END
+$$ LANGUAGE SQL IMMUTABLE;
+
+CREATE FUNCTION sync_campground_price() RETURNS TRIGGER AS $$
+DECLARE
+    from_text BOOLEAN;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        from_text := NEW.price_amount IS NULL;
+    ELSE
+        from_text := (NEW.price_amount, NEW.price_currency) IS NOT DISTINCT FROM (OLD.price_amount, OLD.price_currency)
+            AND NEW.price IS DISTINCT FROM OLD.price;
+    END IF;
+    IF NOT from_text THEN
+        NEW.price := campground_price_text(NEW.price_amount, NEW.price_currency);
+        RETURN NEW;
+    END IF;
+
+    NEW.price_currency := CASE
+        WHEN NEW.price LIKE '%€%' OR NEW.price ILIKE '%EUR%' THEN 'EUR'
+        WHEN NEW.price LIKE '%£%' OR NEW.price ILIKE '%GBP%' THEN 'GBP'
+        WHEN NEW.price LIKE '%¥%' OR NEW.price ILIKE '%JPY%' THEN 'JPY'
+        ELSE 'USD'
+    END;
+    NEW.price_unit := CASE
+        WHEN NEW.price ILIKE '%person%' THEN 'person'
+        WHEN NEW.price ILIKE '%site%' THEN 'site'
+        ELSE 'night'
+    END;
+    -- JPY has no minor unit
+    NEW.price_amount := COALESCE(ROUND(
+        substring(replace(NEW.price, ',', '') FROM '[0-9]+(?:\.[0-9]+)?')::NUMERIC
+        * CASE NEW.price_currency WHEN 'JPY' THEN 1 ELSE 100 END
+    ), 0);
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+UPDATE campgrounds SET price = campground_price_text(price_amount, price_currency);
+ALTER TABLE campgrounds ALTER COLUMN price SET NOT NULL;
+
+-- BEFORE, so NOT NULL is checked after the trigger filled the columns in
+CREATE TRIGGER campgrounds_sync_price
+    BEFORE INSERT OR UPDATE ON campgrounds
+    FOR EACH ROW EXECUTE FUNCTION sync_campground_price();
@@ -0,0 +1,212 @@
+package migrations
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_add_comments.up.sql":   {Data: []byte("CREATE TABLE comments ();")},
+		"0002_add_comments.down.sql": {Data: []byte("DROP TABLE comments;")},
+		"0010_add_index.up.sql":      {Data: []byte("CREATE INDEX i ON comments (id);")},
+		"0001_initial.up.sql":        {Data: []byte("CREATE TABLE users ();")},
+		"0001_initial.down.sql":      {Data: []byte("DROP TABLE users;")},
+		"README.md":                  {Data: []byte("not a migration")},
+	}
+	migrations, err := load(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Migration{
+		{Version: 1, Name: "initial", Up: "CREATE TABLE users ();", Down: "DROP TABLE users;"},
+		{Version: 2, Name: "add_comments", Up: "CREATE TABLE comments ();", Down: "DROP TABLE comments;"},
+		{Version: 10, Name: "add_index", Up: "CREATE INDEX i ON comments (id);"},
+	}
+	if !reflect.DeepEqual(migrations, want) {
+		t.Errorf("got %+v, want %+v", migrations, want)
+	}
+}
+
+func TestLoadRejectsBrokenPairs(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"conflicting names": {
+			"0001_initial.up.sql": {Data: []byte("SELECT 1;")},
+			"0001_other.down.sql": {Data: []byte("SELECT 1;")},
+		},
+		"down without up": {
+			"0001_initial.down.sql": {Data: []byte("SELECT 1;")},
+		},
+	}
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := load(fsys); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := load(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mig := range migrations {
+		if mig.Down == "" {
+			t.Errorf("migration %d_%s has no down script", mig.Version, mig.Name)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	m := &Migrator{migrations: []Migration{{Version: 1}, {Version: 2}, {Version: 5}, {Version: 7}}}
+
+	tests := []struct {
+		name            string
+		current, target int
+		want            []int
+		down            bool
+	}{
+		{"up from empty", 0, 7, []int{1, 2, 5, 7}, false},
+		{"up to a version", 1, 5, []int{2, 5}, false},
+		{"already there", 5, 5, nil, false},
+		{"down one", 7, 5, []int{7}, true},
+		{"down to a version", 7, 1, []int{7, 5, 2}, true},
+		{"down to empty", 5, 0, []int{5, 2, 1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps, down := m.plan(tt.current, tt.target)
+			var got []int
+			for _, mig := range steps {
+				got = append(got, mig.Version)
+			}
+			if !reflect.DeepEqual(got, tt.want) || down != tt.down {
+				t.Errorf("plan(%d, %d) = %v, down %v; want %v, down %v",
+					tt.current, tt.target, got, down, tt.want, tt.down)
+			}
+		})
+	}
+
+	for current, want := range map[int]int{7: 5, 5: 2, 2: 1, 1: 0, 0: 0} {
+		if got := m.previous(current); got != want {
+			t.Errorf("previous(%d) = %d, want %d", current, got, want)
+		}
+	}
+}
+
+// testMigrator connects to the database in TEST_DATABASE_URL, which is
+// emptied of all migrations before and after the test.
+func testMigrator(t *testing.T) *Migrator {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.To(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := m.To(context.Background(), 0); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	return m
+}
+
+func applied(t *testing.T, m *Migrator) []int {
+	t.Helper()
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions := []int{}
+	for _, s := range statuses {
+		if s.Applied {
+			versions = append(versions, s.Version)
+		}
+	}
+	return versions
+}
+
+func TestMigratorUpDownTo(t *testing.T) {
+	m := testMigrator(t)
+	ctx := context.Background()
+
+	if err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	all := applied(t, m)
+	if len(all) != len(m.migrations) || all[len(all)-1] != m.Latest() {
+		t.Fatalf("after up: applied %v", all)
+	}
+	// Up again is a no-op
+	if err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Down(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := applied(t, m); !reflect.DeepEqual(got, all[:len(all)-1]) {
+		t.Fatalf("after down: applied %v, want %v", got, all[:len(all)-1])
+	}
+
+	first := m.migrations[0].Version
+	if err := m.To(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if got := applied(t, m); !reflect.DeepEqual(got, []int{first}) {
+		t.Fatalf("after to %d: applied %v", first, got)
+	}
+
+	if err := m.To(ctx, m.Latest()+1); err == nil {
+		t.Fatal("migrating to an unknown version succeeded")
+	}
+}
+
+func TestMigratorWaitsForLock(t *testing.T) {
+	m := testMigrator(t)
+
+	conn, err := m.db.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Release()
+	if _, err := conn.Exec(context.Background(), "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another replica is migrating, so Up blocks until the lock is free
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := m.Up(ctx); err == nil {
+		t.Fatal("up succeeded while another connection held the lock")
+	}
+	if got := applied(t, m); len(got) != 0 {
+		t.Fatalf("applied %v while locked", got)
+	}
+
+	if _, err := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", lockID); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
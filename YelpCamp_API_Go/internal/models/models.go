@@ -1,33 +1,204 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Role grants a user privileges on top of owning their own content. Roles
+// are ordered: every role includes the privileges of the ones below it.
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+var roleRank = map[Role]int{RoleUser: 1, RoleModerator: 2, RoleAdmin: 3}
+
+func (r Role) Valid() bool {
+	_, ok := roleRank[r]
+	return ok
+}
+
+// AtLeast reports whether r includes the privileges of min.
+func (r Role) AtLeast(min Role) bool {
+	return r.Valid() && roleRank[r] >= roleRank[min]
+}
 
 type User struct {
-	ID        string    `json:"id"`
-	Username  string    `json:"username" validate:"required,min=3,max=30,alphanum"`
-	Email     string    `json:"email" validate:"required,email"`
-	Password  string    `json:"-"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID               string     `json:"id"`
+	Username         string     `json:"username" validate:"required,min=3,max=30,alphanum"`
+	Email            string     `json:"email" validate:"required,email"`
+	EmailVerified    bool       `json:"emailVerified"`
+	DisplayUsername  *string    `json:"displayUsername,omitempty"`
+	Name             *string    `json:"name,omitempty"`
+	Image            *string    `json:"image,omitempty"`
+	Bio              *string    `json:"bio,omitempty"`
+	TwoFactorEnabled bool       `json:"twoFactorEnabled"`
+	Role             Role       `json:"role"`
+	SuspendedAt      *time.Time `json:"suspendedAt,omitempty"`
+	Password         string     `json:"-"`
+	CreatedAt        time.Time  `json:"createdAt"`
+	UpdatedAt        time.Time  `json:"updatedAt"`
 }
 
 type Campground struct {
 	ID          int       `json:"id"`
 	Name        string    `json:"name" validate:"required,max=100"`
-	Price       string    `json:"price" validate:"required"`
+	Price       Price     `json:"priceDetail"`
 	Image       string    `json:"image" validate:"required,url"`
 	Description string    `json:"description" validate:"required,max=5000"`
 	Location    *string   `json:"location,omitempty" validate:"omitempty,max=200"`
+	Address     *string   `json:"address,omitempty"`
+	Latitude    *float64  `json:"latitude,omitempty"`
+	Longitude   *float64  `json:"longitude,omitempty"`
 	AuthorID    *string   `json:"authorId,omitempty"`
 	Author      *Author   `json:"author,omitempty"`
 	Comments    []Comment `json:"comments,omitempty"`
+	// Rating is the average of the comments' ratings, or nil if none rated.
+	Rating      *float64  `json:"rating,omitempty"`
+	RatingCount int       `json:"ratingCount"`
 	CreatedAt   time.Time `json:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt"`
+	// Distance is in kilometres from the point a list was searched near.
+	Distance *float64 `json:"distance,omitempty"`
+	// Highlight is an HTML excerpt of the description with the search
+	// matches in <mark> tags; everything else in it is escaped.
+	Highlight *string `json:"highlight,omitempty"`
+}
+
+// PriceUnit is what a campground's price is charged for.
+type PriceUnit string
+
+const (
+	PricePerNight  PriceUnit = "night"
+	PricePerSite   PriceUnit = "site"
+	PricePerPerson PriceUnit = "person"
+)
+
+// Price is an amount in the currency's minor units, e.g. cents for USD and
+// whole yen for JPY, so prices can be compared and sorted as integers.
+type Price struct {
+	Amount   int64     `json:"amount" validate:"gte=0,lte=100000000"`
+	Currency string    `json:"currency" validate:"required,iso4217"`
+	Unit     PriceUnit `json:"unit" validate:"required,oneof=night site person"`
+}
+
+// currencySymbols are used instead of the code when formatting prices.
+var currencySymbols = map[string]string{"USD": "$", "EUR": "€", "GBP": "£", "JPY": "¥"}
+
+// CurrencyExponent is the number of minor unit digits of an ISO 4217
+// currency: 2 for most, 0 for the likes of JPY, 3 for the likes of KWD.
+func CurrencyExponent(currency string) int {
+	switch currency {
+	case "BIF", "CLP", "DJF", "GNF", "ISK", "JPY", "KMF", "KRW", "PYG",
+		"RWF", "UGX", "UYI", "VND", "VUV", "XAF", "XOF", "XPF":
+		return 0
+	case "BHD", "IQD", "JOD", "KWD", "LYD", "OMR", "TND":
+		return 3
+	}
+	return 2
+}
+
+// Text is the amount in major units without currency or unit, e.g.
+// "15.00", as prices were stored before they had a currency. Older clients
+// and services still read it.
+func (p Price) Text() string {
+	exp := CurrencyExponent(p.Currency)
+	amount := strconv.FormatInt(p.Amount, 10)
+	if exp > 0 {
+		amount = strings.Repeat("0", max(exp+1-len(amount), 0)) + amount
+		amount = amount[:len(amount)-exp] + "." + amount[len(amount)-exp:]
+	}
+	return amount
+}
+
+// String formats the price for display, e.g. "$15.00/night" or
+// "CAD 20.00/site".
+func (p Price) String() string {
+	amount := p.Text()
+	if symbol, ok := currencySymbols[p.Currency]; ok {
+		amount = symbol + amount
+	} else {
+		amount = p.Currency + " " + amount
+	}
+	return amount + "/" + string(p.Unit)
+}
+
+// MarshalJSON adds the formatted price to responses.
+func (p Price) MarshalJSON() ([]byte, error) {
+	type price Price
+	return json.Marshal(struct {
+		price
+		Formatted string `json:"formatted"`
+	}{price(p), p.String()})
+}
+
+// ErrPriceText is returned for a text price without an amount.
+var ErrPriceText = errors.New("price text has no amount")
+
+var priceTextAmount = regexp.MustCompile(`[0-9]+(?:\.[0-9]+)?`)
+
+// UnmarshalJSON also reads the text prices older clients send, such as
+// "15.00" or "€20 per person", the way migration 0014 converted them: the
+// first number, the currency from a symbol or code (USD if there is none)
+// and the unit from "site" or "person" (per night otherwise).
+func (p *Price) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		type price Price
+		return json.Unmarshal(data, (*price)(p))
+	}
+
+	upper := strings.ToUpper(text)
+	switch {
+	case strings.Contains(text, "€") || strings.Contains(upper, "EUR"):
+		p.Currency = "EUR"
+	case strings.Contains(text, "£") || strings.Contains(upper, "GBP"):
+		p.Currency = "GBP"
+	case strings.Contains(text, "¥") || strings.Contains(upper, "JPY"):
+		p.Currency = "JPY"
+	default:
+		p.Currency = "USD"
+	}
+	switch {
+	case strings.Contains(upper, "PERSON"):
+		p.Unit = PricePerPerson
+	case strings.Contains(upper, "SITE"):
+		p.Unit = PricePerSite
+	default:
+		p.Unit = PricePerNight
+	}
+	number := priceTextAmount.FindString(strings.ReplaceAll(text, ",", ""))
+	major, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return ErrPriceText
+	}
+	p.Amount = int64(math.Round(major * math.Pow10(CurrencyExponent(p.Currency))))
+	return nil
+}
+
+// MarshalJSON sends the price both ways: as text under "price", which
+// older clients expect, and in full under "priceDetail".
+func (c Campground) MarshalJSON() ([]byte, error) {
+	type campground Campground
+	return json.Marshal(struct {
+		campground
+		Price string `json:"price"`
+	}{campground(c), c.Price.Text()})
 }
 
 type Comment struct {
 	ID           int       `json:"id"`
 	Text         string    `json:"text" validate:"required,max=500"`
+	Rating       *int      `json:"rating,omitempty" validate:"omitempty,gte=1,lte=5"`
 	CampgroundID int       `json:"campgroundId"`
 	AuthorID     *string   `json:"authorId,omitempty"`
 	Author       *Author   `json:"author,omitempty"`
@@ -36,8 +207,163 @@ type Comment struct {
 }
 
 type Author struct {
-	ID       string `json:"id"`
-	Username string `json:"username"`
+	ID              string  `json:"id"`
+	Username        string  `json:"username"`
+	DisplayUsername *string `json:"displayUsername,omitempty"`
+	Image           *string `json:"image,omitempty"`
+}
+
+// Profile is the public view of a user, without private details such as
+// their email or role.
+type Profile struct {
+	ID              string    `json:"id"`
+	Username        string    `json:"username"`
+	DisplayUsername *string   `json:"displayUsername,omitempty"`
+	Name            *string   `json:"name,omitempty"`
+	Image           *string   `json:"image,omitempty"`
+	Bio             *string   `json:"bio,omitempty"`
+	JoinedAt        time.Time `json:"joinedAt"`
+	CampgroundCount int       `json:"campgroundCount"`
+	CommentCount    int       `json:"commentCount"`
+}
+
+type Session struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"-"`
+	Token     string    `json:"-"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	IPAddress *string   `json:"ipAddress,omitempty"`
+	UserAgent *string   `json:"userAgent,omitempty"`
+	Current   bool      `json:"current"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Verification is a single-use token; Value holds the token hash and
+// Identifier names its purpose and subject, e.g. "password-reset:<user id>".
+type Verification struct {
+	ID         string
+	Identifier string
+	Value      string
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// Account links a user to an identity at an external OAuth/OIDC provider.
+// AccountID is the provider's subject identifier for the user.
+type Account struct {
+	ID                    string
+	UserID                string
+	ProviderID            string
+	AccountID             string
+	AccessToken           *string
+	RefreshToken          *string
+	AccessTokenExpiresAt  *time.Time
+	RefreshTokenExpiresAt *time.Time
+	Scope                 *string
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+}
+
+// TwoFactor holds a user's TOTP secret. It is enrolled but not yet in force
+// until ConfirmedAt is set. LastUsedStep is the most recent TOTP time step
+// accepted, so a code can't be replayed within its validity window.
+type TwoFactor struct {
+	UserID       string
+	Secret       string
+	ConfirmedAt  *time.Time
+	LastUsedStep *int64
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// AuditLog records an administrative action. ActorID is nil once the acting
+// admin's account is deleted.
+type AuditLog struct {
+	ID         string                 `json:"id"`
+	ActorID    *string                `json:"actorId,omitempty"`
+	Action     string                 `json:"action"`
+	TargetType string                 `json:"targetType"`
+	TargetID   string                 `json:"targetId"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	IPAddress  *string                `json:"ipAddress,omitempty"`
+	CreatedAt  time.Time              `json:"createdAt"`
+}
+
+// LoginThrottle counts recent failed logins for a key such as
+// "user:<username>" or "ip:<address>".
+type LoginThrottle struct {
+	Key          string
+	Failures     int
+	LastFailedAt time.Time
+	LockedUntil  *time.Time
+}
+
+type ExportStatus string
+
+const (
+	ExportPending ExportStatus = "pending"
+	ExportRunning ExportStatus = "running"
+	ExportReady   ExportStatus = "ready"
+	ExportFailed  ExportStatus = "failed"
+)
+
+// DataExport is a background job building an archive of everything stored
+// about a user. The archive itself is fetched separately once ready and is
+// deleted at ExpiresAt.
+type DataExport struct {
+	ID          string       `json:"id"`
+	UserID      string       `json:"-"`
+	Status      ExportStatus `json:"status"`
+	Error       *string      `json:"error,omitempty"`
+	Size        int          `json:"size,omitempty"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	StartedAt   *time.Time   `json:"startedAt,omitempty"`
+	CompletedAt *time.Time   `json:"completedAt,omitempty"`
+	ExpiresAt   *time.Time   `json:"expiresAt,omitempty"`
+}
+
+// API key scopes: read keys may only make safe (GET, HEAD, OPTIONS)
+// requests, write keys may also change data.
+const (
+	APIKeyScopeRead  = "read"
+	APIKeyScopeWrite = "write"
+)
+
+// APIKey is a long-lived credential a user creates for scripts. Only its
+// hash is stored; Prefix is kept so users can tell their keys apart.
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// HasScope reports whether the key was granted scope.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type RefreshToken struct {
+	ID         string
+	UserID     string
+	FamilyID   string
+	TokenHash  string
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+	ReplacedBy *string
+	CreatedAt  time.Time
 }
 
 // Request/Response types
@@ -52,28 +378,142 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=6"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+	Code           string `json:"code" validate:"required"`
+}
+
+// CurrentPassword may be left out only by accounts without a password,
+// e.g. ones created through social login.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword" validate:"required,min=6"`
+}
+
+type ChangeEmailRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password"`
+}
+
+// DeleteAccountRequest keeps the user's campgrounds and comments, without
+// an author, unless DeleteContent is set.
+type DeleteAccountRequest struct {
+	Password      string `json:"password"`
+	DeleteContent bool   `json:"deleteContent"`
+}
+
+// PasswordConfirmRequest re-checks the password before sensitive changes.
+// Accounts without a password leave it empty.
+type PasswordConfirmRequest struct {
+	Password string `json:"password"`
+}
+
+type TwoFactorEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauthUri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// TwoFactorChallengeResponse is returned by login instead of a session when
+// the user has two-factor authentication enabled.
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"twoFactorRequired"`
+	ChallengeToken    string `json:"challengeToken"`
+	ExpiresIn         int    `json:"expiresIn"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int    `json:"expiresIn"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=read write"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// APIKeyCreatedResponse carries the key itself, which is only ever shown in
+// this response.
+type APIKeyCreatedResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// UpdateProfileRequest changes only the fields that are present; an empty
+// string clears one.
+type UpdateProfileRequest struct {
+	DisplayUsername *string `json:"displayUsername,omitempty" validate:"omitempty,max=30"`
+	Name            *string `json:"name,omitempty" validate:"omitempty,max=100"`
+	Image           *string `json:"image,omitempty" validate:"omitempty,url"`
+	Bio             *string `json:"bio,omitempty" validate:"omitempty,max=500"`
+}
+
+type UpdateRoleRequest struct {
+	Role Role `json:"role" validate:"required,oneof=user moderator admin"`
+}
+
+type SuspendUserRequest struct {
+	Reason string `json:"reason,omitempty" validate:"max=500"`
+}
+
+// CreateCampgroundRequest takes the price as an object, like a campground's
+// priceDetail, or as text like its price.
 type CreateCampgroundRequest struct {
-	Name        string  `json:"name" validate:"required,max=100"`
-	Price       string  `json:"price" validate:"required"`
-	Image       string  `json:"image" validate:"required,url"`
-	Description string  `json:"description" validate:"required,max=5000"`
-	Location    *string `json:"location,omitempty" validate:"omitempty,max=200"`
+	Name        string   `json:"name" validate:"required,max=100"`
+	Price       Price    `json:"price"`
+	Image       string   `json:"image" validate:"required,url"`
+	Description string   `json:"description" validate:"required,max=5000"`
+	Location    *string  `json:"location,omitempty" validate:"omitempty,max=200"`
+	Latitude    *float64 `json:"latitude,omitempty" validate:"required_with=Longitude,omitempty,gte=-90,lte=90"`
+	Longitude   *float64 `json:"longitude,omitempty" validate:"required_with=Latitude,omitempty,gte=-180,lte=180"`
 }
 
 type UpdateCampgroundRequest struct {
-	Name        *string `json:"name,omitempty" validate:"omitempty,max=100"`
-	Price       *string `json:"price,omitempty"`
-	Image       *string `json:"image,omitempty" validate:"omitempty,url"`
-	Description *string `json:"description,omitempty" validate:"omitempty,max=5000"`
-	Location    *string `json:"location,omitempty" validate:"omitempty,max=200"`
+	Name        *string  `json:"name,omitempty" validate:"omitempty,max=100"`
+	Price       *Price   `json:"price,omitempty"`
+	Image       *string  `json:"image,omitempty" validate:"omitempty,url"`
+	Description *string  `json:"description,omitempty" validate:"omitempty,max=5000"`
+	Location    *string  `json:"location,omitempty" validate:"omitempty,max=200"`
+	Latitude    *float64 `json:"latitude,omitempty" validate:"required_with=Longitude,omitempty,gte=-90,lte=90"`
+	Longitude   *float64 `json:"longitude,omitempty" validate:"required_with=Latitude,omitempty,gte=-180,lte=180"`
 }
 
 type CreateCommentRequest struct {
 	Text string `json:"text" validate:"required,max=500"`
+	// Rating is 1 to 5 stars, or nil for a comment that doesn't rate.
+	Rating *int `json:"rating,omitempty" validate:"omitempty,gte=1,lte=5"`
 }
 
 type UpdateCommentRequest struct {
 	Text string `json:"text" validate:"required,max=500"`
+	// Rating is 1 to 5 stars, or nil for a comment that doesn't rate.
+	Rating *int `json:"rating,omitempty" validate:"omitempty,gte=1,lte=5"`
 }
 
 type PaginatedResponse struct {
@@ -88,8 +528,3 @@ type Pagination struct {
 	TotalPages int  `json:"totalPages"`
 	HasMore    bool `json:"hasMore"`
 }
-
-type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message,omitempty"`
-}
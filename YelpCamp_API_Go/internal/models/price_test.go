@@ -0,0 +1,90 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestPriceString(t *testing.T) {
+	tests := []struct {
+		price Price
+		want  string
+	}{
+		{Price{1500, "USD", PricePerNight}, "$15.00/night"},
+		{Price{5, "EUR", PricePerPerson}, "€0.05/person"},
+		{Price{0, "GBP", PricePerSite}, "£0.00/site"},
+		{Price{3000, "JPY", PricePerNight}, "¥3000/night"},
+		{Price{2050, "CAD", PricePerSite}, "CAD 20.50/site"},
+		{Price{1234, "KWD", PricePerNight}, "KWD 1.234/night"},
+	}
+	for _, tt := range tests {
+		if got := tt.price.String(); got != tt.want {
+			t.Errorf("%+v: got %q, want %q", tt.price, got, tt.want)
+		}
+	}
+}
+
+func TestPriceJSON(t *testing.T) {
+	data, err := json.Marshal(Price{1500, "USD", PricePerNight})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"amount":1500,"currency":"USD","unit":"night","formatted":"$15.00/night"}`
+	if string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+
+	var p Price
+	if err := json.Unmarshal(data, &p); err != nil || p != (Price{1500, "USD", PricePerNight}) {
+		t.Errorf("round trip: %+v, %v", p, err)
+	}
+}
+
+func TestPriceFromText(t *testing.T) {
+	tests := []struct {
+		text string
+		want Price
+	}{
+		{`"15"`, Price{1500, "USD", PricePerNight}},
+		{`"$12.50/night"`, Price{1250, "USD", PricePerNight}},
+		{`"€20 per person"`, Price{2000, "EUR", PricePerPerson}},
+		{`"GBP 8.5 a site"`, Price{850, "GBP", PricePerSite}},
+		{`"¥3,000"`, Price{3000, "JPY", PricePerNight}},
+	}
+	for _, tt := range tests {
+		var p Price
+		if err := json.Unmarshal([]byte(tt.text), &p); err != nil || p != tt.want {
+			t.Errorf("%s: got %+v, %v, want %+v", tt.text, p, err, tt.want)
+		}
+	}
+
+	var p Price
+	if err := json.Unmarshal([]byte(`"free"`), &p); !errors.Is(err, ErrPriceText) {
+		t.Errorf("no amount: %v, want ErrPriceText", err)
+	}
+}
+
+func TestCampgroundJSON(t *testing.T) {
+	c := Campground{ID: 1, Name: "Pine Hollow", Price: Price{3000, "JPY", PricePerSite}}
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(fields["price"]); got != `"3000"` {
+		t.Errorf("price %s, want \"3000\"", got)
+	}
+	want := `{"amount":3000,"currency":"JPY","unit":"site","formatted":"¥3000/site"}`
+	if got := string(fields["priceDetail"]); got != want {
+		t.Errorf("priceDetail %s, want %s", got, want)
+	}
+
+	var back Campground
+	if err := json.Unmarshal(data, &back); err != nil || back.Price != c.Price {
+		t.Errorf("round trip: %+v, %v", back.Price, err)
+	}
+}
@@ -0,0 +1,65 @@
+// Package policy decides who may do what. Handlers describe the caller and
+// the resource they are acting on and ask Can; the rules live only here.
+package policy
+
+import "github.com/sangnn2012/yelpcamp-api-go/internal/models"
+
+type Action string
+
+const (
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+type Kind string
+
+const (
+	KindCampground Kind = "campground"
+	KindComment    Kind = "comment"
+)
+
+// User is the caller an authorization decision is made for.
+type User struct {
+	ID   string
+	Role models.Role
+}
+
+// Resource is what the caller wants to act on. OwnerID is nil for content
+// whose author was deleted.
+type Resource struct {
+	Kind    Kind
+	OwnerID *string
+}
+
+func Campground(c *models.Campground) Resource {
+	return Resource{Kind: KindCampground, OwnerID: c.AuthorID}
+}
+
+func Comment(c *models.Comment) Resource {
+	return Resource{Kind: KindComment, OwnerID: c.AuthorID}
+}
+
+// Can reports whether user may perform action on resource:
+//   - admins may do anything;
+//   - moderators may also delete any comment;
+//   - everyone may update and delete their own campgrounds and comments.
+func Can(user User, action Action, resource Resource) bool {
+	if user.ID == "" {
+		return false
+	}
+	if user.Role.AtLeast(models.RoleAdmin) {
+		return true
+	}
+	if resource.Kind == KindComment && action == ActionDelete && user.Role.AtLeast(models.RoleModerator) {
+		return true
+	}
+
+	switch resource.Kind {
+	case KindCampground, KindComment:
+		if action != ActionUpdate && action != ActionDelete {
+			return false
+		}
+		return resource.OwnerID != nil && *resource.OwnerID == user.ID
+	}
+	return false
+}
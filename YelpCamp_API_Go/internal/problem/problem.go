@@ -0,0 +1,143 @@
+// Package problem writes API errors as RFC 7807 problem details. Every
+// error carries a stable Code for clients to branch on; Detail is for humans
+// and may change.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/sangnn2012/yelpcamp-api-go/pkg/validator"
+)
+
+const ContentType = "application/problem+json"
+
+// Code identifies the kind of error.
+type Code string
+
+const (
+	// Malformed or invalid input
+	InvalidBody      Code = "invalid_body"
+	ValidationFailed Code = "validation_failed"
+	InvalidParameter Code = "invalid_parameter"
+
+	// Authentication
+	Unauthorized          Code = "unauthorized"
+	InvalidToken          Code = "invalid_token"
+	InvalidCredentials    Code = "invalid_credentials"
+	InvalidTwoFactorCode  Code = "invalid_two_factor_code"
+	LoginThrottled        Code = "login_throttled"
+	VerificationThrottled Code = "verification_throttled"
+
+	// Authorization
+	Forbidden        Code = "forbidden"
+	AccountSuspended Code = "account_suspended"
+	EmailNotVerified Code = "email_not_verified"
+	SessionRequired  Code = "session_required"
+	ReadOnlyAPIKey   Code = "read_only_api_key"
+	CannotModifySelf Code = "cannot_modify_self"
+
+	// Resource state
+	NotFound                Code = "not_found"
+	MethodNotAllowed        Code = "method_not_allowed"
+	Conflict                Code = "conflict"
+	EmailTaken              Code = "email_taken"
+	EmailUnchanged          Code = "email_unchanged"
+	EmailAlreadyVerified    Code = "email_already_verified"
+	TwoFactorNotEnabled     Code = "two_factor_not_enabled"
+	TwoFactorAlreadyEnabled Code = "two_factor_already_enabled"
+	TwoFactorNotEnrolled    Code = "two_factor_not_enrolled"
+	ExportNotReady          Code = "export_not_ready"
+
+	// Server side
+	RateLimited         Code = "rate_limited"
+	ProviderUnavailable Code = "provider_unavailable"
+	Internal            Code = "internal_error"
+)
+
+// Problem is the body of every error response. Type is always about:blank,
+// so Title is the HTTP status text and Code tells the errors apart.
+type Problem struct {
+	Type      string                 `json:"type"`
+	Title     string                 `json:"title"`
+	Status    int                    `json:"status"`
+	Code      Code                   `json:"code"`
+	Detail    string                 `json:"detail,omitempty"`
+	Instance  string                 `json:"instance,omitempty"`
+	RequestID string                 `json:"requestId,omitempty"`
+	Errors    []validator.FieldError `json:"errors,omitempty"`
+}
+
+func New(status int, code Code, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   code,
+		Detail: detail,
+	}
+}
+
+// Validation turns a validator error into a problem listing every invalid
+// field.
+func Validation(err error) *Problem {
+	return invalid(validator.ValidationErrors(err))
+}
+
+// InvalidField reports a field that passed validation tags but failed a
+// check in the handler.
+func InvalidField(field, rule, message string) *Problem {
+	return invalid([]validator.FieldError{{Field: field, Rule: rule, Message: message}})
+}
+
+// InvalidFields is InvalidField for several fields at once.
+func InvalidFields(errs []validator.FieldError) *Problem {
+	return invalid(errs)
+}
+
+func invalid(errs []validator.FieldError) *Problem {
+	p := New(http.StatusBadRequest, ValidationFailed, "The request has invalid fields")
+	p.Errors = errs
+	if len(errs) == 1 {
+		p.Detail = errs[0].Message
+	}
+	return p
+}
+
+// From returns err as a problem, or an internal error if it isn't one.
+func From(err error) *Problem {
+	var p *Problem
+	if errors.As(err, &p) {
+		return p
+	}
+	return New(http.StatusInternalServerError, Internal, "Internal server error")
+}
+
+func (p *Problem) Error() string {
+	return p.Detail
+}
+
+// Write sends p with the request's path and ID filled in. p itself is left
+// unchanged, so shared problems can be written concurrently.
+func Write(w http.ResponseWriter, r *http.Request, p *Problem) {
+	out := *p
+	out.Instance = r.URL.Path
+	out.RequestID = middleware.GetReqID(r.Context())
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(out.Status)
+	json.NewEncoder(w).Encode(out)
+}
+
+// NotFoundHandler and MethodNotAllowedHandler answer requests the router
+// has no route for.
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, New(http.StatusNotFound, NotFound, "No route for "+r.URL.Path))
+}
+
+func MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, New(http.StatusMethodNotAllowed, MethodNotAllowed, r.Method+" is not allowed here"))
+}
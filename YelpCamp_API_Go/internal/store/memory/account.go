@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+type AccountStore struct {
+	db *DB
+}
+
+func NewAccountStore(db *DB) *AccountStore {
+	return &AccountStore{db: db}
+}
+
+func (s *AccountStore) GetByProvider(ctx context.Context, providerID, accountID string) (*models.Account, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, a := range s.db.accounts {
+		if a.ProviderID == providerID && a.AccountID == accountID {
+			return &a, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *AccountStore) Create(ctx context.Context, account *models.Account) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, ok := s.db.users[account.UserID]; !ok {
+		return store.ErrNotFound
+	}
+	for _, a := range s.db.accounts {
+		if a.ProviderID == account.ProviderID && a.AccountID == account.AccountID {
+			return store.ErrConflict
+		}
+	}
+	s.db.accounts[account.ID] = *account
+	return nil
+}
+
+func (s *AccountStore) UpdateTokens(ctx context.Context, account *models.Account) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	a, ok := s.db.accounts[account.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	a.AccessToken = account.AccessToken
+	if account.RefreshToken != nil {
+		a.RefreshToken = account.RefreshToken
+	}
+	a.AccessTokenExpiresAt = account.AccessTokenExpiresAt
+	a.Scope = account.Scope
+	a.UpdatedAt = account.UpdatedAt
+	s.db.accounts[account.ID] = a
+	return nil
+}
@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+type APIKeyStore struct {
+	db *DB
+}
+
+func NewAPIKeyStore(db *DB) *APIKeyStore {
+	return &APIKeyStore{db: db}
+}
+
+func (s *APIKeyStore) Create(ctx context.Context, key *models.APIKey) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, ok := s.db.users[key.UserID]; !ok {
+		return store.ErrNotFound
+	}
+	for _, k := range s.db.apiKeys {
+		if k.KeyHash == key.KeyHash {
+			return store.ErrConflict
+		}
+	}
+	s.db.apiKeys[key.ID] = *key
+	return nil
+}
+
+func (s *APIKeyStore) GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, k := range s.db.apiKeys {
+		if k.KeyHash == keyHash {
+			return &k, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *APIKeyStore) ListByUser(ctx context.Context, userID string) ([]models.APIKey, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	keys := []models.APIKey{}
+	for _, k := range s.db.apiKeys {
+		if k.UserID == userID {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+func (s *APIKeyStore) Delete(ctx context.Context, userID, id string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	k, ok := s.db.apiKeys[id]
+	if !ok || k.UserID != userID {
+		return store.ErrNotFound
+	}
+	delete(s.db.apiKeys, id)
+	return nil
+}
+
+func (s *APIKeyStore) DeleteByUser(ctx context.Context, userID string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for id, k := range s.db.apiKeys {
+		if k.UserID == userID {
+			delete(s.db.apiKeys, id)
+		}
+	}
+	return nil
+}
+
+func (s *APIKeyStore) TouchLastUsed(ctx context.Context, id string, at time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if k, ok := s.db.apiKeys[id]; ok {
+		k.LastUsedAt = &at
+		s.db.apiKeys[id] = k
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+type AuditLogStore struct {
+	db *DB
+}
+
+func NewAuditLogStore(db *DB) *AuditLogStore {
+	return &AuditLogStore{db: db}
+}
+
+func (s *AuditLogStore) Create(ctx context.Context, entry *models.AuditLog) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	s.db.auditLogs = append(s.db.auditLogs, *entry)
+	return nil
+}
+
+func (s *AuditLogStore) List(ctx context.Context, filter store.AuditLogFilter) ([]models.AuditLog, int, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	// Entries are appended in order, so walk backwards for newest first
+	matched := []models.AuditLog{}
+	for i := len(s.db.auditLogs) - 1; i >= 0; i-- {
+		e := s.db.auditLogs[i]
+		if filter.ActorID != "" && (e.ActorID == nil || *e.ActorID != filter.ActorID) {
+			continue
+		}
+		if filter.TargetID != "" && e.TargetID != filter.TargetID {
+			continue
+		}
+		matched = append(matched, e)
+	}
+
+	total := len(matched)
+	return paginate(matched, filter.Offset, filter.Limit), total, nil
+}
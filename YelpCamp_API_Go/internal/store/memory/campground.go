@@ -0,0 +1,266 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/geo"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+type CampgroundStore struct {
+	db *DB
+}
+
+func NewCampgroundStore(db *DB) *CampgroundStore {
+	return &CampgroundStore{db: db}
+}
+
+func (s *CampgroundStore) List(ctx context.Context, filter store.CampgroundFilter) ([]models.Campground, int, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	search := parseSearch(filter.Search)
+	ratings := s.db.ratings()
+	commented := map[int]bool{}
+	if filter.HasComments {
+		for _, comment := range s.db.comments {
+			commented[comment.CampgroundID] = true
+		}
+	}
+	matched := []models.Campground{}
+	ranks := map[int]float64{}
+	for _, c := range s.db.campgrounds {
+		if filter.Search != "" {
+			if !search.matches(c) {
+				continue
+			}
+			ranks[c.ID] = search.rank(c)
+			c.Highlight = search.highlight(c.Description)
+		}
+		if filter.AuthorID != "" && (c.AuthorID == nil || *c.AuthorID != filter.AuthorID) {
+			continue
+		}
+		if !filter.CreatedAfter.IsZero() && c.CreatedAt.Before(filter.CreatedAfter) {
+			continue
+		}
+		if !filter.CreatedBefore.IsZero() && !c.CreatedAt.Before(filter.CreatedBefore) {
+			continue
+		}
+		if filter.HasComments && !commented[c.ID] {
+			continue
+		}
+		if !matchesPrice(c.Price, filter) {
+			continue
+		}
+		if filter.Near != nil || filter.BBox != nil {
+			if c.Latitude == nil || c.Longitude == nil {
+				continue
+			}
+			p := geo.Point{Latitude: *c.Latitude, Longitude: *c.Longitude}
+			if filter.BBox != nil && !filter.BBox.Contains(p) {
+				continue
+			}
+			if filter.Near != nil {
+				d := geo.Distance(*filter.Near, p)
+				if d > filter.RadiusKm {
+					continue
+				}
+				c.Distance = &d
+			}
+		}
+		c.Author = s.db.author(c.AuthorID)
+		if filter.Author != "" && (c.Author == nil || c.Author.Username != filter.Author) {
+			continue
+		}
+		ratings[c.ID].apply(&c)
+		matched = append(matched, c)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		switch filter.Sort {
+		case store.SortRelevance:
+			if ranks[a.ID] != ranks[b.ID] {
+				return ranks[a.ID] > ranks[b.ID]
+			}
+		case store.SortDefault:
+			if filter.Near != nil && *a.Distance != *b.Distance {
+				return *a.Distance < *b.Distance
+			}
+		case store.SortOldest:
+			return a.CreatedAt.Before(b.CreatedAt)
+		case store.SortPrice, store.SortPriceDesc:
+			if a.Price.Amount != b.Price.Amount {
+				return (a.Price.Amount < b.Price.Amount) == (filter.Sort == store.SortPrice)
+			}
+		case store.SortName, store.SortNameDesc:
+			if an, bn := strings.ToLower(a.Name), strings.ToLower(b.Name); an != bn {
+				return (an < bn) == (filter.Sort == store.SortName)
+			}
+		case store.SortRating, store.SortRatingDesc:
+			if (a.Rating == nil) != (b.Rating == nil) {
+				return a.Rating != nil
+			}
+			if a.Rating != nil && *a.Rating != *b.Rating {
+				return (*a.Rating < *b.Rating) == (filter.Sort == store.SortRating)
+			}
+		}
+		return a.CreatedAt.After(b.CreatedAt)
+	})
+
+	total := len(matched)
+	return paginate(matched, filter.Offset, filter.Limit), total, nil
+}
+
+func (s *CampgroundStore) GetByID(ctx context.Context, id int) (*models.Campground, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	c, ok := s.db.campgrounds[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	c.Author = s.db.author(c.AuthorID)
+	s.db.ratings()[id].apply(&c)
+	return &c, nil
+}
+
+func (s *CampgroundStore) Exists(ctx context.Context, id int) (bool, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	_, ok := s.db.campgrounds[id]
+	return ok, nil
+}
+
+func (s *CampgroundStore) Create(ctx context.Context, c *models.Campground) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	s.db.nextCampgroundID++
+	c.ID = s.db.nextCampgroundID
+	s.db.campgrounds[c.ID] = *c
+	return nil
+}
+
+func (s *CampgroundStore) Update(ctx context.Context, id int, req models.UpdateCampgroundRequest, updatedAt time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	c, ok := s.db.campgrounds[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if req.Name != nil {
+		c.Name = *req.Name
+	}
+	if req.Price != nil {
+		c.Price = *req.Price
+	}
+	if req.Image != nil {
+		c.Image = *req.Image
+	}
+	if req.Description != nil {
+		c.Description = *req.Description
+	}
+	if req.Location != nil {
+		if c.Location == nil || *c.Location != *req.Location {
+			// The old place's coordinates and address no longer apply
+			c.Latitude, c.Longitude, c.Address = nil, nil, nil
+		}
+		c.Location = req.Location
+	}
+	if req.Latitude != nil {
+		c.Latitude = req.Latitude
+	}
+	if req.Longitude != nil {
+		c.Longitude = req.Longitude
+	}
+	c.UpdatedAt = updatedAt
+	s.db.campgrounds[id] = c
+	return nil
+}
+
+func (s *CampgroundStore) SetGeocode(ctx context.Context, id int, location string, point geo.Point, address string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	c, ok := s.db.campgrounds[id]
+	if !ok || c.Location == nil || *c.Location != location {
+		return store.ErrNotFound
+	}
+	c.Latitude, c.Longitude = &point.Latitude, &point.Longitude
+	c.Address = nil
+	if address != "" {
+		c.Address = &address
+	}
+	s.db.campgrounds[id] = c
+	return nil
+}
+
+func (s *CampgroundStore) Delete(ctx context.Context, id int) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, ok := s.db.campgrounds[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(s.db.campgrounds, id)
+
+	// Mirror ON DELETE CASCADE on comments.campground_id
+	for commentID, comment := range s.db.comments {
+		if comment.CampgroundID == id {
+			delete(s.db.comments, commentID)
+		}
+	}
+	return nil
+}
+
+func matchesPrice(p models.Price, filter store.CampgroundFilter) bool {
+	return (filter.Currency == "" || p.Currency == filter.Currency) &&
+		(filter.PriceMin == nil || p.Amount >= *filter.PriceMin) &&
+		(filter.PriceMax == nil || p.Amount <= *filter.PriceMax)
+}
+
+func paginate[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+	end := len(items)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+	return items[offset:end]
+}
+
+// ratingTotal adds up the ratings given in one campground's comments.
+type ratingTotal struct {
+	sum, count int
+}
+
+// apply sets the campground's average rating and rating count.
+func (t ratingTotal) apply(c *models.Campground) {
+	c.Rating, c.RatingCount = nil, t.count
+	if t.count > 0 {
+		average := float64(t.sum) / float64(t.count)
+		c.Rating = &average
+	}
+}
+
+// ratings totals the comments' ratings by campground; callers must hold
+// db.mu.
+func (db *DB) ratings() map[int]ratingTotal {
+	totals := map[int]ratingTotal{}
+	for _, comment := range db.comments {
+		if comment.Rating != nil {
+			t := totals[comment.CampgroundID]
+			t.sum += *comment.Rating
+			t.count++
+			totals[comment.CampgroundID] = t
+		}
+	}
+	return totals
+}
@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+type CommentStore struct {
+	db *DB
+}
+
+func NewCommentStore(db *DB) *CommentStore {
+	return &CommentStore{db: db}
+}
+
+func (s *CommentStore) ListByCampground(ctx context.Context, campgroundID int) ([]models.Comment, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	comments := []models.Comment{}
+	for _, c := range s.db.comments {
+		if c.CampgroundID != campgroundID {
+			continue
+		}
+		c.Author = s.db.author(c.AuthorID)
+		comments = append(comments, c)
+	}
+	sort.Slice(comments, func(i, j int) bool {
+		return comments[i].CreatedAt.After(comments[j].CreatedAt)
+	})
+	return comments, nil
+}
+
+func (s *CommentStore) List(ctx context.Context, filter store.CommentFilter) ([]models.Comment, int, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	matched := []models.Comment{}
+	for _, c := range s.db.comments {
+		if filter.AuthorID != "" && (c.AuthorID == nil || *c.AuthorID != filter.AuthorID) {
+			continue
+		}
+		c.Author = s.db.author(c.AuthorID)
+		matched = append(matched, c)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].CreatedAt.After(matched[j].CreatedAt)
+	})
+
+	total := len(matched)
+	return paginate(matched, filter.Offset, filter.Limit), total, nil
+}
+
+func (s *CommentStore) GetByID(ctx context.Context, id int) (*models.Comment, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	c, ok := s.db.comments[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	c.Author = s.db.author(c.AuthorID)
+	return &c, nil
+}
+
+func (s *CommentStore) Create(ctx context.Context, c *models.Comment) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, ok := s.db.campgrounds[c.CampgroundID]; !ok {
+		return store.ErrNotFound
+	}
+	s.db.nextCommentID++
+	c.ID = s.db.nextCommentID
+	s.db.comments[c.ID] = *c
+	return nil
+}
+
+func (s *CommentStore) Update(ctx context.Context, id int, text string, rating *int, updatedAt time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	c, ok := s.db.comments[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	c.Text = text
+	c.Rating = rating
+	c.UpdatedAt = updatedAt
+	s.db.comments[id] = c
+	return nil
+}
+
+func (s *CommentStore) Delete(ctx context.Context, id int) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, ok := s.db.comments[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(s.db.comments, id)
+	return nil
+}
@@ -0,0 +1,129 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+type DataExportStore struct {
+	db *DB
+}
+
+func NewDataExportStore(db *DB) *DataExportStore {
+	return &DataExportStore{db: db}
+}
+
+func (s *DataExportStore) Create(ctx context.Context, export *models.DataExport) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, ok := s.db.users[export.UserID]; !ok {
+		return store.ErrNotFound
+	}
+	s.db.dataExports[export.ID] = *export
+	return nil
+}
+
+func (s *DataExportStore) Get(ctx context.Context, userID, id string) (*models.DataExport, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	e, ok := s.db.dataExports[id]
+	if !ok || e.UserID != userID {
+		return nil, store.ErrNotFound
+	}
+	return &e, nil
+}
+
+func (s *DataExportStore) GetActive(ctx context.Context, userID string) (*models.DataExport, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, e := range s.db.dataExports {
+		if e.UserID == userID && (e.Status == models.ExportPending || e.Status == models.ExportRunning) {
+			return &e, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *DataExportStore) Claim(ctx context.Context, now, staleBefore time.Time) (*models.DataExport, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var claimed *models.DataExport
+	for _, e := range s.db.dataExports {
+		claimable := e.Status == models.ExportPending ||
+			e.Status == models.ExportRunning && e.StartedAt != nil && e.StartedAt.Before(staleBefore)
+		if claimable && (claimed == nil || e.CreatedAt.Before(claimed.CreatedAt)) {
+			e := e
+			claimed = &e
+		}
+	}
+	if claimed == nil {
+		return nil, store.ErrNotFound
+	}
+	claimed.Status = models.ExportRunning
+	claimed.StartedAt = &now
+	s.db.dataExports[claimed.ID] = *claimed
+	return claimed, nil
+}
+
+func (s *DataExportStore) Complete(ctx context.Context, id string, archive []byte, completedAt, expiresAt time.Time) error {
+	return s.finish(id, func(e *models.DataExport) {
+		e.Status = models.ExportReady
+		e.Size = len(archive)
+		e.CompletedAt = &completedAt
+		e.ExpiresAt = &expiresAt
+		s.db.exportArchives[id] = archive
+	})
+}
+
+func (s *DataExportStore) Fail(ctx context.Context, id, message string, completedAt time.Time) error {
+	return s.finish(id, func(e *models.DataExport) {
+		e.Status = models.ExportFailed
+		e.Error = &message
+		e.CompletedAt = &completedAt
+	})
+}
+
+func (s *DataExportStore) Archive(ctx context.Context, userID, id string) ([]byte, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	e, ok := s.db.dataExports[id]
+	archive, ready := s.db.exportArchives[id]
+	if !ok || !ready || e.UserID != userID {
+		return nil, store.ErrNotFound
+	}
+	return archive, nil
+}
+
+func (s *DataExportStore) DeleteExpired(ctx context.Context, before time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for id, e := range s.db.dataExports {
+		if e.ExpiresAt != nil && e.ExpiresAt.Before(before) {
+			delete(s.db.dataExports, id)
+			delete(s.db.exportArchives, id)
+		}
+	}
+	return nil
+}
+
+func (s *DataExportStore) finish(id string, apply func(*models.DataExport)) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	e, ok := s.db.dataExports[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	apply(&e)
+	s.db.dataExports[id] = e
+	return nil
+}
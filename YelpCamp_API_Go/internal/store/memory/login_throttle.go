@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+type LoginThrottleStore struct {
+	db *DB
+}
+
+func NewLoginThrottleStore(db *DB) *LoginThrottleStore {
+	return &LoginThrottleStore{db: db}
+}
+
+func (s *LoginThrottleStore) Get(ctx context.Context, key string) (*models.LoginThrottle, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	t, ok := s.db.loginThrottles[key]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &t, nil
+}
+
+func (s *LoginThrottleStore) RecordFailure(ctx context.Context, key string, at, resetBefore time.Time) (*models.LoginThrottle, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	t, ok := s.db.loginThrottles[key]
+	if !ok || t.LastFailedAt.Before(resetBefore) {
+		t = models.LoginThrottle{Key: key, LockedUntil: t.LockedUntil}
+	}
+	t.Failures++
+	t.LastFailedAt = at
+	s.db.loginThrottles[key] = t
+	return &t, nil
+}
+
+func (s *LoginThrottleStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	t, ok := s.db.loginThrottles[key]
+	if !ok {
+		return store.ErrNotFound
+	}
+	t.LockedUntil = &until
+	s.db.loginThrottles[key] = t
+	return nil
+}
+
+func (s *LoginThrottleStore) Delete(ctx context.Context, key string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	delete(s.db.loginThrottles, key)
+	return nil
+}
@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+// DB holds every table in memory behind one lock, so the stores built on it
+// see each other's writes the way the Postgres ones share a database.
+type DB struct {
+	mu sync.RWMutex
+
+	users            map[string]models.User
+	campgrounds      map[int]models.Campground
+	comments         map[int]models.Comment
+	sessions         map[string]models.Session
+	refreshTokens    map[string]models.RefreshToken
+	verifications    map[string]models.Verification
+	auditLogs        []models.AuditLog
+	twoFactors       map[string]models.TwoFactor
+	recoveryCodes    map[string]map[string]bool
+	accounts         map[string]models.Account
+	apiKeys          map[string]models.APIKey
+	loginThrottles   map[string]models.LoginThrottle
+	rateLimits       map[string]rateLimitBucket
+	dataExports      map[string]models.DataExport
+	exportArchives   map[string][]byte
+	nextCampgroundID int
+	nextCommentID    int
+}
+
+func NewDB() *DB {
+	return &DB{
+		users:          map[string]models.User{},
+		campgrounds:    map[int]models.Campground{},
+		comments:       map[int]models.Comment{},
+		sessions:       map[string]models.Session{},
+		refreshTokens:  map[string]models.RefreshToken{},
+		verifications:  map[string]models.Verification{},
+		twoFactors:     map[string]models.TwoFactor{},
+		recoveryCodes:  map[string]map[string]bool{},
+		accounts:       map[string]models.Account{},
+		apiKeys:        map[string]models.APIKey{},
+		loginThrottles: map[string]models.LoginThrottle{},
+		rateLimits:     map[string]rateLimitBucket{},
+		dataExports:    map[string]models.DataExport{},
+		exportArchives: map[string][]byte{},
+	}
+}
+
+// New returns in-memory stores sharing a fresh DB.
+func New() *store.Stores {
+	db := NewDB()
+	return &store.Stores{
+		Users:         NewUserStore(db),
+		Campgrounds:   NewCampgroundStore(db),
+		Comments:      NewCommentStore(db),
+		Sessions:      NewSessionStore(db),
+		RefreshTokens: NewRefreshTokenStore(db),
+		Verifications: NewVerificationStore(db),
+		AuditLogs:     NewAuditLogStore(db),
+		TwoFactors:    NewTwoFactorStore(db),
+		Accounts:      NewAccountStore(db),
+		APIKeys:       NewAPIKeyStore(db),
+		Throttles:     NewLoginThrottleStore(db),
+		RateLimits:    NewRateLimitStore(db),
+		DataExports:   NewDataExportStore(db),
+	}
+}
+
+// author resolves the author join; callers must hold db.mu.
+func (db *DB) author(authorID *string) *models.Author {
+	if authorID == nil {
+		return nil
+	}
+	u, ok := db.users[*authorID]
+	if !ok {
+		return nil
+	}
+	return &models.Author{ID: u.ID, Username: u.Username, DisplayUsername: u.DisplayUsername, Image: u.Image}
+}
+
+var (
+	_ store.UserStore          = (*UserStore)(nil)
+	_ store.CampgroundStore    = (*CampgroundStore)(nil)
+	_ store.CommentStore       = (*CommentStore)(nil)
+	_ store.SessionStore       = (*SessionStore)(nil)
+	_ store.RefreshTokenStore  = (*RefreshTokenStore)(nil)
+	_ store.VerificationStore  = (*VerificationStore)(nil)
+	_ store.AuditLogStore      = (*AuditLogStore)(nil)
+	_ store.TwoFactorStore     = (*TwoFactorStore)(nil)
+	_ store.AccountStore       = (*AccountStore)(nil)
+	_ store.APIKeyStore        = (*APIKeyStore)(nil)
+	_ store.LoginThrottleStore = (*LoginThrottleStore)(nil)
+	_ store.RateLimitStore     = (*RateLimitStore)(nil)
+	_ store.DataExportStore    = (*DataExportStore)(nil)
+)
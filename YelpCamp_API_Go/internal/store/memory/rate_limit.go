@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"context"
+	"time"
+)
+
+type rateLimitBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+type RateLimitStore struct {
+	db *DB
+}
+
+func NewRateLimitStore(db *DB) *RateLimitStore {
+	return &RateLimitStore{db: db}
+}
+
+func (s *RateLimitStore) Take(ctx context.Context, key string, capacity int, period time.Duration, now time.Time) (bool, float64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	b, ok := s.db.rateLimits[key]
+	if !ok {
+		b = rateLimitBucket{tokens: float64(capacity), updatedAt: now}
+	}
+	if elapsed := now.Sub(b.updatedAt); elapsed > 0 {
+		b.tokens = min(float64(capacity), b.tokens+elapsed.Seconds()*float64(capacity)/period.Seconds())
+		b.updatedAt = now
+	}
+	if b.tokens < 1 {
+		s.db.rateLimits[key] = b
+		return false, b.tokens, nil
+	}
+	b.tokens--
+	s.db.rateLimits[key] = b
+	return true, b.tokens, nil
+}
+
+func (s *RateLimitStore) DeleteIdle(ctx context.Context, before time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for key, b := range s.db.rateLimits {
+		if b.updatedAt.Before(before) {
+			delete(s.db.rateLimits, key)
+		}
+	}
+	return nil
+}
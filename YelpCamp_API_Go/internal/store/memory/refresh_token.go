@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+type RefreshTokenStore struct {
+	db *DB
+}
+
+func NewRefreshTokenStore(db *DB) *RefreshTokenStore {
+	return &RefreshTokenStore{db: db}
+}
+
+func (s *RefreshTokenStore) Create(ctx context.Context, token *models.RefreshToken) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	return s.db.insertRefreshToken(token)
+}
+
+func (s *RefreshTokenStore) GetByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, t := range s.db.refreshTokens {
+		if t.TokenHash == tokenHash {
+			return &t, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *RefreshTokenStore) Rotate(ctx context.Context, oldID string, next *models.RefreshToken) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	old, ok := s.db.refreshTokens[oldID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if old.RevokedAt != nil {
+		return store.ErrConflict
+	}
+	if err := s.db.insertRefreshToken(next); err != nil {
+		return err
+	}
+	revokedAt := next.CreatedAt
+	old.RevokedAt = &revokedAt
+	old.ReplacedBy = &next.ID
+	s.db.refreshTokens[oldID] = old
+	return nil
+}
+
+func (s *RefreshTokenStore) RevokeFamily(ctx context.Context, familyID string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	now := time.Now()
+	for id, t := range s.db.refreshTokens {
+		if t.FamilyID == familyID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+			s.db.refreshTokens[id] = t
+		}
+	}
+	return nil
+}
+
+// insertRefreshToken enforces the family_id foreign key and the token_hash
+// unique index; callers must hold db.mu.
+func (db *DB) insertRefreshToken(token *models.RefreshToken) error {
+	if _, ok := db.sessions[token.FamilyID]; !ok {
+		return store.ErrNotFound
+	}
+	for _, t := range db.refreshTokens {
+		if t.TokenHash == token.TokenHash {
+			return store.ErrConflict
+		}
+	}
+	db.refreshTokens[token.ID] = *token
+	return nil
+}
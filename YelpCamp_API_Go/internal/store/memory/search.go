@@ -0,0 +1,143 @@
+package memory
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+)
+
+// excerptLength is about how many characters of the description a
+// highlight shows.
+const excerptLength = 200
+
+// searchQuery approximates Postgres' websearch_to_tsquery: words and
+// "quoted phrases" must all occur and -word or -"phrase" must not. Terms
+// match case-insensitively as substrings; there is no stemming or OR.
+type searchQuery struct {
+	include []string
+	exclude []string
+}
+
+func parseSearch(s string) searchQuery {
+	var q searchQuery
+	s = strings.ToLower(s)
+	for s != "" {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		negate := strings.HasPrefix(s, "-")
+		if negate {
+			s = s[1:]
+		}
+
+		var term string
+		if rest, ok := strings.CutPrefix(s, `"`); ok {
+			term, s, _ = strings.Cut(rest, `"`)
+		} else if i := strings.IndexFunc(s, unicode.IsSpace); i >= 0 {
+			term, s = s[:i], s[i:]
+		} else {
+			term, s = s, ""
+		}
+
+		term = strings.Join(strings.Fields(term), " ")
+		switch {
+		case term == "":
+		case negate:
+			q.exclude = append(q.exclude, term)
+		default:
+			q.include = append(q.include, term)
+		}
+	}
+	return q
+}
+
+func (q searchQuery) matches(c models.Campground) bool {
+	text := searchText(c)
+	for _, term := range q.exclude {
+		if strings.Contains(text, term) {
+			return false
+		}
+	}
+	for _, term := range q.include {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+	return len(q.include) > 0
+}
+
+// rank weighs matches in the name above the location above the
+// description, like the search_vector column.
+func (q searchQuery) rank(c models.Campground) float64 {
+	var location string
+	if c.Location != nil {
+		location = *c.Location
+	}
+	var rank float64
+	for _, term := range q.include {
+		rank += 1.0*float64(strings.Count(strings.ToLower(c.Name), term)) +
+			0.4*float64(strings.Count(strings.ToLower(location), term)) +
+			0.2*float64(strings.Count(strings.ToLower(c.Description), term))
+	}
+	return rank
+}
+
+// highlight excerpts the description around the first match, escapes it and
+// wraps matches in <mark>, like ts_headline in the Postgres store.
+func (q searchQuery) highlight(description string) *string {
+	lower := strings.ToLower(description)
+	var spans [][2]int
+	// Offsets only carry over if lowercasing kept every byte in place
+	for _, term := range q.include {
+		if len(lower) != len(description) {
+			break
+		}
+		for from := 0; ; {
+			i := strings.Index(lower[from:], term)
+			if i < 0 {
+				break
+			}
+			spans = append(spans, [2]int{from + i, from + i + len(term)})
+			from += i + len(term)
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+
+	start := 0
+	if len(spans) > 0 {
+		start = max(spans[0][0]-excerptLength/4, 0)
+	}
+	// Cut between words
+	if start > 0 {
+		start = strings.LastIndexFunc(description[:start], unicode.IsSpace) + 1
+	}
+	end := min(start+excerptLength, len(description))
+	if end < len(description) {
+		if i := strings.LastIndexFunc(description[start:end], unicode.IsSpace); i > 0 {
+			end = start + i
+		}
+	}
+
+	var b strings.Builder
+	pos := start
+	for _, span := range spans {
+		if span[0] < pos || span[1] > end {
+			continue
+		}
+		b.WriteString(html.EscapeString(description[pos:span[0]]))
+		b.WriteString("<mark>" + html.EscapeString(description[span[0]:span[1]]) + "</mark>")
+		pos = span[1]
+	}
+	b.WriteString(html.EscapeString(description[pos:end]))
+	excerpt := b.String()
+	return &excerpt
+}
+
+func searchText(c models.Campground) string {
+	text := c.Name + "\n" + c.Description
+	if c.Location != nil {
+		text += "\n" + *c.Location
+	}
+	return strings.ToLower(text)
+}
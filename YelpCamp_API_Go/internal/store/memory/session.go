@@ -0,0 +1,109 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+type SessionStore struct {
+	db *DB
+}
+
+func NewSessionStore(db *DB) *SessionStore {
+	return &SessionStore{db: db}
+}
+
+func (s *SessionStore) Create(ctx context.Context, session *models.Session) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, existing := range s.db.sessions {
+		if existing.Token == session.Token {
+			return store.ErrConflict
+		}
+	}
+	s.db.sessions[session.ID] = *session
+	return nil
+}
+
+func (s *SessionStore) GetByID(ctx context.Context, id string) (*models.Session, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	session, ok := s.db.sessions[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &session, nil
+}
+
+func (s *SessionStore) ListByUser(ctx context.Context, userID string) ([]models.Session, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	now := time.Now()
+	sessions := []models.Session{}
+	for _, session := range s.db.sessions {
+		if session.UserID == userID && session.ExpiresAt.After(now) {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+	return sessions, nil
+}
+
+func (s *SessionStore) Renew(ctx context.Context, id, tokenHash string, expiresAt time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	session, ok := s.db.sessions[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	session.Token = tokenHash
+	session.ExpiresAt = expiresAt
+	session.UpdatedAt = time.Now()
+	s.db.sessions[id] = session
+	return nil
+}
+
+func (s *SessionStore) Delete(ctx context.Context, userID, id string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	session, ok := s.db.sessions[id]
+	if !ok || session.UserID != userID {
+		return store.ErrNotFound
+	}
+	s.db.deleteSession(id)
+	return nil
+}
+
+func (s *SessionStore) DeleteByUser(ctx context.Context, userID, exceptID string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for id, session := range s.db.sessions {
+		if session.UserID == userID && id != exceptID {
+			s.db.deleteSession(id)
+		}
+	}
+	return nil
+}
+
+// deleteSession mirrors ON DELETE CASCADE on refresh_tokens.family_id;
+// callers must hold db.mu.
+func (db *DB) deleteSession(id string) {
+	delete(db.sessions, id)
+	for tokenID, t := range db.refreshTokens {
+		if t.FamilyID == id {
+			delete(db.refreshTokens, tokenID)
+		}
+	}
+}
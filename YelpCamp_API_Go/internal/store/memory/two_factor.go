@@ -0,0 +1,125 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+type TwoFactorStore struct {
+	db *DB
+}
+
+func NewTwoFactorStore(db *DB) *TwoFactorStore {
+	return &TwoFactorStore{db: db}
+}
+
+func (s *TwoFactorStore) Get(ctx context.Context, userID string) (*models.TwoFactor, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	tf, ok := s.db.twoFactors[userID]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &tf, nil
+}
+
+func (s *TwoFactorStore) Save(ctx context.Context, tf *models.TwoFactor) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if existing, ok := s.db.twoFactors[tf.UserID]; ok && existing.ConfirmedAt != nil {
+		return store.ErrConflict
+	}
+	s.db.twoFactors[tf.UserID] = models.TwoFactor{
+		UserID:    tf.UserID,
+		Secret:    tf.Secret,
+		CreatedAt: tf.CreatedAt,
+		UpdatedAt: tf.UpdatedAt,
+	}
+	return nil
+}
+
+func (s *TwoFactorStore) Enable(ctx context.Context, userID string, recoveryCodeHashes []string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	tf, ok := s.db.twoFactors[userID]
+	if !ok || tf.ConfirmedAt != nil {
+		return store.ErrNotFound
+	}
+	now := time.Now()
+	tf.ConfirmedAt = &now
+	tf.UpdatedAt = now
+	s.db.twoFactors[userID] = tf
+	s.db.setTwoFactorEnabled(userID, true)
+	s.db.replaceRecoveryCodes(userID, recoveryCodeHashes)
+	return nil
+}
+
+func (s *TwoFactorStore) Disable(ctx context.Context, userID string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	delete(s.db.twoFactors, userID)
+	delete(s.db.recoveryCodes, userID)
+	s.db.setTwoFactorEnabled(userID, false)
+	return nil
+}
+
+func (s *TwoFactorStore) UseStep(ctx context.Context, userID string, step int64) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	tf, ok := s.db.twoFactors[userID]
+	if !ok || (tf.LastUsedStep != nil && *tf.LastUsedStep >= step) {
+		return store.ErrConflict
+	}
+	tf.LastUsedStep = &step
+	tf.UpdatedAt = time.Now()
+	s.db.twoFactors[userID] = tf
+	return nil
+}
+
+func (s *TwoFactorStore) UseRecoveryCode(ctx context.Context, userID, codeHash string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	used, ok := s.db.recoveryCodes[userID][codeHash]
+	if !ok || used {
+		return store.ErrNotFound
+	}
+	s.db.recoveryCodes[userID][codeHash] = true
+	return nil
+}
+
+func (s *TwoFactorStore) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	s.db.replaceRecoveryCodes(userID, codeHashes)
+	return nil
+}
+
+// replaceRecoveryCodes stores unused codes keyed by hash; callers must hold
+// db.mu.
+func (db *DB) replaceRecoveryCodes(userID string, codeHashes []string) {
+	codes := make(map[string]bool, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes[hash] = false
+	}
+	db.recoveryCodes[userID] = codes
+}
+
+// setTwoFactorEnabled keeps the user flag in step with the enrollment;
+// callers must hold db.mu.
+func (db *DB) setTwoFactorEnabled(userID string, enabled bool) {
+	if u, ok := db.users[userID]; ok {
+		u.TwoFactorEnabled = enabled
+		u.UpdatedAt = time.Now()
+		db.users[userID] = u
+	}
+}
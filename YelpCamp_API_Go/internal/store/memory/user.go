@@ -0,0 +1,282 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+type UserStore struct {
+	db *DB
+}
+
+func NewUserStore(db *DB) *UserStore {
+	return &UserStore{db: db}
+}
+
+func (s *UserStore) Create(ctx context.Context, user *models.User) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, u := range s.db.users {
+		if u.Username == user.Username || u.Email == user.Email {
+			return store.ErrConflict
+		}
+	}
+	s.db.users[user.ID] = *user
+	return nil
+}
+
+func (s *UserStore) GetByID(ctx context.Context, id string) (*models.User, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	u, ok := s.db.users[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &u, nil
+}
+
+func (s *UserStore) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	return s.find(func(u models.User) bool { return u.Username == username })
+}
+
+func (s *UserStore) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return s.find(func(u models.User) bool { return u.Email == email })
+}
+
+func (s *UserStore) UpdatePassword(ctx context.Context, id, passwordHash string) error {
+	return s.update(id, func(u *models.User) { u.Password = passwordHash })
+}
+
+// UpdateEmail changes the email and marks it unverified.
+func (s *UserStore) UpdateEmail(ctx context.Context, id, email string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	u, ok := s.db.users[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	for _, other := range s.db.users {
+		if other.ID != id && other.Email == email {
+			return store.ErrConflict
+		}
+	}
+	u.Email = email
+	u.EmailVerified = false
+	u.UpdatedAt = time.Now()
+	s.db.users[id] = u
+	return nil
+}
+
+func (s *UserStore) UpdateProfile(ctx context.Context, id string, req models.UpdateProfileRequest) error {
+	return s.update(id, func(u *models.User) {
+		setOptional(&u.DisplayUsername, req.DisplayUsername)
+		setOptional(&u.Name, req.Name)
+		setOptional(&u.Image, req.Image)
+		setOptional(&u.Bio, req.Bio)
+	})
+}
+
+func (s *UserStore) GetProfile(ctx context.Context, username string) (*models.Profile, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, u := range s.db.users {
+		if u.Username != username {
+			continue
+		}
+		p := &models.Profile{
+			ID:              u.ID,
+			Username:        u.Username,
+			DisplayUsername: u.DisplayUsername,
+			Name:            u.Name,
+			Image:           u.Image,
+			Bio:             u.Bio,
+			JoinedAt:        u.CreatedAt,
+		}
+		for _, c := range s.db.campgrounds {
+			if c.AuthorID != nil && *c.AuthorID == u.ID {
+				p.CampgroundCount++
+			}
+		}
+		for _, c := range s.db.comments {
+			if c.AuthorID != nil && *c.AuthorID == u.ID {
+				p.CommentCount++
+			}
+		}
+		return p, nil
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *UserStore) MarkEmailVerified(ctx context.Context, id string) error {
+	return s.update(id, func(u *models.User) { u.EmailVerified = true })
+}
+
+func (s *UserStore) UpdateRole(ctx context.Context, id string, role models.Role) error {
+	return s.update(id, func(u *models.User) { u.Role = role })
+}
+
+func (s *UserStore) SetSuspended(ctx context.Context, id string, suspendedAt *time.Time) error {
+	return s.update(id, func(u *models.User) { u.SuspendedAt = suspendedAt })
+}
+
+func (s *UserStore) ExistsByUsernameOrEmail(ctx context.Context, username, email string) (bool, error) {
+	_, err := s.find(func(u models.User) bool { return u.Username == username || u.Email == email })
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *UserStore) List(ctx context.Context, filter store.UserFilter) ([]models.User, int, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	search := strings.ToLower(filter.Search)
+	matched := []models.User{}
+	for _, u := range s.db.users {
+		if search != "" && !strings.Contains(strings.ToLower(u.Username), search) &&
+			!strings.Contains(strings.ToLower(u.Email), search) {
+			continue
+		}
+		matched = append(matched, u)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].CreatedAt.Before(matched[j].CreatedAt)
+		}
+		return matched[i].ID < matched[j].ID
+	})
+
+	total := len(matched)
+	return paginate(matched, filter.Offset, filter.Limit), total, nil
+}
+
+// Delete mirrors the foreign keys on users: sessions, API keys and data
+// exports cascade, authored content and audit entries keep a NULL reference.
+func (s *UserStore) Delete(ctx context.Context, id string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	return s.db.deleteUser(id)
+}
+
+// DeleteWithContent also deletes the user's campgrounds, with every comment
+// on them, and the user's comments elsewhere.
+func (s *UserStore) DeleteWithContent(ctx context.Context, id string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, ok := s.db.users[id]; !ok {
+		return store.ErrNotFound
+	}
+	for cid, c := range s.db.campgrounds {
+		if c.AuthorID != nil && *c.AuthorID == id {
+			delete(s.db.campgrounds, cid)
+		}
+	}
+	for cid, c := range s.db.comments {
+		_, onKept := s.db.campgrounds[c.CampgroundID]
+		if !onKept || c.AuthorID != nil && *c.AuthorID == id {
+			delete(s.db.comments, cid)
+		}
+	}
+	return s.db.deleteUser(id)
+}
+
+// deleteUser applies the user foreign keys; callers must hold db.mu.
+func (db *DB) deleteUser(id string) error {
+	if _, ok := db.users[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(db.users, id)
+	delete(db.twoFactors, id)
+	delete(db.recoveryCodes, id)
+	for accountID, account := range db.accounts {
+		if account.UserID == id {
+			delete(db.accounts, accountID)
+		}
+	}
+	for keyID, key := range db.apiKeys {
+		if key.UserID == id {
+			delete(db.apiKeys, keyID)
+		}
+	}
+	for exportID, e := range db.dataExports {
+		if e.UserID == id {
+			delete(db.dataExports, exportID)
+			delete(db.exportArchives, exportID)
+		}
+	}
+	for sessionID, session := range db.sessions {
+		if session.UserID == id {
+			db.deleteSession(sessionID)
+		}
+	}
+	for cid, c := range db.campgrounds {
+		if c.AuthorID != nil && *c.AuthorID == id {
+			c.AuthorID = nil
+			db.campgrounds[cid] = c
+		}
+	}
+	for cid, c := range db.comments {
+		if c.AuthorID != nil && *c.AuthorID == id {
+			c.AuthorID = nil
+			db.comments[cid] = c
+		}
+	}
+	for i, e := range db.auditLogs {
+		if e.ActorID != nil && *e.ActorID == id {
+			db.auditLogs[i].ActorID = nil
+		}
+	}
+	return nil
+}
+
+func (s *UserStore) find(match func(models.User) bool) (*models.User, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, u := range s.db.users {
+		if match(u) {
+			return &u, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *UserStore) update(id string, apply func(*models.User)) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	u, ok := s.db.users[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	apply(&u)
+	u.UpdatedAt = time.Now()
+	s.db.users[id] = u
+	return nil
+}
+
+// setOptional applies a profile field update: nil keeps the value, an
+// empty string clears it.
+func setOptional(dst **string, value *string) {
+	switch {
+	case value == nil:
+	case *value == "":
+		*dst = nil
+	default:
+		v := *value
+		*dst = &v
+	}
+}
@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+type VerificationStore struct {
+	db *DB
+}
+
+func NewVerificationStore(db *DB) *VerificationStore {
+	return &VerificationStore{db: db}
+}
+
+func (s *VerificationStore) Create(ctx context.Context, v *models.Verification) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	s.db.verifications[v.ID] = *v
+	return nil
+}
+
+func (s *VerificationStore) Consume(ctx context.Context, value string) (*models.Verification, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for id, v := range s.db.verifications {
+		if v.Value == value {
+			delete(s.db.verifications, id)
+			return &v, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *VerificationStore) GetByIdentifier(ctx context.Context, identifier string) (*models.Verification, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var latest *models.Verification
+	for _, v := range s.db.verifications {
+		if v.Identifier == identifier && (latest == nil || v.CreatedAt.After(latest.CreatedAt)) {
+			v := v
+			latest = &v
+		}
+	}
+	if latest == nil {
+		return nil, store.ErrNotFound
+	}
+	return latest, nil
+}
+
+func (s *VerificationStore) DeleteByIdentifier(ctx context.Context, identifier string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for id, v := range s.db.verifications {
+		if v.Identifier == identifier {
+			delete(s.db.verifications, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+type AccountStore struct {
+	db *pgxpool.Pool
+}
+
+func NewAccountStore(db *pgxpool.Pool) *AccountStore {
+	return &AccountStore{db: db}
+}
+
+func (s *AccountStore) GetByProvider(ctx context.Context, providerID, accountID string) (*models.Account, error) {
+	var a models.Account
+	err := s.db.QueryRow(ctx, `
+		SELECT id, user_id, provider_id, account_id, access_token, refresh_token,
+			   access_token_expires_at, refresh_token_expires_at, scope, created_at, updated_at
+		FROM accounts WHERE provider_id = $1 AND account_id = $2
+	`, providerID, accountID).Scan(&a.ID, &a.UserID, &a.ProviderID, &a.AccountID, &a.AccessToken,
+		&a.RefreshToken, &a.AccessTokenExpiresAt, &a.RefreshTokenExpiresAt, &a.Scope, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &a, nil
+}
+
+func (s *AccountStore) Create(ctx context.Context, a *models.Account) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO accounts (id, user_id, provider_id, account_id, access_token, refresh_token,
+			access_token_expires_at, refresh_token_expires_at, scope, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`, a.ID, a.UserID, a.ProviderID, a.AccountID, a.AccessToken, a.RefreshToken,
+		a.AccessTokenExpiresAt, a.RefreshTokenExpiresAt, a.Scope, a.CreatedAt, a.UpdatedAt)
+	return mapError(err)
+}
+
+func (s *AccountStore) UpdateTokens(ctx context.Context, a *models.Account) error {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE accounts
+		SET access_token = $1, refresh_token = COALESCE($2, refresh_token),
+			access_token_expires_at = $3, scope = $4, updated_at = $5
+		WHERE id = $6
+	`, a.AccessToken, a.RefreshToken, a.AccessTokenExpiresAt, a.Scope, a.UpdatedAt, a.ID)
+	if err != nil {
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+type APIKeyStore struct {
+	db *pgxpool.Pool
+}
+
+func NewAPIKeyStore(db *pgxpool.Pool) *APIKeyStore {
+	return &APIKeyStore{db: db}
+}
+
+const apiKeyColumns = `
+	SELECT id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, created_at
+	FROM api_keys
+`
+
+func (s *APIKeyStore) Create(ctx context.Context, key *models.APIKey) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, key.ID, key.UserID, key.Name, key.Prefix, key.KeyHash, key.Scopes, key.ExpiresAt, key.CreatedAt)
+	return mapError(err)
+}
+
+func (s *APIKeyStore) GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := scanAPIKey(s.db.QueryRow(ctx, apiKeyColumns+" WHERE key_hash = $1", keyHash), &key); err != nil {
+		return nil, mapError(err)
+	}
+	return &key, nil
+}
+
+func (s *APIKeyStore) ListByUser(ctx context.Context, userID string) ([]models.APIKey, error) {
+	rows, err := s.db.Query(ctx, apiKeyColumns+" WHERE user_id = $1 ORDER BY created_at DESC", userID)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		var key models.APIKey
+		if err := scanAPIKey(rows, &key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *APIKeyStore) Delete(ctx context.Context, userID, id string) error {
+	tag, err := s.db.Exec(ctx, "DELETE FROM api_keys WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *APIKeyStore) DeleteByUser(ctx context.Context, userID string) error {
+	_, err := s.db.Exec(ctx, "DELETE FROM api_keys WHERE user_id = $1", userID)
+	return mapError(err)
+}
+
+func (s *APIKeyStore) TouchLastUsed(ctx context.Context, id string, at time.Time) error {
+	_, err := s.db.Exec(ctx, "UPDATE api_keys SET last_used_at = $1 WHERE id = $2", at, id)
+	return mapError(err)
+}
+
+func scanAPIKey(row interface{ Scan(...interface{}) error }, key *models.APIKey) error {
+	return row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash, &key.Scopes,
+		&key.ExpiresAt, &key.LastUsedAt, &key.CreatedAt)
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+type AuditLogStore struct {
+	db *pgxpool.Pool
+}
+
+func NewAuditLogStore(db *pgxpool.Pool) *AuditLogStore {
+	return &AuditLogStore{db: db}
+}
+
+func (s *AuditLogStore) Create(ctx context.Context, entry *models.AuditLog) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO audit_logs (id, actor_id, action, target_type, target_id, details, ip_address, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, entry.ID, entry.ActorID, entry.Action, entry.TargetType, entry.TargetID, entry.Details,
+		entry.IPAddress, entry.CreatedAt)
+	return mapError(err)
+}
+
+func (s *AuditLogStore) List(ctx context.Context, filter store.AuditLogFilter) ([]models.AuditLog, int, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	if filter.ActorID != "" {
+		args = append(args, filter.ActorID)
+		conditions = append(conditions, fmt.Sprintf("actor_id = $%d", len(args)))
+	}
+	if filter.TargetID != "" {
+		args = append(args, filter.TargetID)
+		conditions = append(conditions, fmt.Sprintf("target_id = $%d", len(args)))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRow(ctx, "SELECT COUNT(*) FROM audit_logs"+where, args...).Scan(&total); err != nil {
+		return nil, 0, mapError(err)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, actor_id, action, target_type, target_id, details, ip_address, created_at
+		FROM audit_logs%s
+		ORDER BY created_at DESC, id
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)+1, len(args)+2)
+	rows, err := s.db.Query(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, mapError(err)
+	}
+	defer rows.Close()
+
+	entries := []models.AuditLog{}
+	for rows.Next() {
+		var e models.AuditLog
+		err := rows.Scan(&e.ID, &e.ActorID, &e.Action, &e.TargetType, &e.TargetID, &e.Details,
+			&e.IPAddress, &e.CreatedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
@@ -0,0 +1,286 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/geo"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+type CampgroundStore struct {
+	db *pgxpool.Pool
+}
+
+func NewCampgroundStore(db *pgxpool.Pool) *CampgroundStore {
+	return &CampgroundStore{db: db}
+}
+
+const (
+	campgroundFields = `
+	c.id, c.name, c.price_amount, c.price_currency, c.price_unit, c.image, c.description, c.location,
+	c.address, c.latitude, c.longitude, c.author_id, c.created_at, c.updated_at,
+	u.id, u.username, u.display_username, u.image, r.average, r.count
+`
+	campgroundFrom = `
+	FROM campgrounds c
+	LEFT JOIN users u ON c.author_id = u.id
+	LEFT JOIN LATERAL (
+		SELECT avg(rating)::DOUBLE PRECISION AS average, count(rating) AS count
+		FROM comments WHERE campground_id = c.id
+	) r ON true
+`
+	campgroundColumns = "SELECT " + campgroundFields + campgroundFrom
+
+	// haversine is the great-circle distance in km from the point given by
+	// the two placeholders; LEAST guards asin against rounding past 1.
+	haversine = `(2 * %[3]f * asin(LEAST(1, sqrt(
+	power(sin(radians(c.latitude - $%[1]d) / 2), 2) +
+	cos(radians($%[1]d)) * cos(radians(c.latitude)) * power(sin(radians(c.longitude - $%[2]d) / 2), 2)))))`
+
+	// searchQuery parses the search like web search engines do: "quoted
+	// phrases", -excluded words and OR. The language must match the
+	// search_vector column.
+	searchQuery = "websearch_to_tsquery('english', $%d)"
+	// headline excerpts the description around the matches, which it marks
+	// with private use characters for highlightHTML.
+	headline = "ts_headline('english', c.description, %s, " +
+		"'StartSel=" + markStart + ", StopSel=" + markStop + ", MaxWords=35, MinWords=15, " +
+		"MaxFragments=2, FragmentDelimiter=\" … \"')"
+	markStart = "\ue000"
+	markStop  = "\ue001"
+)
+
+// campgroundOrders holds the ORDER BY for each field sort, so no part of
+// the request ends up in the query text.
+var campgroundOrders = map[store.CampgroundSort]string{
+	store.SortNewest:    "c.created_at DESC",
+	store.SortOldest:    "c.created_at",
+	store.SortPrice:     "c.price_amount, c.created_at DESC",
+	store.SortPriceDesc: "c.price_amount DESC, c.created_at DESC",
+	store.SortName:      "lower(c.name), c.created_at DESC",
+	store.SortNameDesc:  "lower(c.name) DESC, c.created_at DESC",
+	// avg is NULL for campgrounds without ratings
+	store.SortRating:     "r.average NULLS LAST, c.created_at DESC",
+	store.SortRatingDesc: "r.average DESC NULLS LAST, c.created_at DESC",
+}
+
+func (s *CampgroundStore) List(ctx context.Context, filter store.CampgroundFilter) ([]models.Campground, int, error) {
+	var conditions []string
+	args := []interface{}{}
+	highlight, rank := "NULL::TEXT", ""
+	if filter.Search != "" {
+		args = append(args, filter.Search)
+		query := fmt.Sprintf(searchQuery, len(args))
+		conditions = append(conditions, "c.search_vector @@ "+query)
+		highlight = fmt.Sprintf(headline, query)
+		rank = fmt.Sprintf("ts_rank_cd(c.search_vector, %s) DESC, ", query)
+	}
+	if filter.AuthorID != "" {
+		args = append(args, filter.AuthorID)
+		conditions = append(conditions, fmt.Sprintf("c.author_id = $%d", len(args)))
+	}
+	if filter.Author != "" {
+		args = append(args, filter.Author)
+		conditions = append(conditions, fmt.Sprintf("c.author_id = (SELECT id FROM users WHERE username = $%d)", len(args)))
+	}
+	if !filter.CreatedAfter.IsZero() {
+		args = append(args, filter.CreatedAfter)
+		conditions = append(conditions, fmt.Sprintf("c.created_at >= $%d", len(args)))
+	}
+	if !filter.CreatedBefore.IsZero() {
+		args = append(args, filter.CreatedBefore)
+		conditions = append(conditions, fmt.Sprintf("c.created_at < $%d", len(args)))
+	}
+	if filter.HasComments {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM comments cm WHERE cm.campground_id = c.id)")
+	}
+	if filter.Currency != "" {
+		args = append(args, filter.Currency)
+		conditions = append(conditions, fmt.Sprintf("c.price_currency = $%d", len(args)))
+	}
+	if filter.PriceMin != nil {
+		args = append(args, *filter.PriceMin)
+		conditions = append(conditions, fmt.Sprintf("c.price_amount >= $%d", len(args)))
+	}
+	if filter.PriceMax != nil {
+		args = append(args, *filter.PriceMax)
+		conditions = append(conditions, fmt.Sprintf("c.price_amount <= $%d", len(args)))
+	}
+	if filter.BBox != nil {
+		b := filter.BBox
+		args = append(args, b.MinLat, b.MaxLat, b.MinLng, b.MaxLng)
+		n := len(args)
+		lng := "c.longitude BETWEEN $%[3]d AND $%[4]d"
+		if b.MinLng > b.MaxLng {
+			lng = "(c.longitude >= $%[3]d OR c.longitude <= $%[4]d)"
+		}
+		conditions = append(conditions, fmt.Sprintf("c.latitude BETWEEN $%[1]d AND $%[2]d AND "+lng, n-3, n-2, n-1, n))
+	}
+	distance, order := "NULL::DOUBLE PRECISION", "c.created_at DESC"
+	if filter.Near != nil {
+		// Narrow by latitude first, which the index can serve
+		band := filter.RadiusKm / geo.EarthRadiusKm * 180 / math.Pi
+		args = append(args, filter.Near.Latitude-band, filter.Near.Latitude+band)
+		conditions = append(conditions, fmt.Sprintf("c.latitude BETWEEN $%d AND $%d", len(args)-1, len(args)))
+		args = append(args, filter.Near.Latitude, filter.Near.Longitude)
+		distance = fmt.Sprintf(haversine, len(args)-1, len(args), geo.EarthRadiusKm)
+		args = append(args, filter.RadiusKm)
+		conditions = append(conditions, fmt.Sprintf("%s <= $%d", distance, len(args)))
+		order = "distance, c.created_at DESC"
+	}
+	if filter.Sort == store.SortRelevance {
+		order = rank + "c.created_at DESC"
+	} else if sortOrder, ok := campgroundOrders[filter.Sort]; ok {
+		order = sortOrder
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// Count total
+	var total int
+	if err := s.db.QueryRow(ctx, "SELECT COUNT(*) FROM campgrounds c"+where, args...).Scan(&total); err != nil {
+		return nil, 0, mapError(err)
+	}
+
+	// Get campgrounds
+	query := fmt.Sprintf("SELECT %s, %s AS distance, %s %s%s ORDER BY %s LIMIT $%d OFFSET $%d",
+		campgroundFields, distance, highlight, campgroundFrom, where, order, len(args)+1, len(args)+2)
+	rows, err := s.db.Query(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, mapError(err)
+	}
+	defer rows.Close()
+
+	campgrounds := []models.Campground{}
+	for rows.Next() {
+		var c models.Campground
+		var excerpt *string
+		if err := scanCampground(rows, &c, &c.Distance, &excerpt); err != nil {
+			return nil, 0, err
+		}
+		if excerpt != nil {
+			c.Highlight = highlightHTML(*excerpt)
+		}
+		campgrounds = append(campgrounds, c)
+	}
+	return campgrounds, total, rows.Err()
+}
+
+func (s *CampgroundStore) GetByID(ctx context.Context, id int) (*models.Campground, error) {
+	var c models.Campground
+	if err := scanCampground(s.db.QueryRow(ctx, campgroundColumns+" WHERE c.id = $1", id), &c); err != nil {
+		return nil, mapError(err)
+	}
+	return &c, nil
+}
+
+func (s *CampgroundStore) Exists(ctx context.Context, id int) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM campgrounds WHERE id = $1)", id).Scan(&exists)
+	return exists, mapError(err)
+}
+
+func (s *CampgroundStore) Create(ctx context.Context, c *models.Campground) error {
+	err := s.db.QueryRow(ctx, `
+		INSERT INTO campgrounds (name, price_amount, price_currency, price_unit, image, description, location,
+			latitude, longitude, author_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id
+	`, c.Name, c.Price.Amount, c.Price.Currency, c.Price.Unit, c.Image, c.Description, c.Location,
+		c.Latitude, c.Longitude, c.AuthorID, c.CreatedAt, c.UpdatedAt).Scan(&c.ID)
+	return mapError(err)
+}
+
+func (s *CampgroundStore) Update(ctx context.Context, id int, req models.UpdateCampgroundRequest, updatedAt time.Time) error {
+	// The price is replaced as a whole
+	var amount *int64
+	var currency *string
+	var unit *models.PriceUnit
+	if req.Price != nil {
+		amount, currency, unit = &req.Price.Amount, &req.Price.Currency, &req.Price.Unit
+	}
+	// A new location drops the old place's address, and its coordinates
+	// unless new ones come along; SET expressions see the old row
+	tag, err := s.db.Exec(ctx, `
+		UPDATE campgrounds SET
+			name = COALESCE($1, name),
+			price_amount = COALESCE($2, price_amount),
+			price_currency = COALESCE($3, price_currency),
+			price_unit = COALESCE($4, price_unit),
+			image = COALESCE($5, image),
+			description = COALESCE($6, description),
+			location = COALESCE($7, location),
+			latitude = CASE WHEN $8::DOUBLE PRECISION IS NOT NULL THEN $8
+				WHEN COALESCE($7, location) IS DISTINCT FROM location THEN NULL ELSE latitude END,
+			longitude = CASE WHEN $9::DOUBLE PRECISION IS NOT NULL THEN $9
+				WHEN COALESCE($7, location) IS DISTINCT FROM location THEN NULL ELSE longitude END,
+			address = CASE WHEN COALESCE($7, location) IS DISTINCT FROM location THEN NULL ELSE address END,
+			updated_at = $10
+		WHERE id = $11
+	`, req.Name, amount, currency, unit, req.Image, req.Description, req.Location,
+		req.Latitude, req.Longitude, updatedAt, id)
+	if err != nil {
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *CampgroundStore) SetGeocode(ctx context.Context, id int, location string, point geo.Point, address string) error {
+	// Not a content change, so updated_at stays
+	tag, err := s.db.Exec(ctx, `
+		UPDATE campgrounds SET latitude = $1, longitude = $2, address = NULLIF($3, '')
+		WHERE id = $4 AND location = $5
+	`, point.Latitude, point.Longitude, address, id, location)
+	if err != nil {
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *CampgroundStore) Delete(ctx context.Context, id int) error {
+	tag, err := s.db.Exec(ctx, "DELETE FROM campgrounds WHERE id = $1", id)
+	if err != nil {
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// scanCampground scans the campgroundFields, then any extra columns.
+func scanCampground(row interface{ Scan(...interface{}) error }, c *models.Campground, extra ...interface{}) error {
+	var a authorRow
+	dest := []interface{}{&c.ID, &c.Name, &c.Price.Amount, &c.Price.Currency, &c.Price.Unit, &c.Image,
+		&c.Description, &c.Location, &c.Address, &c.Latitude, &c.Longitude, &c.AuthorID, &c.CreatedAt, &c.UpdatedAt,
+		&a.ID, &a.Username, &a.DisplayUsername, &a.Image, &c.Rating, &c.RatingCount}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return err
+	}
+	c.Author = a.author()
+	return nil
+}
+
+// highlightHTML escapes a ts_headline excerpt and turns its match markers
+// into <mark> tags, so clients can render it without trusting the text.
+func highlightHTML(excerpt string) *string {
+	escaped := html.EscapeString(excerpt)
+	escaped = strings.NewReplacer(markStart, "<mark>", markStop, "</mark>").Replace(escaped)
+	return &escaped
+}
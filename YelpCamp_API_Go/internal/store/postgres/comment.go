@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+type CommentStore struct {
+	db *pgxpool.Pool
+}
+
+func NewCommentStore(db *pgxpool.Pool) *CommentStore {
+	return &CommentStore{db: db}
+}
+
+const commentColumns = `
+	SELECT c.id, c.text, c.rating, c.campground_id, c.author_id, c.created_at, c.updated_at,
+		   u.id, u.username, u.display_username, u.image
+	FROM comments c
+	LEFT JOIN users u ON c.author_id = u.id
+`
+
+func (s *CommentStore) ListByCampground(ctx context.Context, campgroundID int) ([]models.Comment, error) {
+	return s.list(ctx, commentColumns+`
+		WHERE c.campground_id = $1
+		ORDER BY c.created_at DESC
+	`, campgroundID)
+}
+
+func (s *CommentStore) List(ctx context.Context, filter store.CommentFilter) ([]models.Comment, int, error) {
+	where := ""
+	args := []interface{}{}
+	if filter.AuthorID != "" {
+		where = " WHERE c.author_id = $1"
+		args = append(args, filter.AuthorID)
+	}
+
+	var total int
+	if err := s.db.QueryRow(ctx, "SELECT COUNT(*) FROM comments c"+where, args...).Scan(&total); err != nil {
+		return nil, 0, mapError(err)
+	}
+
+	query := fmt.Sprintf("%s%s ORDER BY c.created_at DESC LIMIT $%d OFFSET $%d",
+		commentColumns, where, len(args)+1, len(args)+2)
+	comments, err := s.list(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	return comments, total, err
+}
+
+func (s *CommentStore) GetByID(ctx context.Context, id int) (*models.Comment, error) {
+	var comment models.Comment
+	var a authorRow
+	err := s.db.QueryRow(ctx, commentColumns+" WHERE c.id = $1", id).
+		Scan(&comment.ID, &comment.Text, &comment.Rating, &comment.CampgroundID, &comment.AuthorID,
+			&comment.CreatedAt, &comment.UpdatedAt, &a.ID, &a.Username, &a.DisplayUsername, &a.Image)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	comment.Author = a.author()
+	return &comment, nil
+}
+
+func (s *CommentStore) Create(ctx context.Context, c *models.Comment) error {
+	err := s.db.QueryRow(ctx, `
+		INSERT INTO comments (text, rating, campground_id, author_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, c.Text, c.Rating, c.CampgroundID, c.AuthorID, c.CreatedAt, c.UpdatedAt).Scan(&c.ID)
+	return mapError(err)
+}
+
+func (s *CommentStore) Update(ctx context.Context, id int, text string, rating *int, updatedAt time.Time) error {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE comments SET text = $1, rating = $2, updated_at = $3 WHERE id = $4
+	`, text, rating, updatedAt, id)
+	if err != nil {
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *CommentStore) Delete(ctx context.Context, id int) error {
+	tag, err := s.db.Exec(ctx, "DELETE FROM comments WHERE id = $1", id)
+	if err != nil {
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *CommentStore) list(ctx context.Context, query string, args ...interface{}) ([]models.Comment, error) {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	comments := []models.Comment{}
+	for rows.Next() {
+		var comment models.Comment
+		var a authorRow
+		err := rows.Scan(&comment.ID, &comment.Text, &comment.Rating, &comment.CampgroundID, &comment.AuthorID,
+			&comment.CreatedAt, &comment.UpdatedAt, &a.ID, &a.Username, &a.DisplayUsername, &a.Image)
+		if err != nil {
+			return nil, err
+		}
+		comment.Author = a.author()
+		comments = append(comments, comment)
+	}
+	return comments, rows.Err()
+}
@@ -0,0 +1,112 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+type DataExportStore struct {
+	db *pgxpool.Pool
+}
+
+func NewDataExportStore(db *pgxpool.Pool) *DataExportStore {
+	return &DataExportStore{db: db}
+}
+
+const dataExportColumns = `
+	SELECT id, user_id, status, error, size, created_at, started_at, completed_at, expires_at
+	FROM data_exports
+`
+
+func (s *DataExportStore) Create(ctx context.Context, export *models.DataExport) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO data_exports (id, user_id, status, created_at) VALUES ($1, $2, $3, $4)
+	`, export.ID, export.UserID, export.Status, export.CreatedAt)
+	return mapError(err)
+}
+
+func (s *DataExportStore) Get(ctx context.Context, userID, id string) (*models.DataExport, error) {
+	return s.get(ctx, dataExportColumns+" WHERE id = $1 AND user_id = $2", id, userID)
+}
+
+func (s *DataExportStore) GetActive(ctx context.Context, userID string) (*models.DataExport, error) {
+	return s.get(ctx, dataExportColumns+" WHERE user_id = $1 AND status IN ($2, $3) LIMIT 1",
+		userID, models.ExportPending, models.ExportRunning)
+}
+
+// Claim locks the row with SKIP LOCKED, so workers on several replicas
+// never pick up the same export.
+func (s *DataExportStore) Claim(ctx context.Context, now, staleBefore time.Time) (*models.DataExport, error) {
+	var e models.DataExport
+	err := scanDataExport(s.db.QueryRow(ctx, `
+		UPDATE data_exports SET status = $1, started_at = $2
+		WHERE id = (
+			SELECT id FROM data_exports
+			WHERE status = $3 OR (status = $1 AND started_at < $4)
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, user_id, status, error, size, created_at, started_at, completed_at, expires_at
+	`, models.ExportRunning, now, models.ExportPending, staleBefore), &e)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &e, nil
+}
+
+func (s *DataExportStore) Complete(ctx context.Context, id string, archive []byte, completedAt, expiresAt time.Time) error {
+	return s.update(ctx, `
+		UPDATE data_exports SET status = $1, archive = $2, size = $3, completed_at = $4, expires_at = $5
+		WHERE id = $6
+	`, models.ExportReady, archive, len(archive), completedAt, expiresAt, id)
+}
+
+func (s *DataExportStore) Fail(ctx context.Context, id, message string, completedAt time.Time) error {
+	return s.update(ctx, "UPDATE data_exports SET status = $1, error = $2, completed_at = $3 WHERE id = $4",
+		models.ExportFailed, message, completedAt, id)
+}
+
+func (s *DataExportStore) Archive(ctx context.Context, userID, id string) ([]byte, error) {
+	var archive []byte
+	err := s.db.QueryRow(ctx, `
+		SELECT archive FROM data_exports WHERE id = $1 AND user_id = $2 AND archive IS NOT NULL
+	`, id, userID).Scan(&archive)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return archive, nil
+}
+
+func (s *DataExportStore) DeleteExpired(ctx context.Context, before time.Time) error {
+	_, err := s.db.Exec(ctx, "DELETE FROM data_exports WHERE expires_at < $1", before)
+	return mapError(err)
+}
+
+func (s *DataExportStore) get(ctx context.Context, query string, args ...interface{}) (*models.DataExport, error) {
+	var e models.DataExport
+	if err := scanDataExport(s.db.QueryRow(ctx, query, args...), &e); err != nil {
+		return nil, mapError(err)
+	}
+	return &e, nil
+}
+
+func (s *DataExportStore) update(ctx context.Context, query string, args ...interface{}) error {
+	tag, err := s.db.Exec(ctx, query, args...)
+	if err != nil {
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func scanDataExport(row interface{ Scan(...interface{}) error }, e *models.DataExport) error {
+	return row.Scan(&e.ID, &e.UserID, &e.Status, &e.Error, &e.Size, &e.CreatedAt, &e.StartedAt,
+		&e.CompletedAt, &e.ExpiresAt)
+}
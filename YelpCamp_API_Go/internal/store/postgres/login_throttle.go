@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+type LoginThrottleStore struct {
+	db *pgxpool.Pool
+}
+
+func NewLoginThrottleStore(db *pgxpool.Pool) *LoginThrottleStore {
+	return &LoginThrottleStore{db: db}
+}
+
+func (s *LoginThrottleStore) Get(ctx context.Context, key string) (*models.LoginThrottle, error) {
+	var t models.LoginThrottle
+	err := s.db.QueryRow(ctx, `
+		SELECT key, failures, last_failed_at, locked_until FROM login_throttles WHERE key = $1
+	`, key).Scan(&t.Key, &t.Failures, &t.LastFailedAt, &t.LockedUntil)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &t, nil
+}
+
+// RecordFailure upserts so concurrent failures for one key are all counted.
+func (s *LoginThrottleStore) RecordFailure(ctx context.Context, key string, at, resetBefore time.Time) (*models.LoginThrottle, error) {
+	var t models.LoginThrottle
+	err := s.db.QueryRow(ctx, `
+		INSERT INTO login_throttles (key, failures, last_failed_at) VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_throttles.last_failed_at < $3 THEN 1
+				ELSE login_throttles.failures + 1 END,
+			last_failed_at = $2
+		RETURNING key, failures, last_failed_at, locked_until
+	`, key, at, resetBefore).Scan(&t.Key, &t.Failures, &t.LastFailedAt, &t.LockedUntil)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &t, nil
+}
+
+func (s *LoginThrottleStore) Lock(ctx context.Context, key string, until time.Time) error {
+	tag, err := s.db.Exec(ctx, "UPDATE login_throttles SET locked_until = $1 WHERE key = $2", until, key)
+	if err != nil {
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *LoginThrottleStore) Delete(ctx context.Context, key string) error {
+	_, err := s.db.Exec(ctx, "DELETE FROM login_throttles WHERE key = $1", key)
+	return mapError(err)
+}
@@ -0,0 +1,70 @@
+package postgres
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+func New(db *pgxpool.Pool) *store.Stores {
+	return &store.Stores{
+		Users:         NewUserStore(db),
+		Campgrounds:   NewCampgroundStore(db),
+		Comments:      NewCommentStore(db),
+		Sessions:      NewSessionStore(db),
+		RefreshTokens: NewRefreshTokenStore(db),
+		Verifications: NewVerificationStore(db),
+		AuditLogs:     NewAuditLogStore(db),
+		TwoFactors:    NewTwoFactorStore(db),
+		Accounts:      NewAccountStore(db),
+		APIKeys:       NewAPIKeyStore(db),
+		Throttles:     NewLoginThrottleStore(db),
+		RateLimits:    NewRateLimitStore(db),
+		DataExports:   NewDataExportStore(db),
+	}
+}
+
+// mapError translates pgx errors into the store package's sentinel errors.
+func mapError(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return store.ErrConflict
+	}
+	return err
+}
+
+// authorRow receives the joined author columns, which are all NULL once
+// the author is deleted.
+type authorRow struct {
+	ID, Username, DisplayUsername, Image *string
+}
+
+func (a *authorRow) author() *models.Author {
+	if a.ID == nil || a.Username == nil {
+		return nil
+	}
+	return &models.Author{ID: *a.ID, Username: *a.Username, DisplayUsername: a.DisplayUsername, Image: a.Image}
+}
+
+var (
+	_ store.UserStore          = (*UserStore)(nil)
+	_ store.CampgroundStore    = (*CampgroundStore)(nil)
+	_ store.CommentStore       = (*CommentStore)(nil)
+	_ store.SessionStore       = (*SessionStore)(nil)
+	_ store.RefreshTokenStore  = (*RefreshTokenStore)(nil)
+	_ store.VerificationStore  = (*VerificationStore)(nil)
+	_ store.AuditLogStore      = (*AuditLogStore)(nil)
+	_ store.TwoFactorStore     = (*TwoFactorStore)(nil)
+	_ store.AccountStore       = (*AccountStore)(nil)
+	_ store.APIKeyStore        = (*APIKeyStore)(nil)
+	_ store.LoginThrottleStore = (*LoginThrottleStore)(nil)
+	_ store.RateLimitStore     = (*RateLimitStore)(nil)
+	_ store.DataExportStore    = (*DataExportStore)(nil)
+)
@@ -0,0 +1,63 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type RateLimitStore struct {
+	db *pgxpool.Pool
+}
+
+func NewRateLimitStore(db *pgxpool.Pool) *RateLimitStore {
+	return &RateLimitStore{db: db}
+}
+
+// refilledTokens is the bucket's level at $4, refilling $3 tokens a second
+// up to $2. Replica clocks may disagree slightly, so time never runs back.
+const refilledTokens = `LEAST($2::float8, rate_limits.tokens +
+	GREATEST(EXTRACT(EPOCH FROM ($4::timestamp - rate_limits.updated_at)), 0) * $3::float8)`
+
+// Take refills and takes a token in one upsert, so replicas sharing a bucket
+// can't both spend its last token. The WHERE clause skips the update when the
+// bucket is empty; the level is then read separately for the headers.
+func (s *RateLimitStore) Take(ctx context.Context, key string, capacity int, period time.Duration, now time.Time) (bool, float64, error) {
+	rate := float64(capacity) / period.Seconds()
+
+	var tokens float64
+	err := s.db.QueryRow(ctx, `
+		INSERT INTO rate_limits (key, tokens, updated_at) VALUES ($1, $2::float8 - 1, $4)
+		ON CONFLICT (key) DO UPDATE SET
+			tokens = `+refilledTokens+` - 1,
+			updated_at = GREATEST(rate_limits.updated_at, $4)
+		WHERE `+refilledTokens+` >= 1
+		RETURNING tokens
+	`, key, capacity, rate, now).Scan(&tokens)
+	if err == nil {
+		return true, tokens, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return false, 0, mapError(err)
+	}
+
+	err = s.db.QueryRow(ctx, `
+		SELECT `+refilledTokens+` FROM rate_limits WHERE key = $1
+	`, key, capacity, rate, now).Scan(&tokens)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Deleted in between; report it as just emptied.
+		return false, 0, nil
+	}
+	if err != nil {
+		return false, 0, mapError(err)
+	}
+	return false, tokens, nil
+}
+
+func (s *RateLimitStore) DeleteIdle(ctx context.Context, before time.Time) error {
+	_, err := s.db.Exec(ctx, "DELETE FROM rate_limits WHERE updated_at < $1", before)
+	return mapError(err)
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+type SessionStore struct {
+	db *pgxpool.Pool
+}
+
+func NewSessionStore(db *pgxpool.Pool) *SessionStore {
+	return &SessionStore{db: db}
+}
+
+const sessionColumns = `
+	SELECT id, user_id, token, expires_at, ip_address, user_agent, created_at, updated_at
+	FROM sessions
+`
+
+func (s *SessionStore) Create(ctx context.Context, session *models.Session) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO sessions (id, user_id, token, expires_at, ip_address, user_agent, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, session.ID, session.UserID, session.Token, session.ExpiresAt, session.IPAddress,
+		session.UserAgent, session.CreatedAt, session.UpdatedAt)
+	return mapError(err)
+}
+
+func (s *SessionStore) GetByID(ctx context.Context, id string) (*models.Session, error) {
+	var session models.Session
+	if err := scanSession(s.db.QueryRow(ctx, sessionColumns+" WHERE id = $1", id), &session); err != nil {
+		return nil, mapError(err)
+	}
+	return &session, nil
+}
+
+func (s *SessionStore) ListByUser(ctx context.Context, userID string) ([]models.Session, error) {
+	rows, err := s.db.Query(ctx, sessionColumns+`
+		WHERE user_id = $1 AND expires_at > $2
+		ORDER BY created_at DESC
+	`, userID, time.Now())
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		if err := scanSession(rows, &session); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (s *SessionStore) Renew(ctx context.Context, id, tokenHash string, expiresAt time.Time) error {
+	tag, err := s.db.Exec(ctx,
+		"UPDATE sessions SET token = $1, expires_at = $2, updated_at = $3 WHERE id = $4",
+		tokenHash, expiresAt, time.Now(), id)
+	if err != nil {
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *SessionStore) Delete(ctx context.Context, userID, id string) error {
+	tag, err := s.db.Exec(ctx, "DELETE FROM sessions WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *SessionStore) DeleteByUser(ctx context.Context, userID, exceptID string) error {
+	_, err := s.db.Exec(ctx, "DELETE FROM sessions WHERE user_id = $1 AND id <> $2", userID, exceptID)
+	return mapError(err)
+}
+
+func scanSession(row interface{ Scan(...interface{}) error }, session *models.Session) error {
+	return row.Scan(&session.ID, &session.UserID, &session.Token, &session.ExpiresAt,
+		&session.IPAddress, &session.UserAgent, &session.CreatedAt, &session.UpdatedAt)
+}
@@ -0,0 +1,155 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+type TwoFactorStore struct {
+	db *pgxpool.Pool
+}
+
+func NewTwoFactorStore(db *pgxpool.Pool) *TwoFactorStore {
+	return &TwoFactorStore{db: db}
+}
+
+func (s *TwoFactorStore) Get(ctx context.Context, userID string) (*models.TwoFactor, error) {
+	var tf models.TwoFactor
+	err := s.db.QueryRow(ctx, `
+		SELECT user_id, secret, confirmed_at, last_used_step, created_at, updated_at
+		FROM two_factors WHERE user_id = $1
+	`, userID).Scan(&tf.UserID, &tf.Secret, &tf.ConfirmedAt, &tf.LastUsedStep, &tf.CreatedAt, &tf.UpdatedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &tf, nil
+}
+
+func (s *TwoFactorStore) Save(ctx context.Context, tf *models.TwoFactor) error {
+	tag, err := s.db.Exec(ctx, `
+		INSERT INTO two_factors (user_id, secret, created_at, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = NULL, updated_at = EXCLUDED.updated_at
+		WHERE two_factors.confirmed_at IS NULL
+	`, tf.UserID, tf.Secret, tf.CreatedAt, tf.UpdatedAt)
+	if err != nil {
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrConflict
+	}
+	return nil
+}
+
+func (s *TwoFactorStore) Enable(ctx context.Context, userID string, recoveryCodeHashes []string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+	tag, err := tx.Exec(ctx, `
+		UPDATE two_factors SET confirmed_at = $1, updated_at = $1
+		WHERE user_id = $2 AND confirmed_at IS NULL
+	`, now, userID)
+	if err != nil {
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	if _, err := tx.Exec(ctx,
+		"UPDATE users SET two_factor_enabled = TRUE, updated_at = $1 WHERE id = $2", now, userID); err != nil {
+		return mapError(err)
+	}
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *TwoFactorStore) Disable(ctx context.Context, userID string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "DELETE FROM two_factors WHERE user_id = $1", userID); err != nil {
+		return mapError(err)
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		return mapError(err)
+	}
+	if _, err := tx.Exec(ctx,
+		"UPDATE users SET two_factor_enabled = FALSE, updated_at = $1 WHERE id = $2", time.Now(), userID); err != nil {
+		return mapError(err)
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *TwoFactorStore) UseStep(ctx context.Context, userID string, step int64) error {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE two_factors SET last_used_step = $1, updated_at = $2
+		WHERE user_id = $3 AND (last_used_step IS NULL OR last_used_step < $1)
+	`, step, time.Now(), userID)
+	if err != nil {
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrConflict
+	}
+	return nil
+}
+
+func (s *TwoFactorStore) UseRecoveryCode(ctx context.Context, userID, codeHash string) error {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE recovery_codes SET used_at = $1
+		WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL
+	`, time.Now(), userID, codeHash)
+	if err != nil {
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *TwoFactorStore) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID string, codeHashes []string) error {
+	if _, err := tx.Exec(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		return mapError(err)
+	}
+	now := time.Now()
+	for _, hash := range codeHashes {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO recovery_codes (id, user_id, code_hash, created_at)
+			VALUES ($1, $2, $3, $4)
+		`, uuid.New().String(), userID, hash, now)
+		if err != nil {
+			return mapError(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/store"
+)
+
+type UserStore struct {
+	db *pgxpool.Pool
+}
+
+func NewUserStore(db *pgxpool.Pool) *UserStore {
+	return &UserStore{db: db}
+}
+
+const userColumns = `
+	SELECT id, username, email, COALESCE(email_verified, FALSE), display_username, name, image, bio,
+		   two_factor_enabled, role, suspended_at, COALESCE(password, ''), created_at, updated_at
+	FROM users
+`
+
+func (s *UserStore) Create(ctx context.Context, user *models.User) error {
+	_, err := s.db.Exec(ctx,
+		`INSERT INTO users (id, username, email, email_verified, role, password, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		user.ID, user.Username, user.Email, user.EmailVerified, user.Role, user.Password, user.CreatedAt, user.UpdatedAt)
+	return mapError(err)
+}
+
+func (s *UserStore) GetByID(ctx context.Context, id string) (*models.User, error) {
+	return s.get(ctx, userColumns+" WHERE id = $1", id)
+}
+
+func (s *UserStore) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	return s.get(ctx, userColumns+" WHERE username = $1", username)
+}
+
+func (s *UserStore) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return s.get(ctx, userColumns+" WHERE email = $1", email)
+}
+
+func (s *UserStore) UpdatePassword(ctx context.Context, id, passwordHash string) error {
+	return s.update(ctx, "UPDATE users SET password = $1, updated_at = $2 WHERE id = $3",
+		passwordHash, time.Now(), id)
+}
+
+func (s *UserStore) UpdateEmail(ctx context.Context, id, email string) error {
+	return s.update(ctx, "UPDATE users SET email = $1, email_verified = FALSE, updated_at = $2 WHERE id = $3",
+		email, time.Now(), id)
+}
+
+// UpdateProfile keeps a column when its field is nil and stores NULL for
+// an empty string.
+func (s *UserStore) UpdateProfile(ctx context.Context, id string, req models.UpdateProfileRequest) error {
+	return s.update(ctx, `
+		UPDATE users SET
+			display_username = CASE WHEN $1::text IS NULL THEN display_username ELSE NULLIF($1, '') END,
+			name = CASE WHEN $2::text IS NULL THEN name ELSE NULLIF($2, '') END,
+			image = CASE WHEN $3::text IS NULL THEN image ELSE NULLIF($3, '') END,
+			bio = CASE WHEN $4::text IS NULL THEN bio ELSE NULLIF($4, '') END,
+			updated_at = $5
+		WHERE id = $6
+	`, req.DisplayUsername, req.Name, req.Image, req.Bio, time.Now(), id)
+}
+
+func (s *UserStore) GetProfile(ctx context.Context, username string) (*models.Profile, error) {
+	var p models.Profile
+	err := s.db.QueryRow(ctx, `
+		SELECT u.id, u.username, u.display_username, u.name, u.image, u.bio, u.created_at,
+			   (SELECT COUNT(*) FROM campgrounds WHERE author_id = u.id),
+			   (SELECT COUNT(*) FROM comments WHERE author_id = u.id)
+		FROM users u
+		WHERE u.username = $1
+	`, username).Scan(&p.ID, &p.Username, &p.DisplayUsername, &p.Name, &p.Image, &p.Bio, &p.JoinedAt,
+		&p.CampgroundCount, &p.CommentCount)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &p, nil
+}
+
+func (s *UserStore) MarkEmailVerified(ctx context.Context, id string) error {
+	return s.update(ctx, "UPDATE users SET email_verified = TRUE, updated_at = $1 WHERE id = $2",
+		time.Now(), id)
+}
+
+func (s *UserStore) UpdateRole(ctx context.Context, id string, role models.Role) error {
+	return s.update(ctx, "UPDATE users SET role = $1, updated_at = $2 WHERE id = $3",
+		role, time.Now(), id)
+}
+
+func (s *UserStore) SetSuspended(ctx context.Context, id string, suspendedAt *time.Time) error {
+	return s.update(ctx, "UPDATE users SET suspended_at = $1, updated_at = $2 WHERE id = $3",
+		suspendedAt, time.Now(), id)
+}
+
+func (s *UserStore) ExistsByUsernameOrEmail(ctx context.Context, username, email string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(ctx,
+		"SELECT EXISTS(SELECT 1 FROM users WHERE username = $1 OR email = $2)",
+		username, email).Scan(&exists)
+	return exists, mapError(err)
+}
+
+func (s *UserStore) List(ctx context.Context, filter store.UserFilter) ([]models.User, int, error) {
+	where := ""
+	args := []interface{}{}
+	if filter.Search != "" {
+		where = " WHERE username ILIKE $1 OR email ILIKE $1"
+		args = append(args, "%"+filter.Search+"%")
+	}
+
+	var total int
+	if err := s.db.QueryRow(ctx, "SELECT COUNT(*) FROM users"+where, args...).Scan(&total); err != nil {
+		return nil, 0, mapError(err)
+	}
+
+	query := fmt.Sprintf("%s%s ORDER BY created_at, id LIMIT $%d OFFSET $%d",
+		userColumns, where, len(args)+1, len(args)+2)
+	rows, err := s.db.Query(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, mapError(err)
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		if err := scanUser(rows, &user); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+	return users, total, rows.Err()
+}
+
+func (s *UserStore) Delete(ctx context.Context, id string) error {
+	return s.update(ctx, "DELETE FROM users WHERE id = $1", id)
+}
+
+// DeleteWithContent deletes in one transaction, so a failure can't leave the
+// account half gone. Comments on the campgrounds go by ON DELETE CASCADE.
+func (s *UserStore) DeleteWithContent(ctx context.Context, id string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "DELETE FROM comments WHERE author_id = $1", id); err != nil {
+		return mapError(err)
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM campgrounds WHERE author_id = $1", id); err != nil {
+		return mapError(err)
+	}
+	tag, err := tx.Exec(ctx, "DELETE FROM users WHERE id = $1", id)
+	if err != nil {
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *UserStore) get(ctx context.Context, query string, args ...interface{}) (*models.User, error) {
+	var user models.User
+	if err := scanUser(s.db.QueryRow(ctx, query, args...), &user); err != nil {
+		return nil, mapError(err)
+	}
+	return &user, nil
+}
+
+func scanUser(row interface{ Scan(...interface{}) error }, user *models.User) error {
+	return row.Scan(&user.ID, &user.Username, &user.Email, &user.EmailVerified, &user.DisplayUsername,
+		&user.Name, &user.Image, &user.Bio, &user.TwoFactorEnabled, &user.Role, &user.SuspendedAt,
+		&user.Password, &user.CreatedAt, &user.UpdatedAt)
+}
+
+func (s *UserStore) update(ctx context.Context, query string, args ...interface{}) error {
+	tag, err := s.db.Exec(ctx, query, args...)
+	if err != nil {
+		return mapError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+)
+
+type VerificationStore struct {
+	db *pgxpool.Pool
+}
+
+func NewVerificationStore(db *pgxpool.Pool) *VerificationStore {
+	return &VerificationStore{db: db}
+}
+
+func (s *VerificationStore) Create(ctx context.Context, v *models.Verification) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO verifications (id, identifier, value, expires_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, v.ID, v.Identifier, v.Value, v.ExpiresAt, v.CreatedAt, v.UpdatedAt)
+	return mapError(err)
+}
+
+func (s *VerificationStore) Consume(ctx context.Context, value string) (*models.Verification, error) {
+	var v models.Verification
+	err := s.db.QueryRow(ctx, `
+		DELETE FROM verifications WHERE value = $1
+		RETURNING id, identifier, value, expires_at, created_at, updated_at
+	`, value).Scan(&v.ID, &v.Identifier, &v.Value, &v.ExpiresAt, &v.CreatedAt, &v.UpdatedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &v, nil
+}
+
+func (s *VerificationStore) GetByIdentifier(ctx context.Context, identifier string) (*models.Verification, error) {
+	var v models.Verification
+	err := s.db.QueryRow(ctx, `
+		SELECT id, identifier, value, expires_at, created_at, updated_at
+		FROM verifications WHERE identifier = $1
+		ORDER BY created_at DESC LIMIT 1
+	`, identifier).Scan(&v.ID, &v.Identifier, &v.Value, &v.ExpiresAt, &v.CreatedAt, &v.UpdatedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &v, nil
+}
+
+func (s *VerificationStore) DeleteByIdentifier(ctx context.Context, identifier string) error {
+	_, err := s.db.Exec(ctx, "DELETE FROM verifications WHERE identifier = $1", identifier)
+	return mapError(err)
+}
@@ -0,0 +1,296 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sangnn2012/yelpcamp-api-go/internal/geo"
+	"github.com/sangnn2012/yelpcamp-api-go/internal/models"
+)
+
+var (
+	ErrNotFound = errors.New("record not found")
+	ErrConflict = errors.New("record already exists")
+)
+
+// Stores groups every repository the handlers depend on, so main can wire
+// either the Postgres or the in-memory implementation in one place.
+type Stores struct {
+	Users         UserStore
+	Campgrounds   CampgroundStore
+	Comments      CommentStore
+	Sessions      SessionStore
+	RefreshTokens RefreshTokenStore
+	Verifications VerificationStore
+	AuditLogs     AuditLogStore
+	TwoFactors    TwoFactorStore
+	Accounts      AccountStore
+	APIKeys       APIKeyStore
+	Throttles     LoginThrottleStore
+	RateLimits    RateLimitStore
+	DataExports   DataExportStore
+}
+
+type UserStore interface {
+	// Create inserts the user; Password must already be hashed.
+	Create(ctx context.Context, user *models.User) error
+	// The Get methods also load the password hash for credential checks;
+	// it is never serialized.
+	GetByID(ctx context.Context, id string) (*models.User, error)
+	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	UpdatePassword(ctx context.Context, id, passwordHash string) error
+	// UpdateEmail changes the email and marks it unverified. It returns
+	// ErrConflict if another user has the email.
+	UpdateEmail(ctx context.Context, id, email string) error
+	MarkEmailVerified(ctx context.Context, id string) error
+	UpdateProfile(ctx context.Context, id string, req models.UpdateProfileRequest) error
+	// GetProfile returns the public profile with content counts.
+	GetProfile(ctx context.Context, username string) (*models.Profile, error)
+	UpdateRole(ctx context.Context, id string, role models.Role) error
+	// SetSuspended suspends the user at the given time, or lifts the
+	// suspension when suspendedAt is nil.
+	SetSuspended(ctx context.Context, id string, suspendedAt *time.Time) error
+	ExistsByUsernameOrEmail(ctx context.Context, username, email string) (bool, error)
+	// List returns one page of users, oldest first, and the total count.
+	List(ctx context.Context, filter UserFilter) ([]models.User, int, error)
+	// Delete removes the user with their sessions; their campgrounds and
+	// comments are kept without an author.
+	Delete(ctx context.Context, id string) error
+	// DeleteWithContent is Delete, but the user's campgrounds (with all
+	// comments on them) and comments are deleted too.
+	DeleteWithContent(ctx context.Context, id string) error
+}
+
+// UserFilter matches Search against username and email.
+type UserFilter struct {
+	Search string
+	Limit  int
+	Offset int
+}
+
+// CampgroundSort orders a campground list. By default the newest come
+// first, or the nearest when the filter has Near. The other sorts name a
+// field, descending when prefixed with "-", and fall back to newest first
+// for ties.
+type CampgroundSort string
+
+const (
+	SortDefault CampgroundSort = ""
+	// SortRelevance puts the best Search matches first.
+	SortRelevance CampgroundSort = "relevance"
+	SortNewest    CampgroundSort = "-created_at"
+	SortOldest    CampgroundSort = "created_at"
+	// SortPrice compares amounts only, so it is only meaningful together
+	// with a Currency filter.
+	SortPrice     CampgroundSort = "price"
+	SortPriceDesc CampgroundSort = "-price"
+	// SortName ignores case.
+	SortName     CampgroundSort = "name"
+	SortNameDesc CampgroundSort = "-name"
+	// SortRating compares average ratings; campgrounds nobody has rated
+	// come last either way.
+	SortRating     CampgroundSort = "rating"
+	SortRatingDesc CampgroundSort = "-rating"
+)
+
+// CampgroundSorts lists every sort other than the default.
+var CampgroundSorts = []CampgroundSort{
+	SortRelevance, SortNewest, SortOldest, SortPrice, SortPriceDesc, SortName, SortNameDesc,
+	SortRating, SortRatingDesc,
+}
+
+// CampgroundFilter full-text searches Search in name, location and
+// description, weighted in that order, and sets each match's Highlight.
+// AuthorID or Author, a username, limits the list to one user's
+// campgrounds. CreatedAfter is an inclusive and CreatedBefore an exclusive
+// bound when not zero. HasComments leaves out campgrounds nobody has
+// commented on. PriceMin and PriceMax are inclusive bounds in minor units,
+// so they are only meaningful together with Currency. With Near, only
+// campgrounds within RadiusKm are listed, with their Distance set; BBox
+// limits the list to an area. Campgrounds without coordinates never match
+// either.
+type CampgroundFilter struct {
+	Search        string
+	AuthorID      string
+	Author        string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	HasComments   bool
+	Currency      string
+	PriceMin      *int64
+	PriceMax      *int64
+	Near          *geo.Point
+	RadiusKm      float64
+	BBox          *geo.BoundingBox
+	Sort          CampgroundSort
+	Limit         int
+	Offset        int
+}
+
+type CommentFilter struct {
+	AuthorID string
+	Limit    int
+	Offset   int
+}
+
+type CampgroundStore interface {
+	// List returns one page of campgrounds with authors and the total count.
+	List(ctx context.Context, filter CampgroundFilter) ([]models.Campground, int, error)
+	GetByID(ctx context.Context, id int) (*models.Campground, error)
+	Exists(ctx context.Context, id int) (bool, error)
+	// Create inserts the campground and sets its ID.
+	Create(ctx context.Context, c *models.Campground) error
+	// Update changes the fields set in req. A changed location clears the
+	// address, and the coordinates unless req has new ones.
+	Update(ctx context.Context, id int, req models.UpdateCampgroundRequest, updatedAt time.Time) error
+	// SetGeocode stores the coordinates and normalized address found for
+	// location. It returns ErrNotFound if the campground is gone or its
+	// location changed in the meantime.
+	SetGeocode(ctx context.Context, id int, location string, point geo.Point, address string) error
+	Delete(ctx context.Context, id int) error
+}
+
+type CommentStore interface {
+	ListByCampground(ctx context.Context, campgroundID int) ([]models.Comment, error)
+	// List returns one page of comments, newest first, and the total count.
+	List(ctx context.Context, filter CommentFilter) ([]models.Comment, int, error)
+	GetByID(ctx context.Context, id int) (*models.Comment, error)
+	// Create inserts the comment and sets its ID.
+	Create(ctx context.Context, c *models.Comment) error
+	// Update replaces the comment's text and rating; a nil rating removes it.
+	Update(ctx context.Context, id int, text string, rating *int, updatedAt time.Time) error
+	Delete(ctx context.Context, id int) error
+}
+
+type SessionStore interface {
+	Create(ctx context.Context, session *models.Session) error
+	GetByID(ctx context.Context, id string) (*models.Session, error)
+	// ListByUser returns the user's unexpired sessions, newest first.
+	ListByUser(ctx context.Context, userID string) ([]models.Session, error)
+	// Renew stores the session's new refresh token hash and expiry.
+	Renew(ctx context.Context, id, tokenHash string, expiresAt time.Time) error
+	// Delete removes one of the user's sessions and its refresh tokens.
+	Delete(ctx context.Context, userID, id string) error
+	// DeleteByUser removes all of the user's sessions except exceptID,
+	// which may be empty.
+	DeleteByUser(ctx context.Context, userID, exceptID string) error
+}
+
+// RefreshTokenStore keeps rotated refresh tokens. A token's FamilyID is the
+// ID of the session it belongs to.
+type RefreshTokenStore interface {
+	Create(ctx context.Context, token *models.RefreshToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	// Rotate revokes oldID and stores next in one step. It returns
+	// ErrConflict if oldID was already revoked by a concurrent rotation.
+	Rotate(ctx context.Context, oldID string, next *models.RefreshToken) error
+	RevokeFamily(ctx context.Context, familyID string) error
+}
+
+type VerificationStore interface {
+	Create(ctx context.Context, v *models.Verification) error
+	// Consume deletes and returns the verification with the given value, so
+	// each token can be redeemed at most once.
+	Consume(ctx context.Context, value string) (*models.Verification, error)
+	// GetByIdentifier returns the most recent verification for identifier.
+	GetByIdentifier(ctx context.Context, identifier string) (*models.Verification, error)
+	DeleteByIdentifier(ctx context.Context, identifier string) error
+}
+
+type AuditLogFilter struct {
+	ActorID  string
+	TargetID string
+	Limit    int
+	Offset   int
+}
+
+type AuditLogStore interface {
+	Create(ctx context.Context, entry *models.AuditLog) error
+	// List returns one page of entries, newest first, and the total count.
+	List(ctx context.Context, filter AuditLogFilter) ([]models.AuditLog, int, error)
+}
+
+// TwoFactorStore keeps TOTP enrollments and their hashed recovery codes.
+type TwoFactorStore interface {
+	Get(ctx context.Context, userID string) (*models.TwoFactor, error)
+	// Save stores an unconfirmed enrollment, replacing any earlier
+	// unconfirmed one. It returns ErrConflict if two-factor is already on.
+	Save(ctx context.Context, tf *models.TwoFactor) error
+	// Enable confirms the enrollment, turns two-factor on for the user and
+	// replaces their recovery codes in one step.
+	Enable(ctx context.Context, userID string, recoveryCodeHashes []string) error
+	// Disable removes the enrollment and recovery codes and turns
+	// two-factor off.
+	Disable(ctx context.Context, userID string) error
+	// UseStep records step as the last accepted TOTP step. It returns
+	// ErrConflict unless step is newer than the last one, so each code
+	// works once.
+	UseStep(ctx context.Context, userID string, step int64) error
+	// UseRecoveryCode marks an unused code as used, or returns ErrNotFound.
+	UseRecoveryCode(ctx context.Context, userID, codeHash string) error
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error
+}
+
+type AccountStore interface {
+	// GetByProvider finds the account for a provider's subject identifier.
+	GetByProvider(ctx context.Context, providerID, accountID string) (*models.Account, error)
+	// Create links the account; it returns ErrConflict if the provider
+	// identity is already linked.
+	Create(ctx context.Context, account *models.Account) error
+	// UpdateTokens stores the provider tokens from the latest sign-in.
+	UpdateTokens(ctx context.Context, account *models.Account) error
+}
+
+type APIKeyStore interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	// ListByUser returns all of the user's keys, newest first.
+	ListByUser(ctx context.Context, userID string) ([]models.APIKey, error)
+	// Delete removes one of the user's keys.
+	Delete(ctx context.Context, userID, id string) error
+	// DeleteByUser removes all of the user's keys.
+	DeleteByUser(ctx context.Context, userID string) error
+	TouchLastUsed(ctx context.Context, id string, at time.Time) error
+}
+
+type LoginThrottleStore interface {
+	Get(ctx context.Context, key string) (*models.LoginThrottle, error)
+	// RecordFailure counts a failed login at the given time and returns the
+	// updated row. The count starts over if the last failure was before
+	// resetBefore.
+	RecordFailure(ctx context.Context, key string, at, resetBefore time.Time) (*models.LoginThrottle, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Delete(ctx context.Context, key string) error
+}
+
+// RateLimitStore keeps token buckets for request rate limiting. A bucket
+// holds up to capacity tokens and refills at capacity per period; one that
+// doesn't exist yet is full.
+type RateLimitStore interface {
+	// Take refills the bucket up to now and removes one token if there is
+	// one. It returns whether a token was taken and how many are left.
+	Take(ctx context.Context, key string, capacity int, period time.Duration, now time.Time) (bool, float64, error)
+	// DeleteIdle removes buckets last used before the given time.
+	DeleteIdle(ctx context.Context, before time.Time) error
+}
+
+// DataExportStore keeps export jobs and their finished archives. Jobs are
+// claimed by whichever replica's worker gets to them first.
+type DataExportStore interface {
+	Create(ctx context.Context, export *models.DataExport) error
+	Get(ctx context.Context, userID, id string) (*models.DataExport, error)
+	// GetActive returns the user's pending or running export.
+	GetActive(ctx context.Context, userID string) (*models.DataExport, error)
+	// Claim marks the oldest pending export as running and returns it. A
+	// running export started before staleBefore is claimed again, since its
+	// worker must have died. It returns ErrNotFound when there is no work.
+	Claim(ctx context.Context, now, staleBefore time.Time) (*models.DataExport, error)
+	Complete(ctx context.Context, id string, archive []byte, completedAt, expiresAt time.Time) error
+	Fail(ctx context.Context, id, message string, completedAt time.Time) error
+	// Archive returns a ready export's archive.
+	Archive(ctx context.Context, userID, id string) ([]byte, error)
+	// DeleteExpired removes exports whose archive expired before the time.
+	DeleteExpired(ctx context.Context, before time.Time) error
+}
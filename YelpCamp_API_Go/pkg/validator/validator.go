@@ -1,6 +1,9 @@
 package validator
 
 import (
+	"reflect"
+	"strings"
+
 	"github.com/go-playground/validator/v10"
 )
 
@@ -8,32 +11,75 @@ var validate *validator.Validate
 
 func init() {
 	validate = validator.New()
+	// Report fields by their JSON names, the ones clients send
+	validate.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+}
+
+// FieldError describes one invalid field. Rule is the failed validation
+// tag, e.g. "required" or "email".
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
 }
 
 func Validate(s interface{}) error {
 	return validate.Struct(s)
 }
 
-func ValidationErrors(err error) []string {
-	var errors []string
+// Var checks a single value against tag, e.g. a query parameter with the
+// rule its struct field counterpart uses.
+func Var(value interface{}, tag string) error {
+	return validate.Var(value, tag)
+}
+
+func ValidationErrors(err error) []FieldError {
+	var errors []FieldError
 	if validationErrors, ok := err.(validator.ValidationErrors); ok {
 		for _, e := range validationErrors {
-			errors = append(errors, formatError(e))
+			errors = append(errors, FieldError{Field: fieldPath(e), Rule: e.Tag(), Message: formatError(e)})
 		}
 	}
 	return errors
 }
 
+// fieldPath is the field's path below the validated struct, e.g.
+// "image" or "items[0].name".
+func fieldPath(e validator.FieldError) string {
+	_, path, found := strings.Cut(e.Namespace(), ".")
+	if !found {
+		return e.Field()
+	}
+	return path
+}
+
 func formatError(e validator.FieldError) string {
 	switch e.Tag() {
 	case "required":
 		return e.Field() + " is required"
+	case "required_with":
+		// The param is the Go field name; ours match the JSON name but for case
+		return e.Field() + " is required with " + strings.ToLower(e.Param()[:1]) + e.Param()[1:]
 	case "email":
 		return e.Field() + " must be a valid email"
 	case "min":
-		return e.Field() + " must be at least " + e.Param() + " characters"
+		return e.Field() + " must be at least " + e.Param() + " " + unit(e)
 	case "max":
-		return e.Field() + " must be at most " + e.Param() + " characters"
+		return e.Field() + " must be at most " + e.Param() + " " + unit(e)
+	case "gte":
+		return e.Field() + " must be at least " + e.Param()
+	case "lte":
+		return e.Field() + " must be at most " + e.Param()
+	case "iso4217":
+		return e.Field() + " must be an ISO 4217 currency code, e.g. USD"
+	case "oneof":
+		return e.Field() + " must be one of: " + strings.ReplaceAll(e.Param(), " ", ", ")
 	case "url":
 		return e.Field() + " must be a valid URL"
 	case "alphanum":
@@ -42,3 +88,11 @@ func formatError(e validator.FieldError) string {
 		return e.Field() + " is invalid"
 	}
 }
+
+// unit is what min and max count for the field.
+func unit(e validator.FieldError) string {
+	if e.Kind() == reflect.Slice {
+		return "items"
+	}
+	return "characters"
+}